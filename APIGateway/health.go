@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"Task36a41/pkg/tracing"
+)
+
+// healthCheckTimeout ограничивает ожидание ответа каждого сервиса.
+const healthCheckTimeout = 3 * time.Second
+
+// upstreams — сервисы, готовность которых проверяет /health.
+var upstreams = map[string]string{
+	"news":       "http://localhost:8082/readyz",
+	"comments":   "http://localhost:8081/readyz",
+	"censorship": "http://localhost:8083/readyz",
+}
+
+// dependencyStatus — состояние одного сервиса.
+type dependencyStatus struct {
+	Status     string          `json:"status"`
+	LatencyMS  int64           `json:"latency_ms"`
+	HTTPStatus int             `json:"http_status,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Details    json.RawMessage `json:"details,omitempty"` // Ответ /readyz сервиса с его собственными проверками
+}
+
+// checkUpstream запрашивает /readyz сервиса и замеряет время ответа.
+func checkUpstream(ctx context.Context, url string) dependencyStatus {
+	start := time.Now()
+	result := dependencyStatus{Status: "unavailable"}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	resp, err := upstreamClient.Do(req)
+	result.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+
+	result.HTTPStatus = resp.StatusCode
+	if body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10)); err == nil && json.Valid(body) {
+		result.Details = body
+	}
+	if resp.StatusCode != http.StatusOK {
+		result.Error = fmt.Sprintf("unexpected status %s", resp.Status)
+		return result
+	}
+	result.Status = "ok"
+	return result
+}
+
+// Проверить готовность всех сервисов. Сервисы опрашиваются параллельно;
+// ответ 200, если все готовы, иначе 503 с описанием каждой зависимости.
+func getHealth(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	dependencies := make(map[string]dependencyStatus, len(upstreams))
+	for name, url := range upstreams {
+		wg.Add(1)
+		go func(name, url string) {
+			defer wg.Done()
+			status := checkUpstream(ctx, url)
+			mu.Lock()
+			dependencies[name] = status
+			mu.Unlock()
+		}(name, url)
+	}
+	wg.Wait()
+
+	status, code := "ok", http.StatusOK
+	for name, dependency := range dependencies {
+		if dependency.Status != "ok" {
+			status, code = "unavailable", http.StatusServiceUnavailable
+			log.Printf("[Request ID: %s] Dependency %s is not ready: %s", tracing.TraceID(r.Context()), name, dependency.Error)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":       status,
+		"dependencies": dependencies,
+	})
+}
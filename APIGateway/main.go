@@ -3,34 +3,37 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
-	"github.com/google/uuid"
+	"Task36a41/pkg/tracing"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// shutdownTimeout — сколько ждать завершения обрабатываемых запросов при остановке.
+const shutdownTimeout = 10 * time.Second
+
+// streams отменяется при остановке шлюза и завершает проксируемые потоки /news/stream,
+// которые иначе не закончились бы до истечения shutdownTimeout.
+var streams, closeStreams = context.WithCancel(context.Background())
+
 type Comment struct {
 	ID      int    `json:"id"`
 	NewsID  int    `json:"news_id"`
 	Content string `json:"content"`
 }
 
-func requestIDMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		requestID := r.Header.Get("X-Request-ID")
-		if requestID == "" {
-			requestID = uuid.New().String()
-		}
-		ctx := context.WithValue(r.Context(), "request_id", requestID)
-		r = r.WithContext(ctx)
-		w.Header().Set("X-Request-ID", requestID)
-		next.ServeHTTP(w, r)
-	})
-}
+// logRequestMiddleware учитывает запрос в метриках и пишет его в журнал.
+// Идентификатор запроса в журнале — идентификатор трассировки из tracing.ServeMux.
 func logRequestMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -40,10 +43,10 @@ func logRequestMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(rw, r)
 
 		duration := time.Since(start)
+		observeRequest(r, rw.statusCode, duration)
 
-		// Если обработчик обновил Request ID, он будет учтен
-		log.Printf("Completed request: %s %s with status %d in %v [Client IP: %s]",
-			r.Method, r.URL.Path, rw.statusCode, duration, getClientIP(r))
+		log.Printf("[Request ID: %s] Completed request: %s %s with status %d in %v [Client IP: %s]",
+			tracing.TraceID(r.Context()), r.Method, r.URL.Path, rw.statusCode, duration, getClientIP(r))
 	})
 }
 
@@ -57,42 +60,23 @@ func (rw *responseWriterWrapper) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap нужен http.ResponseController, чтобы сбрасывать потоковые ответы клиенту
+func (rw *responseWriterWrapper) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 func forwardRequest(ctx context.Context, method, url string, body io.Reader) (*http.Response, error) {
 	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, err
 	}
 
-	// Передаем текущий Request ID из контекста
-	requestID := ctx.Value("request_id")
-	if requestID != nil {
-		req.Header.Set("X-Request-ID", requestID.(string))
-	}
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-
-	// Проверяем и логируем новый Request ID из ответа
-	externalRequestID := resp.Header.Get("X-Request-ID")
-	if externalRequestID != "" {
-		log.Printf("External service responded with Request ID: %s", externalRequestID)
-	}
-
-	return resp, nil
+	// Контекст трассировки передаёт upstreamClient
+	return upstreamClient.Do(req)
 }
 
 func processResponse(w http.ResponseWriter, resp *http.Response) {
-	ctx := resp.Request.Context()
-	requestID := ctx.Value("request_id").(string)
-
-	// Проверяем и обновляем Request ID, если он пришел из внешнего сервиса
-	externalRequestID := resp.Header.Get("X-Request-ID")
-	if externalRequestID != "" {
-		requestID = externalRequestID
-	}
+	requestID := tracing.TraceID(resp.Request.Context())
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -102,7 +86,12 @@ func processResponse(w http.ResponseWriter, resp *http.Response) {
 	}
 	defer resp.Body.Close()
 
-	w.Header().Set("Content-Type", "application/json")
+	// Сервисы отвечают не только JSON: ленты отдаются в XML
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(resp.StatusCode)
 	w.Write(body)
 
@@ -116,7 +105,11 @@ func getLastNPosts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	apiURL := fmt.Sprintf("http://localhost:8082/news/%s", n)
-	requestID := r.Context().Value("request_id").(string)
+	// Курсор передаётся и пустым: он запрашивает первую порцию вместе с курсорами
+	if query := r.URL.Query(); query.Has("cursor") {
+		apiURL += "?cursor=" + url.QueryEscape(query.Get("cursor"))
+	}
+	requestID := tracing.TraceID(r.Context())
 	log.Printf("%s [Request ID: %s] Fetching last %s posts",
 		time.Now().Format(time.RFC3339), requestID, n)
 
@@ -130,9 +123,11 @@ func getLastNPosts(w http.ResponseWriter, r *http.Request) {
 	processResponse(w, resp)
 }
 
+// newsQueryParams — параметры /news, которые шлюз передаёт сервису новостей.
+var newsQueryParams = []string{"s", "source", "from", "to", "sort", "per_page", "cursor", "collapse"}
+
 // Получить новости с фильтрацией и пагинацией
 func getNews(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query().Get("s")
 	page := r.URL.Query().Get("page")
 	if page == "" {
 		page = "1"
@@ -140,7 +135,11 @@ func getNews(w http.ResponseWriter, r *http.Request) {
 
 	baseURL := "http://localhost:8082/news"
 	params := url.Values{}
-	params.Add("s", query)
+	for _, name := range newsQueryParams {
+		if value := r.URL.Query().Get(name); value != "" {
+			params.Add(name, value)
+		}
+	}
 	params.Add("page", page)
 
 	apiURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
@@ -155,6 +154,92 @@ func getNews(w http.ResponseWriter, r *http.Request) {
 	processResponse(w, resp)
 }
 
+// Получить ленту RSS или Atom со всеми новостями. Фильтры те же, что у /news.
+// Ссылки в ленте ведут на шлюз: его адрес задан в public_url конфигурации сервиса новостей.
+func getFeed(w http.ResponseWriter, r *http.Request) {
+	apiURL := "http://localhost:8082" + r.URL.Path
+	if r.URL.RawQuery != "" {
+		apiURL += "?" + r.URL.RawQuery
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, apiURL, nil)
+	if err != nil {
+		http.Error(w, "Error creating request", http.StatusInternalServerError)
+		return
+	}
+	resp, err := upstreamClient.Do(req)
+	if err != nil {
+		http.Error(w, "Error contacting news service", http.StatusInternalServerError)
+		log.Printf("Error contacting news service: %v", err)
+		return
+	}
+
+	processResponse(w, resp)
+}
+
+// Поток новых публикаций (Server-Sent Events). В отличие от processResponse,
+// ответ сервиса новостей передаётся клиенту по частям, по мере поступления.
+// Last-Event-ID передаётся дальше, чтобы после переподключения поток продолжился без пропусков.
+func streamNews(w http.ResponseWriter, r *http.Request) {
+	apiURL := "http://localhost:8082/news/stream"
+	if lastEventID := r.URL.Query().Get("last_event_id"); lastEventID != "" {
+		apiURL += "?last_event_id=" + url.QueryEscape(lastEventID)
+	}
+	requestID := tracing.TraceID(r.Context())
+
+	// Поток прерывается, когда уходит клиент или останавливается шлюз
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	defer context.AfterFunc(streams, cancel)()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		http.Error(w, "Error creating request", http.StatusInternalServerError)
+		return
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := upstreamClient.Do(req)
+	if err != nil {
+		http.Error(w, "Error contacting news service", http.StatusInternalServerError)
+		log.Printf("[Request ID: %s] Error contacting news service: %v", requestID, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		processResponse(w, resp)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	buf := make([]byte, 4096)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				break
+			}
+			if ferr := rc.Flush(); ferr != nil {
+				log.Printf("[Request ID: %s] Error flushing stream: %v", requestID, ferr)
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	log.Printf("[Request ID: %s] News stream closed", requestID)
+}
+
 // Получить детали новости
 func getNewsDetails(w http.ResponseWriter, r *http.Request) {
 	id := r.URL.Query().Get("id")
@@ -164,7 +249,7 @@ func getNewsDetails(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
-	requestID := ctx.Value("request_id").(string)
+	requestID := tracing.TraceID(ctx)
 
 	// 1. Получаем информацию о новости
 	newsAPIURL := fmt.Sprintf("http://localhost:8082/news/details?id=%s", url.QueryEscape(id))
@@ -176,12 +261,6 @@ func getNewsDetails(w http.ResponseWriter, r *http.Request) {
 	}
 	defer newsResp.Body.Close()
 
-	// Обновляем Request ID
-	externalRequestID := newsResp.Header.Get("X-Request-ID")
-	if externalRequestID != "" {
-		requestID = externalRequestID
-	}
-
 	if newsResp.StatusCode != http.StatusOK {
 		http.Error(w, "Failed to get news details", newsResp.StatusCode)
 		processResponse(w, newsResp)
@@ -261,12 +340,6 @@ func addComment(w http.ResponseWriter, r *http.Request) {
 	}
 	defer resp.Body.Close()
 
-	// Извлечение Request ID из заголовков ответа
-	externalRequestID := resp.Header.Get("X-Request-ID")
-	if externalRequestID != "" {
-		log.Printf("%s [Request ID: %s] Received external Request ID from comments service: %s", time.Now().Format(time.RFC3339), r.Context().Value("request_id").(string), externalRequestID)
-	}
-
 	processResponse(w, resp)
 }
 
@@ -311,16 +384,52 @@ func getComments(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
+	// SIGINT и SIGTERM отменяют контекст и запускают остановку шлюза
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Трассировка: экспортёр задаётся переменными окружения OTEL_*
+	shutdownTracing, err := tracing.Init(ctx, "gateway")
+	if err != nil {
+		log.Fatalf("Error initializing tracing: %v", err)
+	}
+
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/news", getNews)
 	mux.HandleFunc("/news/last", getLastNPosts)
+	mux.HandleFunc("/news/stream", streamNews)
 	mux.HandleFunc("/news/details", getNewsDetails)
 	mux.HandleFunc("/news/comments", getComments)
 	mux.HandleFunc("/news/comments/add", addComment)
+	mux.HandleFunc("/feed.rss", getFeed)
+	mux.HandleFunc("/feed.atom", getFeed)
+	mux.HandleFunc("/health", getHealth)
+	mux.Handle("/metrics", promhttp.Handler())
+
+	handler := tracing.ServeMux(mux, logRequestMiddleware)
+
+	server := &http.Server{Addr: ":8080", Handler: handler}
+	server.RegisterOnShutdown(closeStreams)
+	go func() {
+		log.Println("API Gateway is running on http://localhost:8080")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Error starting server: %v", err)
+		}
+	}()
 
-	handler := requestIDMiddleware(logRequestMiddleware(mux))
+	// Перестаём принимать запросы и дожидаемся текущих
+	<-ctx.Done()
+	stop() // Повторный сигнал завершит процесс сразу
+	log.Println("Shutting down API Gateway...")
 
-	log.Println("API Gateway is running on http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":8080", handler))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down server: %v", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Printf("Error shutting down tracing: %v", err)
+	}
+	log.Println("API Gateway stopped")
 }
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"Task36a41/pkg/tracing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Метрики шлюза в формате Prometheus, отдаются на /metrics.
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_http_requests_total",
+		Help: "Количество обработанных HTTP-запросов по маршруту, методу и коду ответа.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_http_request_duration_seconds",
+		Help:    "Время обработки HTTP-запросов по маршруту, методу и коду ответа.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	upstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_upstream_request_duration_seconds",
+		Help:    "Время ответа сервисов до получения заголовков по сервису, методу и коду ответа (error — ошибка соединения).",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"upstream", "method", "status"})
+)
+
+// upstreamNames — названия сервисов по адресу для меток метрик.
+var upstreamNames = map[string]string{
+	"localhost:8082": "news",
+	"localhost:8081": "comments",
+	"localhost:8083": "censorship",
+}
+
+// upstreamClient — клиент для запросов к сервисам, замеряющий время их ответа
+// и передающий им контекст трассировки.
+var upstreamClient = &http.Client{Transport: &tracingTransport{next: &metricsTransport{next: http.DefaultTransport}}}
+
+// metricsTransport замеряет время ответа сервисов.
+type metricsTransport struct {
+	next http.RoundTripper
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+
+	upstream, ok := upstreamNames[req.URL.Host]
+	if !ok {
+		upstream = "other"
+	}
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	upstreamDuration.WithLabelValues(upstream, req.Method, status).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+// observeRequest учитывает обработанный запрос к шлюзу. Маршрутом служит
+// шаблон из mux, найденный tracing.ServeMux, поэтому запросы к неизвестным
+// путям не порождают новых рядов.
+func observeRequest(r *http.Request, status int, duration time.Duration) {
+	route := tracing.Route(r.Context())
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(route, r.Method, code).Inc()
+	httpDuration.WithLabelValues(route, r.Method, code).Observe(duration.Seconds())
+}
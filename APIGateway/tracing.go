@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+
+	"Task36a41/pkg/tracing"
+
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Трассировка: шлюз начинает трассировку запроса клиента или продолжает переданную
+// в traceparent (tracing.ServeMux) и передаёт её сервисам в заголовках W3C
+// traceparent и tracestate. Экспортёр задаётся переменной OTEL_TRACES_EXPORTER,
+// как и во всех сервисах, см. пакет Task36a41/pkg/tracing.
+
+// tracingTransport создаёт клиентский спан для каждого запроса к сервису
+// и передаёт ему контекст трассировки. Спан завершается с получением заголовков ответа.
+type tracingTransport struct {
+	next http.RoundTripper
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	upstream, ok := upstreamNames[req.URL.Host]
+	if !ok {
+		upstream = "other"
+	}
+	ctx, span := tracing.Tracer().Start(req.Context(), req.Method+" "+upstream,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPMethod(req.Method), semconv.URLFull(req.URL.String()), semconv.PeerService(upstream)))
+	defer span.End()
+
+	// RoundTripper не должен менять исходный запрос, поэтому заголовки добавляются в копию
+	req = req.Clone(ctx)
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return resp, err
+	}
+	span.SetAttributes(semconv.HTTPStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	return resp, nil
+}
@@ -2,14 +2,23 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
+
+	"Task36a41/pkg/tracing"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // API представляет структуру для API с доступом к базе данных.
@@ -24,13 +33,21 @@ func NewAPI(db *sql.DB) *API {
 
 // RegisterRoutes регистрирует маршруты API и middleware.
 func (api *API) RegisterRoutes(router *mux.Router) {
-	// Добавляем middleware для request_id и логирования
-	router.Use(RequestIDMiddleware)
+	// Добавляем middleware для трассировки, логирования и метрик
+	router.Use(tracing.Middleware)
 	router.Use(LoggingMiddleware)
+	router.Use(MetricsMiddleware)
 
 	// Регистрация маршрутов
 	router.HandleFunc("/comments", api.AddCommentHandler).Methods(http.MethodPost)
 	router.HandleFunc("/comments", api.GetCommentsHandler).Methods(http.MethodGet)
+
+	// Проверки живости и готовности
+	router.HandleFunc("/healthz", api.HealthzHandler).Methods(http.MethodGet)
+	router.HandleFunc("/readyz", api.ReadyzHandler).Methods(http.MethodGet)
+
+	// Метрики в формате Prometheus
+	router.Handle("/metrics", promhttp.Handler()).Methods(http.MethodGet)
 }
 
 // AddCommentHandler — обработчик для добавления комментария.
@@ -43,13 +60,13 @@ func (api *API) AddCommentHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Проверяем комментарий через сервис цензурирования
-	if !api.checkCensorship(comment.Content) {
+	if !api.checkCensorship(r.Context(), comment.Content) {
 		http.Error(w, "Comment rejected by censorship service", http.StatusBadRequest)
 		return
 	}
 
 	// Сохраняем комментарий в БД
-	id, err := SaveComment(api.db, &comment)
+	id, err := SaveComment(r.Context(), api.db, &comment)
 	if err != nil {
 		http.Error(w, "Failed to save comment", http.StatusInternalServerError)
 		return
@@ -69,7 +86,7 @@ func (api *API) GetCommentsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	comments, err := GetCommentsByNewsID(api.db, newsID)
+	comments, err := GetCommentsByNewsID(r.Context(), api.db, newsID)
 	if err != nil {
 		http.Error(w, "Failed to get comments", http.StatusInternalServerError)
 		return
@@ -79,9 +96,18 @@ func (api *API) GetCommentsHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(comments)
 }
 
-func (api *API) checkCensorship(content string) bool {
+// checkCensorship проверяет текст через сервис цензуры. Запрос отменяется
+// вместе с запросом клиента и продолжает его трассировку.
+func (api *API) checkCensorship(ctx context.Context, content string) (accepted bool) {
 	client := &http.Client{}
 
+	ctx, span := tracing.Tracer().Start(ctx, "POST /censor", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPMethod(http.MethodPost), semconv.PeerService("censorship")))
+	defer func() {
+		span.SetAttributes(attribute.Bool("censorship.accepted", accepted))
+		span.End()
+	}()
+
 	// Подготавливаем тело запроса в формате JSON
 	censorshipRequest := map[string]string{
 		"text": content,
@@ -95,30 +121,45 @@ func (api *API) checkCensorship(content string) bool {
 	}
 
 	// Создаем новый запрос
-	req, err := http.NewRequest("POST", "http://localhost:8083/censor", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", "http://localhost:8083/censor", bytes.NewBuffer(jsonData))
 	if err != nil {
 		log.Printf("Error creating censorship request: %v", err)
 		return false
 	}
 
-	// Устанавливаем заголовки
+	// Устанавливаем заголовки: тип тела и контекст трассировки для сервиса цензуры
 	req.Header.Set("Content-Type", "application/json")
+	tracing.Inject(ctx, req.Header)
 
 	// Выполняем запрос к сервису цензуры
+	start := time.Now()
 	resp, err := client.Do(req)
+	censorshipDuration.Observe(time.Since(start).Seconds())
 	if err != nil {
 		log.Printf("Error calling censorship service: %v", err)
+		censorshipChecks.WithLabelValues("error").Inc()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return false
 	}
 	defer resp.Body.Close()
+	span.SetAttributes(semconv.HTTPStatusCode(resp.StatusCode))
 
-	// Если статус ответа не 200 OK, выводим ошибку и возвращаем false
+	// Если статус ответа не 200 OK, выводим ошибку и возвращаем false.
+	// 400 означает, что текст отклонён, остальные коды — ошибку сервиса
 	if resp.StatusCode != http.StatusOK {
 		body, _ := ioutil.ReadAll(resp.Body)
 		log.Printf("Censorship service returned an error: %s", body)
+		if resp.StatusCode == http.StatusBadRequest {
+			censorshipChecks.WithLabelValues("rejected").Inc()
+		} else {
+			censorshipChecks.WithLabelValues("error").Inc()
+			span.SetStatus(codes.Error, resp.Status)
+		}
 		return false
 	}
 
 	// Возвращаем true, если все в порядке
+	censorshipChecks.WithLabelValues("accepted").Inc()
 	return true
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -22,43 +23,39 @@ func InitDB() *sql.DB {
 		log.Fatalf("Failed to ping the database: %v", err)
 	}
 
-	// Создание таблицы комментариев
-	query := `
-	CREATE TABLE IF NOT EXISTS comments (
-		id SERIAL PRIMARY KEY,
-		news_id INT NOT NULL,
-		parent_id INT DEFAULT NULL,
-		content TEXT NOT NULL
-	);`
-	_, err = db.Exec(query)
-	if err != nil {
-		log.Fatalf("Failed to create comments table: %v", err)
+	// Применение миграций схемы
+	if err := applyMigrations(db); err != nil {
+		log.Fatalf("Failed to migrate the database: %v", err)
 	}
 
 	fmt.Println("Database connected and initialized successfully.")
 	return db
 }
 
-func SaveComment(db *sql.DB, comment *Comment) (int, error) {
+func SaveComment(ctx context.Context, db *sql.DB, comment *Comment) (int, error) {
+	defer observeQuery(ctx, "save_comment")()
+
 	var id int
 	query := `
 		INSERT INTO comments (news_id, parent_id, content)
 		VALUES ($1, $2, $3)
 		RETURNING id;`
-	err := db.QueryRow(query, comment.NewsID, comment.ParentID, comment.Content).Scan(&id)
+	err := db.QueryRowContext(ctx, query, comment.NewsID, comment.ParentID, comment.Content).Scan(&id)
 	if err != nil {
 		return 0, err
 	}
 	return id, nil
 }
 
-func GetCommentsByNewsID(db *sql.DB, newsID int) ([]Comment, error) {
+func GetCommentsByNewsID(ctx context.Context, db *sql.DB, newsID int) ([]Comment, error) {
+	defer observeQuery(ctx, "get_comments_by_news_id")()
+
 	query := `
 		SELECT id, news_id, parent_id, content
 		FROM comments
 		WHERE news_id = $1;`
 
-	rows, err := db.Query(query, newsID)
+	rows, err := db.QueryContext(ctx, query, newsID)
 	if err != nil {
 		return nil, err
 	}
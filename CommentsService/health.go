@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// healthCheckTimeout ограничивает время проверки базы данных.
+const healthCheckTimeout = 2 * time.Second
+
+// checkResult — результат проверки одной зависимости.
+type checkResult struct {
+	Status    string `json:"status"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// healthResponse — ответ /healthz и /readyz.
+type healthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks,omitempty"`
+}
+
+// HealthzHandler сообщает, что процесс жив и обрабатывает запросы.
+func (api *API) HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, healthResponse{Status: "ok"})
+}
+
+// ReadyzHandler сообщает, готов ли сервис принимать запросы: база данных должна отвечать.
+func (api *API) ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	check := checkResult{Status: "ok"}
+	if err := api.db.PingContext(ctx); err != nil {
+		log.Printf("Database is not available: %v", err)
+		check.Status = "unavailable"
+		check.Error = err.Error()
+	}
+	check.LatencyMS = time.Since(start).Milliseconds()
+
+	response := healthResponse{Status: check.Status, Checks: map[string]checkResult{"database": check}}
+	writeHealth(w, response)
+}
+
+// writeHealth отправляет результат проверки: 200, если всё в порядке, иначе 503.
+func writeHealth(w http.ResponseWriter, response healthResponse) {
+	code := http.StatusOK
+	if response.Status != "ok" {
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(response)
+}
@@ -1,13 +1,34 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"Task36a41/pkg/tracing"
 
 	"github.com/gorilla/mux"
 )
 
+// shutdownTimeout — сколько ждать завершения обрабатываемых запросов при остановке.
+const shutdownTimeout = 10 * time.Second
+
 func main() {
+	// SIGINT и SIGTERM отменяют контекст и запускают остановку сервера
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Трассировка: экспортёр задаётся переменными окружения OTEL_*
+	shutdownTracing, err := tracing.Init(ctx, "comments")
+	if err != nil {
+		log.Fatalf("Error initializing tracing: %v", err)
+	}
+
 	// Инициализация базы данных PostgreSQL
 	db := InitDB()
 	defer db.Close()
@@ -20,6 +41,26 @@ func main() {
 	api.RegisterRoutes(router)
 
 	// Запуск HTTP-сервера
-	log.Println("Starting comments service on :8081...")
-	log.Fatal(http.ListenAndServe(":8081", router))
+	server := &http.Server{Addr: ":8081", Handler: router}
+	go func() {
+		log.Println("Starting comments service on :8081...")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Error starting server: %v", err)
+		}
+	}()
+
+	// Перестаём принимать запросы и дожидаемся текущих, после этого закрывается БД
+	<-ctx.Done()
+	stop() // Повторный сигнал завершит процесс сразу
+	log.Println("Shutting down comments service...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down server: %v", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Printf("Error shutting down tracing: %v", err)
+	}
+	log.Println("Comments service stopped")
 }
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Task36a41/pkg/tracing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Метрики сервиса комментариев в формате Prometheus, отдаются на /metrics.
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "comments_http_requests_total",
+		Help: "Количество обработанных HTTP-запросов по маршруту, методу и коду ответа.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "comments_http_request_duration_seconds",
+		Help:    "Время обработки HTTP-запросов по маршруту, методу и коду ответа.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	dbQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "comments_db_query_duration_seconds",
+		Help:    "Время выполнения запросов к базе данных.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"query"})
+
+	censorshipChecks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "comments_censorship_checks_total",
+		Help: "Результаты проверки комментариев сервисом цензуры: accepted, rejected или error.",
+	}, []string{"result"})
+
+	censorshipDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "comments_censorship_request_duration_seconds",
+		Help:    "Время ответа сервиса цензуры.",
+		Buckets: prometheus.DefBuckets,
+	})
+)
+
+// observeQuery начинает замер и спан запроса к базе данных и возвращает функцию, которая их завершает.
+func observeQuery(ctx context.Context, query string) func() {
+	start := time.Now()
+	_, span := tracing.Tracer().Start(ctx, "db "+query,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperation(query)))
+	return func() {
+		span.End()
+		dbQueryDuration.WithLabelValues(query).Observe(time.Since(start).Seconds())
+	}
+}
+
+// statusRecorder запоминает код ответа для метрик.
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (rw *statusRecorder) WriteHeader(code int) {
+	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+// MetricsMiddleware считает запросы и время их обработки по шаблону маршрута.
+func MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(rw, r)
+
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		status := strconv.Itoa(rw.statusCode)
+		httpRequests.WithLabelValues(route, r.Method, status).Inc()
+		httpDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+	})
+}
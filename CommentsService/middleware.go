@@ -1,45 +1,20 @@
 package main
 
 import (
-	"context"
 	"log"
 	"net/http"
 	"time"
 
-	"github.com/google/uuid"
+	"Task36a41/pkg/tracing"
 )
 
-// RequestIDMiddleware генерирует или извлекает request_id для каждого запроса.
-func RequestIDMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Проверяем, передан ли request_id в заголовке
-		requestID := r.Header.Get("X-Request-ID")
-		if requestID == "" {
-			// Если request_id нет, генерируем новый
-			requestID = uuid.New().String()
-		}
-
-		// Добавляем request_id в контекст запроса
-		ctx := context.WithValue(r.Context(), "request_id", requestID)
-
-		// Устанавливаем request_id в заголовок ответа
-		w.Header().Set("X-Request-ID", requestID)
-
-		// Передаем обработку запроса дальше
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
-}
-
 // LoggingMiddleware логирует все запросы, включая информацию о запросе.
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
-		// Извлекаем request_id из контекста
-		requestID := r.Context().Value("request_id")
-		if requestID == nil {
-			requestID = "unknown"
-		}
+		// Идентификатор запроса — идентификатор трассировки из tracing.Middleware
+		requestID := tracing.TraceID(r.Context())
 
 		// Логируем запрос
 		log.Printf("IP: %s, Method: %s, URL: %s, Request ID: %s, Started at: %s", r.RemoteAddr, r.Method, r.URL.Path, requestID, start.Format(time.RFC3339))
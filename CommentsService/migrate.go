@@ -0,0 +1,28 @@
+package main
+
+import (
+	"database/sql"
+	"embed"
+
+	"Task36a41/pkg/migrate"
+)
+
+// Миграции схемы встроены в бинарный файл и применяются тем же пакетом migrate,
+// что и в сервисе новостей. schema.sql разворачивает их вручную и записывает
+// версии в schema_migrations.
+//
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// migrationLockID — ключ advisory-блокировки, чтобы несколько экземпляров
+// сервиса не применяли миграции одновременно.
+const migrationLockID = 36042
+
+// applyMigrations применяет к базе данных ещё не применённые миграции.
+func applyMigrations(db *sql.DB) error {
+	migrations, err := migrate.Load(migrationsFS, "migrations")
+	if err != nil {
+		return err
+	}
+	return migrate.Apply(db, migrations, migrationLockID)
+}
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS comments (
+    id SERIAL PRIMARY KEY,
+    news_id INT NOT NULL,
+    parent_id INT DEFAULT NULL,
+    content TEXT NOT NULL
+);
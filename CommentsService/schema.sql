@@ -1,7 +1,18 @@
-DROP TABLE IF EXISTS comments;
-CREATE TABLE IF NOT EXISTS comments (
-		id SERIAL PRIMARY KEY,
-		news_id INT NOT NULL,
-		parent_id INT DEFAULT NULL,
-		content TEXT NOT NULL
-	);
\ No newline at end of file
+-- Схема БД сервиса комментариев собирается из миграций в каталоге migrations.
+-- Сервер применяет их автоматически при запуске и отмечает в таблице schema_migrations.
+-- Этот файл разворачивает ту же схему на пустой базе вручную: psql -f schema.sql.
+-- Версии миграций записываются в schema_migrations, как это делает сервер,
+-- поэтому при запуске он не применяет их повторно.
+\set ON_ERROR_STOP on
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INT PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+\ir migrations/0001_create_comments.sql
+INSERT INTO schema_migrations (version, name) VALUES (1, '0001_create_comments.sql');
+
+COMMIT;
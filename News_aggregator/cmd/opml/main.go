@@ -0,0 +1,137 @@
+// Команда opml импортирует и экспортирует список источников агрегатора в формате OPML.
+//
+//	opml [-config config.json] export [-o subscriptions.opml]
+//	opml [-config config.json] import subscriptions.opml
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"Task36a41/pkg/config"
+	"Task36a41/pkg/opml"
+	"Task36a41/pkg/storage"
+)
+
+// exportTitle — заголовок выгружаемого документа.
+const exportTitle = "News aggregator subscriptions"
+
+func main() {
+	configPath := flag.String("config", "config.json", "путь к файлу конфигурации")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage:\n  %[1]s [-config file] export [-o file]\n  %[1]s [-config file] import file\n\nFlags:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+
+	switch command, args := flag.Arg(0), flag.Args()[1:]; command {
+	case "export":
+		err = runExport(cfg, args)
+	case "import":
+		err = runImport(cfg, args)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// runExport выгружает источники из базы данных. Если список источников ещё
+// не заполнен сервером, выгружаются ленты из конфигурации.
+func runExport(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	output := fs.String("o", "", "файл для записи (по умолчанию стандартный вывод)")
+	fs.Parse(args)
+
+	var doc *opml.OPML
+	if cfg.Storage == config.StorageMemory {
+		doc = configOPML(cfg)
+	} else {
+		db, err := storage.New(cfg.DatabaseURL)
+		if err != nil {
+			return fmt.Errorf("error connecting to database: %v", err)
+		}
+		defer db.Close()
+
+		if doc, err = opml.Export(context.Background(), db, exportTitle, time.Now()); err != nil {
+			return fmt.Errorf("error exporting sources: %v", err)
+		}
+		if len(doc.Body.Outlines) == 0 {
+			doc = configOPML(cfg)
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return doc.Write(w)
+}
+
+// configOPML создаёт документ из лент, перечисленных в конфигурации.
+func configOPML(cfg *config.Config) *opml.OPML {
+	feeds := make([]opml.Feed, 0, len(cfg.RSS))
+	for _, url := range cfg.RSS {
+		feeds = append(feeds, opml.Feed{URL: url})
+	}
+	return opml.New(exportTitle, feeds, time.Now())
+}
+
+// runImport добавляет источники из файла OPML в базу данных.
+// Работающий сервер начнёт их опрашивать на ближайшем шаге планировщика.
+func runImport(cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("import expects exactly one OPML file")
+	}
+	if cfg.Storage == config.StorageMemory {
+		return fmt.Errorf("import requires %q storage: in-memory sources live only inside the server", config.StoragePostgres)
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	doc, err := opml.Parse(f)
+	if err != nil {
+		return err
+	}
+
+	db, err := storage.New(cfg.DatabaseURL)
+	if err != nil {
+		return fmt.Errorf("error connecting to database: %v", err)
+	}
+	defer db.Close()
+
+	report, err := opml.Import(context.Background(), db, doc)
+	if err != nil {
+		return fmt.Errorf("error importing sources: %v", err)
+	}
+	for _, skipped := range report.Skipped {
+		log.Printf("Skipped %s: %s", skipped.URL, skipped.Reason)
+	}
+	log.Printf("Imported: added=%d existing=%d skipped=%d", report.Added, report.Existing, len(report.Skipped))
+	return nil
+}
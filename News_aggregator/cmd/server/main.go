@@ -1,76 +1,144 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"Task36a41/pkg/api"
 	"Task36a41/pkg/config"
-	"Task36a41/pkg/rss"
+	"Task36a41/pkg/metrics"
+	"Task36a41/pkg/scheduler"
 	"Task36a41/pkg/storage"
+	"Task36a41/pkg/tracing"
 
-	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
-// Middleware для генерации и логирования request_id
-func requestIDMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		requestID := r.URL.Query().Get("request_id")
-		if requestID == "" {
-			requestID = uuid.NewString()
-		}
-		r.Header.Set("X-Request-ID", requestID)
+// shutdownTimeout — сколько ждать завершения обрабатываемых запросов при остановке.
+const shutdownTimeout = 10 * time.Second
 
-		next.ServeHTTP(w, r)
+// seedSources добавляет ленты из конфигурации в пустой список источников
+// с индивидуальными интервалами опроса из feed_intervals.
+func seedSources(ctx context.Context, db storage.SourceStore, cfg *config.Config) error {
+	sources, err := db.GetSources(ctx)
+	if err != nil {
+		return err
+	}
+	if len(sources) > 0 {
+		return nil
+	}
 
-		log.Printf("Request ID: %s, IP: %s, Method: %s, URL: %s, Status: %d",
-			requestID, r.RemoteAddr, r.Method, r.URL.Path, http.StatusOK)
-	})
+	for _, url := range cfg.RSS {
+		source := storage.Source{URL: url, Enabled: true, PollInterval: cfg.FeedIntervals[url]}
+		if _, err := db.AddSource(ctx, source); err != nil && err != storage.ErrSourceExists {
+			return err
+		}
+	}
+	log.Printf("Added %d sources from config", len(cfg.RSS))
+	return nil
 }
 
 func main() {
+	// SIGINT и SIGTERM отменяют контекст: сервер перестаёт принимать запросы,
+	// дожидается текущих, планировщик прерывает загрузки, затем закрывается БД
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Загружаем конфигурацию
 	cfg, err := config.LoadConfig("config.json")
 	if err != nil {
 		log.Fatalf("Error loading config: %v", err)
 	}
 
-	// Инициализируем подключение к базе данных
-	db, err := storage.New(cfg.DatabaseURL)
+	// Трассировка: экспортёр задаётся переменными окружения OTEL_*
+	shutdownTracing, err := tracing.Init(ctx, "news")
 	if err != nil {
-		log.Fatalf("Error connecting to database: %v", err)
+		log.Fatalf("Error initializing tracing: %v", err)
+	}
+
+	// Инициализируем хранилище: PostgreSQL или память, в зависимости от конфигурации
+	var db storage.Interface
+	if cfg.Storage == config.StorageMemory {
+		log.Println("Using in-memory storage, collected news will be lost on restart")
+		db = storage.NewMemory()
+	} else {
+		db, err = storage.New(cfg.DatabaseURL)
+		if err != nil {
+			log.Fatalf("Error connecting to database: %v", err)
+		}
 	}
 	defer db.Close()
 
-	// Создаем API
+	// Создаем API. Маршруты /admin требуют токена администратора
 	apiService := api.New(db)
+	apiService.SetAdminToken(cfg.AdminToken)
+	if cfg.AdminToken == "" {
+		log.Println("Admin token is not configured, /admin endpoints are disabled")
+	}
+	if err := apiService.SetPublicURL(cfg.PublicURL); err != nil {
+		log.Fatalf("Error in config: %v", err)
+	}
 
-	// Загружаем RSS-фиды каждые RequestPeriod минут
+	// При первом запуске заполняем список источников лентами из конфигурации,
+	// дальше им управляют через /admin/sources
+	if err := seedSources(ctx, db, cfg); err != nil {
+		log.Fatalf("Error seeding sources: %v", err)
+	}
+
+	// Опрашиваем каждый включённый источник по его собственному расписанию.
+	// ETag и Last-Modified хранятся в БД, поэтому неизменившиеся ленты не скачиваются повторно.
+	// О новых публикациях планировщик сообщает потокам /news/stream.
+	poller := scheduler.New(db, time.Duration(cfg.RequestPeriod)*time.Minute)
+	poller.OnNewPosts(apiService.NotifyNewPosts)
+	pollerDone := make(chan struct{})
 	go func() {
-		for {
-			posts := rss.FetchAllRSS(cfg.RSS)
-			if err := db.SavePosts(posts); err != nil {
-				log.Println("Error saving posts:", err)
-			}
-			time.Sleep(time.Duration(cfg.RequestPeriod) * time.Minute)
-		}
+		poller.Run(ctx)
+		close(pollerDone)
 	}()
 
 	// Настраиваем маршрутизатор и регистрируем маршруты
 	router := mux.NewRouter()
-	router.Use(requestIDMiddleware) // Добавляем middleware
 	apiService.RegisterRoutes(router)
 
+	// Метрики в формате Prometheus
+	router.Handle("/metrics", metrics.Handler()).Methods(http.MethodGet)
+
 	// Добавляем обработчик для статических файлов фронтенда
 	router.PathPrefix("/").Handler(http.StripPrefix("/", http.FileServer(http.Dir("./webapp"))))
 
-	// Запускаем сервер
+	// Запускаем сервер. Потоки /news/stream закрываются при остановке,
+	// иначе Shutdown ждал бы их до истечения таймаута
 	port := cfg.ServerPort
-	fmt.Printf("Server running on port %d\n", port)
-	if err := http.ListenAndServe(fmt.Sprintf(":%d", port), router); err != nil {
-		log.Fatalf("Error starting server: %v", err)
+	server := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: router}
+	server.RegisterOnShutdown(apiService.CloseStreams)
+	go func() {
+		fmt.Printf("Server running on port %d\n", port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Error starting server: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop() // Повторный сигнал завершит процесс сразу
+	log.Println("Shutting down server...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down server: %v", err)
+	}
+	<-pollerDone
+
+	// Выгружаем оставшиеся спаны
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Printf("Error shutting down tracing: %v", err)
 	}
+	log.Println("Server stopped")
 }
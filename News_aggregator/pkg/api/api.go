@@ -3,41 +3,65 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
+	"Task36a41/pkg/metrics"
+	"Task36a41/pkg/rss"
 	"Task36a41/pkg/storage"
+	"Task36a41/pkg/tracing"
 
-	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
 // API представляет структуру для API с доступом к хранилищу данных.
 type API struct {
-	storage *storage.Storage
+	storage    storage.Interface
+	discover   func(url string) ([]rss.Candidate, error) // Поиск лент по адресу сайта
+	events     *broker                                   // Оповещения потоков /news/stream о новых публикациях
+	adminToken string                                    // Токен доступа к /admin; пустой отключает эти маршруты
+	publicURL  *url.URL                                  // Внешний адрес для ссылок в выходных лентах; nil — адрес запроса
 }
 
-// New создает новый экземпляр API.
-func New(storage *storage.Storage) *API {
-	return &API{storage: storage}
+// New создает новый экземпляр API поверх любой реализации хранилища.
+func New(storage storage.Interface) *API {
+	return &API{storage: storage, discover: rss.Discover, events: newBroker()}
 }
 
 // RegisterRoutes регистрирует маршруты API.
 func (api *API) RegisterRoutes(router *mux.Router) {
-	// Middleware для request_id и логирования
-	router.Use(RequestIDMiddleware)
+	// Middleware для трассировки и логирования. Идентификатор запроса
+	// в журнале — идентификатор трассировки
+	router.Use(tracing.Middleware)
 	router.Use(LoggingMiddleware)
 
 	// Регистрация маршрутов
 	router.HandleFunc("/news/details", api.getNewsDetails).Methods(http.MethodGet)   // Обработчик деталей новости
+	router.HandleFunc("/news/stream", api.streamNews).Methods(http.MethodGet)        // Поток новых публикаций (SSE)
 	router.HandleFunc("/news/{n:[0-9]+}", api.getLastNPosts).Methods(http.MethodGet) // Ограничение для {n} только числами
 	router.HandleFunc("/news", api.getNews).Methods(http.MethodGet)                  // Уже существующий маршрут
+	router.HandleFunc("/feeds", api.getFeeds).Methods(http.MethodGet)                // Состояние опроса лент
+
+	// Проверки живости и готовности
+	api.registerHealthRoutes(router)
+
+	// Выходные ленты RSS и Atom
+	api.registerFeedRoutes(router)
+
+	// Управление источниками
+	api.registerSourceRoutes(router)
 }
 
+// getLastNPosts возвращает последние n публикаций массивом JSON.
+// С параметром cursor (для первой порции — пустым: /news/10?cursor=) ответ содержит
+// публикации и курсоры соседних порций: по ним следующая или предыдущая порция
+// выбирается без пропусков и повторов.
 func (api *API) getLastNPosts(w http.ResponseWriter, r *http.Request) {
-	requestID := r.Context().Value("request_id")
+	requestID := tracing.TraceID(r.Context())
 	log.Printf("[Request ID: %s] Processing getLastNPosts", requestID)
 
 	vars := mux.Vars(r)
@@ -47,27 +71,108 @@ func (api *API) getLastNPosts(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid number format", http.StatusBadRequest)
 		return
 	}
+	// Как и per_page в /news, размер страницы ограничен: иначе /news/1000000000
+	// выгрузил бы всю таблицу
+	n = min(n, maxItemsPerPage)
+
+	filter := storage.PostFilter{Sort: storage.SortNewest}
+	if filter.Cursor, err = parseCursor(r.URL.Query()); err != nil {
+		log.Printf("[Request ID: %s] %v", requestID, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Получаем последние N постов из хранилища
-	posts, err := api.storage.GetLastNPosts(n)
+	posts, _, cursors, err := api.fetchPage(r.Context(), filter, n)
 	if err != nil {
 		log.Printf("[Request ID: %s] Error retrieving posts: %v", requestID, err)
 		http.Error(w, "Error retrieving posts", http.StatusInternalServerError)
 		return
 	}
 
+	// Без курсора ответ остаётся массивом, как до появления курсоров
+	var response interface{} = posts
+	if r.URL.Query().Has("cursor") {
+		response = map[string]interface{}{
+			"posts":       posts,
+			"next_cursor": cursors.Next,
+			"prev_cursor": cursors.Prev,
+		}
+	}
+
 	// Отправляем ответ
 	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(posts); err != nil {
+	if err := json.NewEncoder(w).Encode(response); err != nil {
 		log.Printf("[Request ID: %s] Error encoding response: %v", requestID, err)
 		http.Error(w, "Error encoding response", http.StatusInternalServerError)
 	}
 }
 
+// pageCursors — курсоры соседних страниц; пустая строка означает, что страницы нет.
+type pageCursors struct {
+	Next string
+	Prev string
+}
+
+// parseCursor извлекает курсор из параметра cursor, если он передан.
+func parseCursor(values url.Values) (*storage.Cursor, error) {
+	value := values.Get("cursor")
+	if value == "" {
+		return nil, nil
+	}
+	cursor, err := storage.DecodeCursor(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'cursor' parameter: %v", err)
+	}
+	return cursor, nil
+}
+
+// fetchPage выбирает страницу из perPage публикаций по фильтру и вычисляет курсоры
+// соседних страниц. Из хранилища запрашивается на одну публикацию больше,
+// чтобы узнать, есть ли что-то за пределами страницы. Для сортировки по
+// релевантности курсоры не формируются.
+func (api *API) fetchPage(ctx context.Context, filter storage.PostFilter, perPage int) ([]rss.Post, int, pageCursors, error) {
+	var cursors pageCursors
+
+	filter.Limit = perPage + 1
+	posts, total, err := api.storage.SearchPosts(ctx, filter)
+	if err != nil {
+		return nil, 0, cursors, err
+	}
+
+	backward := filter.Cursor != nil && filter.Cursor.Backward
+	more := len(posts) > perPage
+	if more {
+		if backward {
+			posts = posts[len(posts)-perPage:]
+		} else {
+			posts = posts[:perPage]
+		}
+	}
+
+	order := filter.SortOrder()
+	if order == storage.SortRelevance || len(posts) == 0 {
+		return posts, total, cursors, nil
+	}
+
+	hasNext, hasPrev := more, filter.Offset > 0
+	if filter.Cursor != nil {
+		// Курсор всегда указывает на существующую соседнюю страницу с той стороны, откуда пришли
+		hasNext, hasPrev = more || backward, !backward || more
+	}
+	if hasNext {
+		cursors.Next = storage.CursorAfter(posts[len(posts)-1], order)
+	}
+	if hasPrev {
+		cursors.Prev = storage.CursorBefore(posts[0], order)
+	}
+	return posts, total, cursors, nil
+}
+
 // getNewsDetails обрабатывает запрос для получения деталей новости по ID.
 func (api *API) getNewsDetails(w http.ResponseWriter, r *http.Request) {
 	// Извлекаем request_id из контекста для логирования
-	requestID := r.Context().Value("request_id")
+	requestID := tracing.TraceID(r.Context())
 	log.Printf("[Request ID: %s] Processing getNewsDetails", requestID)
 
 	// Получаем параметр id из строки запроса
@@ -87,7 +192,7 @@ func (api *API) getNewsDetails(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Получаем новость из хранилища по ID
-	post, err := api.storage.GetPostByID(id)
+	post, err := api.storage.GetPostByID(r.Context(), id)
 	if err != nil {
 		log.Printf("[Request ID: %s] Error retrieving post with ID %d: %v", requestID, id, err)
 		http.Error(w, "Error retrieving post", http.StatusInternalServerError)
@@ -109,13 +214,91 @@ func (api *API) getNewsDetails(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// getNews обрабатывает запрос для поиска новостей.
+// Размер страницы в /news.
+const (
+	defaultItemsPerPage = 15  // Если параметр per_page не передан
+	maxItemsPerPage     = 100 // Верхняя граница per_page
+)
+
+// parseFilter извлекает из параметров запроса фильтр публикаций:
+// s — поисковый запрос, source — URL ленты, from и to — границы даты публикации
+// (RFC 3339 или ГГГГ-ММ-ДД), sort — newest, oldest или relevance.
+func parseFilter(values url.Values) (storage.PostFilter, error) {
+	filter := storage.PostFilter{
+		Query:  values.Get("s"),
+		Source: values.Get("source"),
+		Sort:   values.Get("sort"),
+	}
+
+	switch filter.Sort {
+	case "", storage.SortNewest, storage.SortOldest, storage.SortRelevance:
+	default:
+		return filter, fmt.Errorf("invalid 'sort' parameter: must be one of %s, %s, %s",
+			storage.SortNewest, storage.SortOldest, storage.SortRelevance)
+	}
+
+	var err error
+	if collapse := values.Get("collapse"); collapse != "" {
+		if filter.Collapse, err = strconv.ParseBool(collapse); err != nil {
+			return filter, fmt.Errorf("invalid 'collapse' parameter: must be true or false")
+		}
+	}
+	if filter.From, err = parseTimeParam(values.Get("from"), false); err != nil {
+		return filter, fmt.Errorf("invalid 'from' parameter: %v", err)
+	}
+	if filter.To, err = parseTimeParam(values.Get("to"), true); err != nil {
+		return filter, fmt.Errorf("invalid 'to' parameter: %v", err)
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.From.After(filter.To) {
+		return filter, fmt.Errorf("'from' must not be after 'to'")
+	}
+
+	return filter, nil
+}
+
+// parseTimeParam разбирает время в формате RFC 3339 или дату ГГГГ-ММ-ДД (UTC).
+// Для верхней границы дата без времени означает конец этого дня.
+func parseTimeParam(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected RFC 3339 time or YYYY-MM-DD date, got %q", value)
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Second)
+	}
+	return t, nil
+}
+
+// getNews обрабатывает запрос для полнотекстового поиска новостей.
+// Параметр s поддерживает фразы в кавычках и исключение слов через минус.
+// Выборку можно ограничить параметрами source, from и to, упорядочить параметром sort
+// и разбить на страницы параметрами page и per_page. Вместо page можно передать
+// cursor из next_cursor или prev_cursor прошлого ответа: курсор задаёт и порядок сортировки.
+// С collapse=true почти одинаковые публикации из разных лент сворачиваются в одну,
+// а остальные возвращаются в её поле alternates.
 func (api *API) getNews(w http.ResponseWriter, r *http.Request) {
-	requestID := r.Context().Value("request_id")
+	requestID := tracing.TraceID(r.Context())
 	log.Printf("[Request ID: %s] Processing getNews", requestID)
 
-	query := r.URL.Query().Get("s")
-	log.Printf("[Request ID: %s] Search query: %s", requestID, query)
+	filter, err := parseFilter(r.URL.Query())
+	if err != nil {
+		log.Printf("[Request ID: %s] %v", requestID, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("[Request ID: %s] Search query: %s, source: %s, sort: %s", requestID, filter.Query, filter.Source, filter.Sort)
+
+	if filter.Cursor, err = parseCursor(r.URL.Query()); err != nil {
+		log.Printf("[Request ID: %s] %v", requestID, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	pageStr := r.URL.Query().Get("page")
 	page := 1
@@ -124,12 +307,27 @@ func (api *API) getNews(w http.ResponseWriter, r *http.Request) {
 			page = p
 		}
 	}
-	log.Printf("[Request ID: %s] Pagination page: %d", requestID, page)
 
-	itemsPerPage := 15
-	offset := (page - 1) * itemsPerPage
+	itemsPerPage := defaultItemsPerPage
+	if perPageStr := r.URL.Query().Get("per_page"); perPageStr != "" {
+		perPage, err := strconv.Atoi(perPageStr)
+		if err != nil || perPage <= 0 {
+			log.Printf("[Request ID: %s] Invalid 'per_page' parameter: %s", requestID, perPageStr)
+			http.Error(w, "Invalid 'per_page' parameter: must be a positive number", http.StatusBadRequest)
+			return
+		}
+		itemsPerPage = perPage
+		if itemsPerPage > maxItemsPerPage {
+			itemsPerPage = maxItemsPerPage
+		}
+	}
+	log.Printf("[Request ID: %s] Pagination page: %d, per page: %d", requestID, page, itemsPerPage)
+
+	if filter.Cursor == nil {
+		filter.Offset = (page - 1) * itemsPerPage
+	}
 
-	posts, total, err := api.storage.SearchPostsByTitle(r.Context(), query, itemsPerPage, offset)
+	posts, total, cursors, err := api.fetchPage(r.Context(), filter, itemsPerPage)
 	if err != nil {
 		log.Printf("[Request ID: %s] Error retrieving posts: %v", requestID, err)
 		http.Error(w, "Error retrieving posts", http.StatusInternalServerError)
@@ -140,14 +338,20 @@ func (api *API) getNews(w http.ResponseWriter, r *http.Request) {
 	log.Printf("[Request ID: %s] Retrieved %d posts (page %d of %d)", requestID, len(posts), page, totalPages)
 
 	pagination := map[string]int{
-		"current_page":   page,
 		"total_pages":    totalPages,
 		"items_per_page": itemsPerPage,
+		"total_items":    total,
+	}
+	// При выборке по курсору номер страницы не определён
+	if filter.Cursor == nil {
+		pagination["current_page"] = page
 	}
 
 	response := map[string]interface{}{
-		"posts":      posts,
-		"pagination": pagination,
+		"posts":       posts,
+		"pagination":  pagination,
+		"next_cursor": cursors.Next,
+		"prev_cursor": cursors.Prev,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -157,28 +361,24 @@ func (api *API) getNews(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// RequestIDMiddleware генерирует или извлекает request_id для каждого запроса.
-func RequestIDMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Проверяем, передан ли request_id в заголовке
-		requestID := r.Header.Get("X-Request-ID")
-		if requestID == "" {
-			// Если request_id нет, генерируем новый
-			requestID = uuid.New().String()
-			log.Printf("Generated new Request ID: %s", requestID)
-		} else {
-			log.Printf("Using provided Request ID: %s", requestID)
-		}
-
-		// Добавляем request_id в контекст запроса
-		ctx := context.WithValue(r.Context(), "request_id", requestID)
+// getFeeds возвращает состояние опроса всех лент: время последнего успеха,
+// последнюю ошибку, количество ошибок подряд и количество публикаций.
+func (api *API) getFeeds(w http.ResponseWriter, r *http.Request) {
+	requestID := tracing.TraceID(r.Context())
+	log.Printf("[Request ID: %s] Processing getFeeds", requestID)
 
-		// Устанавливаем request_id в заголовок ответа
-		w.Header().Set("X-Request-ID", requestID)
+	feeds, err := api.storage.GetFeedStatuses(r.Context())
+	if err != nil {
+		log.Printf("[Request ID: %s] Error retrieving feeds: %v", requestID, err)
+		http.Error(w, "Error retrieving feeds", http.StatusInternalServerError)
+		return
+	}
 
-		// Передаем обработку запроса дальше
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(feeds); err != nil {
+		log.Printf("[Request ID: %s] Error encoding response: %v", requestID, err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+	}
 }
 
 // LoggingMiddleware логирует все запросы, включая информацию о запросе.
@@ -186,11 +386,8 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
-		// Извлекаем request_id из контекста
-		requestID := r.Context().Value("request_id")
-		if requestID == nil {
-			requestID = "unknown"
-		}
+		// Идентификатор запроса — идентификатор трассировки из tracing.Middleware
+		requestID := tracing.TraceID(r.Context())
 
 		log.Printf("[Request ID: %s] Incoming request: %s %s from %s", requestID, r.Method, r.URL.Path, r.RemoteAddr)
 
@@ -203,6 +400,15 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 		duration := time.Since(start)
 		log.Printf("[Request ID: %s] Completed request: %s %s with status %d in %v",
 			requestID, r.Method, r.URL.Path, rw.statusCode, duration)
+
+		// Метрики группируются по шаблону маршрута, например /news/{n}
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		metrics.ObserveRequest(route, r.Method, rw.statusCode, duration)
 	})
 }
 
@@ -216,3 +422,9 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.statusCode = code
 	rw.ResponseWriter.WriteHeader(code)
 }
+
+// Unwrap открывает исходный ResponseWriter для http.ResponseController,
+// чтобы потоковые ответы можно было сбрасывать клиенту.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
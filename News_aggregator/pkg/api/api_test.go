@@ -0,0 +1,365 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"Task36a41/pkg/rss"
+	"Task36a41/pkg/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// testAdminToken — токен администратора тестового маршрутизатора; doRequest
+// и doJSONRequest отправляют его с каждым запросом.
+const testAdminToken = "test-admin-token"
+
+// newTestRouter создаёт маршрутизатор API поверх хранилища в памяти с тестовыми публикациями.
+func newTestRouter(t *testing.T, count int) (*mux.Router, *storage.Memory) {
+	t.Helper()
+	db := storage.NewMemory()
+	base := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+
+	var posts []rss.Post
+	for i := 1; i <= count; i++ {
+		posts = append(posts, rss.Post{
+			Title:   fmt.Sprintf("Go news #%d", i),
+			Content: fmt.Sprintf("Content #%d", i),
+			PubDate: base.Add(time.Duration(i) * time.Minute).Format(time.RFC1123Z),
+			Link:    fmt.Sprintf("http://example.com/%d", i),
+		})
+	}
+	posts = append(posts, rss.Post{
+		Title:   "Python release",
+		PubDate: base.Format(time.RFC1123Z),
+		Link:    "http://example.com/python",
+	})
+	if _, err := db.SavePosts(context.Background(), posts); err != nil {
+		t.Fatalf("Error saving posts: %v", err)
+	}
+
+	// Адреса источников в тестах считаются лентами, без обращения к сети
+	api := New(db)
+	api.SetAdminToken(testAdminToken)
+	api.discover = func(url string) ([]rss.Candidate, error) {
+		return []rss.Candidate{{URL: url}}, nil
+	}
+
+	router := mux.NewRouter()
+	api.RegisterRoutes(router)
+	return router, db
+}
+
+// doRequest выполняет запрос к маршрутизатору и декодирует JSON-ответ.
+func doRequest(t *testing.T, router http.Handler, target string, out interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	router.ServeHTTP(rec, req)
+	if out != nil && rec.Code == http.StatusOK {
+		if err := json.NewDecoder(rec.Body).Decode(out); err != nil {
+			t.Fatalf("Error decoding response of %s: %v", target, err)
+		}
+	}
+	return rec
+}
+
+func TestGetLastNPosts(t *testing.T) {
+	router, _ := newTestRouter(t, 5)
+
+	// Без курсора ответ — массив публикаций, как раньше
+	var posts []rss.Post
+	rec := doRequest(t, router, "/news/3", &posts)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if titles := postTitles(posts); titles != "Go news #5, Go news #4, Go news #3" {
+		t.Errorf("Unexpected posts: %s", titles)
+	}
+
+	// Пустой курсор запрашивает первую порцию вместе с курсорами
+	var page newsPage
+	doRequest(t, router, "/news/3?cursor=", &page)
+	if len(page.Posts) != 3 || page.Posts[0].Title != "Go news #5" {
+		t.Errorf("Unexpected posts: %+v", page.Posts)
+	}
+	if page.NextCursor == "" || page.PrevCursor != "" {
+		t.Errorf("Unexpected cursors: next %q, prev %q", page.NextCursor, page.PrevCursor)
+	}
+
+	// Следующая порция по курсору продолжает ленту без повторов
+	var next newsPage
+	doRequest(t, router, "/news/3?cursor="+page.NextCursor, &next)
+	if titles := postTitles(next.Posts); titles != "Go news #2, Go news #1, Python release" {
+		t.Errorf("Unexpected next page: %s", titles)
+	}
+	if next.NextCursor != "" || next.PrevCursor == "" {
+		t.Errorf("Unexpected cursors: next %q, prev %q", next.NextCursor, next.PrevCursor)
+	}
+}
+
+func TestRequestIDFromTraceparent(t *testing.T) {
+	router, _ := newTestRouter(t, 1)
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	// Идентификатор запроса в журнале — идентификатор трассировки из traceparent,
+	// X-Request-ID не используется
+	req := httptest.NewRequest(http.MethodGet, "/news/1", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set("X-Request-ID", "client-id")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if !strings.Contains(logs.String(), "[Request ID: 4bf92f3577b34da6a3ce929d0e0e4736] Processing getLastNPosts") {
+		t.Errorf("Expected trace ID as request ID in logs:\n%s", logs.String())
+	}
+	if strings.Contains(logs.String(), "client-id") || rec.Header().Get("X-Request-ID") != "" {
+		t.Errorf("Expected X-Request-ID to be ignored, logs:\n%s", logs.String())
+	}
+}
+
+func TestGetLastNPostsLimit(t *testing.T) {
+	router, _ := newTestRouter(t, maxItemsPerPage+5)
+
+	for _, target := range []string{"/news/1000000000", "/news/9223372036854775807"} {
+		var posts []rss.Post
+		if rec := doRequest(t, router, target, &posts); rec.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 for %s, got %d", target, rec.Code)
+		}
+		if len(posts) != maxItemsPerPage {
+			t.Errorf("Expected %d posts for %s, got %d", maxItemsPerPage, target, len(posts))
+		}
+	}
+}
+
+// newsPage — ответ списков новостей с курсорами.
+type newsPage struct {
+	Posts      []rss.Post     `json:"posts"`
+	Pagination map[string]int `json:"pagination"`
+	NextCursor string         `json:"next_cursor"`
+	PrevCursor string         `json:"prev_cursor"`
+}
+
+// postTitles перечисляет заголовки публикаций через запятую.
+func postTitles(posts []rss.Post) string {
+	var titles []string
+	for _, post := range posts {
+		titles = append(titles, post.Title)
+	}
+	return strings.Join(titles, ", ")
+}
+
+func TestGetNewsCursor(t *testing.T) {
+	router, db := newTestRouter(t, 5)
+
+	var first newsPage
+	doRequest(t, router, "/news?per_page=2", &first)
+	if titles := postTitles(first.Posts); titles != "Go news #5, Go news #4" {
+		t.Fatalf("Unexpected first page: %s", titles)
+	}
+
+	// Новая публикация, появившаяся во время просмотра, не сдвигает следующую страницу
+	if _, err := db.SavePosts(context.Background(), []rss.Post{{
+		Title:   "Go news #6",
+		PubDate: time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC).Format(time.RFC1123Z),
+		Link:    "http://example.com/6",
+	}}); err != nil {
+		t.Fatalf("Error saving post: %v", err)
+	}
+
+	var second newsPage
+	doRequest(t, router, "/news?per_page=2&page=5&cursor="+first.NextCursor, &second)
+	if titles := postTitles(second.Posts); titles != "Go news #3, Go news #2" {
+		t.Errorf("Unexpected second page: %s", titles)
+	}
+	if _, ok := second.Pagination["current_page"]; ok {
+		t.Errorf("Expected no current_page for cursor request: %v", second.Pagination)
+	}
+
+	var back newsPage
+	doRequest(t, router, "/news?per_page=2&cursor="+second.PrevCursor, &back)
+	if titles := postTitles(back.Posts); titles != "Go news #5, Go news #4" {
+		t.Errorf("Unexpected previous page: %s", titles)
+	}
+	if back.PrevCursor == "" || back.NextCursor == "" {
+		t.Errorf("Unexpected cursors: next %q, prev %q", back.NextCursor, back.PrevCursor)
+	}
+
+	// Курсор задаёт порядок сортировки
+	var oldest newsPage
+	doRequest(t, router, "/news?sort=oldest&per_page=2", &oldest)
+	var oldestNext newsPage
+	doRequest(t, router, "/news?per_page=2&cursor="+oldest.NextCursor, &oldestNext)
+	if titles := postTitles(oldestNext.Posts); titles != "Go news #2, Go news #3" {
+		t.Errorf("Unexpected oldest second page: %s", titles)
+	}
+
+	// Для сортировки по релевантности курсоры не выдаются
+	var relevance newsPage
+	doRequest(t, router, "/news?s=go&per_page=2", &relevance)
+	if relevance.NextCursor != "" || relevance.PrevCursor != "" {
+		t.Errorf("Expected no cursors for relevance sort: %+v", relevance)
+	}
+
+	for _, target := range []string{"/news?cursor=garbage", "/news/3?cursor=garbage"} {
+		if rec := doRequest(t, router, target, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", target, rec.Code)
+		}
+	}
+}
+
+func TestGetNewsCollapse(t *testing.T) {
+	router, db := newTestRouter(t, 0)
+	story := "Как мы ускорили сборку Go-проекта в три раза: кэширование модулей и параллельные тесты сократили время CI."
+	date := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	db.SavePosts(context.Background(), []rss.Post{
+		{Title: "Сборка Go", Content: story, PubDate: date.Format(time.RFC1123Z), Link: "https://habr.com/1", Source: "hub"},
+		{Title: "Сборка Go", Content: story, PubDate: date.Add(time.Hour).Format(time.RFC1123Z), Link: "https://habr.com/1?best", Source: "best"},
+	})
+
+	var page newsPage
+	doRequest(t, router, "/news?collapse=true", &page)
+	if page.Pagination["total_items"] != 2 || len(page.Posts) != 2 {
+		t.Fatalf("Expected 2 collapsed posts, got %+v", page)
+	}
+	if alts := page.Posts[0].Alternates; len(alts) != 1 || alts[0].Link != "https://habr.com/1?best" || alts[0].Source != "best" {
+		t.Errorf("Expected alternate from best feed: %+v", page.Posts[0])
+	}
+
+	doRequest(t, router, "/news", &page)
+	if page.Pagination["total_items"] != 3 {
+		t.Errorf("Expected 3 posts without collapse, got %d", page.Pagination["total_items"])
+	}
+	if rec := doRequest(t, router, "/news?collapse=maybe", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid collapse, got %d", rec.Code)
+	}
+}
+
+func TestGetNewsDetails(t *testing.T) {
+	router, db := newTestRouter(t, 2)
+	last, _ := db.GetLastNPosts(context.Background(), 1)
+
+	var post rss.Post
+	rec := doRequest(t, router, fmt.Sprintf("/news/details?id=%d", last[0].ID), &post)
+	if rec.Code != http.StatusOK || post.Title != last[0].Title {
+		t.Errorf("Unexpected response %d: %+v", rec.Code, post)
+	}
+
+	// Полный текст статьи отдаётся только в деталях новости
+	full := last[0]
+	full.FullContent = "<p>Full article</p>"
+	db.SavePosts(context.Background(), []rss.Post{full})
+	doRequest(t, router, fmt.Sprintf("/news/details?id=%d", full.ID), &post)
+	if post.FullContent != full.FullContent {
+		t.Errorf("Expected full content in details, got %q", post.FullContent)
+	}
+	var list []rss.Post
+	doRequest(t, router, "/news/1", &list)
+	if len(list) != 1 || list[0].FullContent != "" {
+		t.Errorf("Expected no full content in news list: %+v", list)
+	}
+
+	tests := []struct {
+		target string
+		status int
+	}{
+		{"/news/details", http.StatusBadRequest},
+		{"/news/details?id=abc", http.StatusBadRequest},
+		{"/news/details?id=1000", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if rec := doRequest(t, router, tt.target, nil); rec.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.target, tt.status, rec.Code)
+		}
+	}
+}
+
+func TestGetNews(t *testing.T) {
+	router, _ := newTestRouter(t, 20)
+
+	var response struct {
+		Posts      []rss.Post     `json:"posts"`
+		Pagination map[string]int `json:"pagination"`
+	}
+	rec := doRequest(t, router, "/news?s=go&page=2", &response)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if len(response.Posts) != 5 {
+		t.Errorf("Expected 5 posts on second page, got %d", len(response.Posts))
+	}
+	if response.Pagination["current_page"] != 2 || response.Pagination["total_pages"] != 2 || response.Pagination["total_items"] != 20 {
+		t.Errorf("Unexpected pagination: %v", response.Pagination)
+	}
+}
+
+func TestGetNewsFilters(t *testing.T) {
+	router, _ := newTestRouter(t, 20)
+
+	var response struct {
+		Posts      []rss.Post     `json:"posts"`
+		Pagination map[string]int `json:"pagination"`
+	}
+
+	// Посты newTestRouter публикуются раз в минуту начиная с 09:01
+	rec := doRequest(t, router, "/news?from=2024-03-04T09:05:00Z&to=2024-03-04T09:10:00Z&sort=oldest&per_page=4", &response)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if len(response.Posts) != 4 || response.Posts[0].Title != "Go news #5" {
+		t.Errorf("Unexpected posts: %+v", response.Posts)
+	}
+	if response.Pagination["total_items"] != 6 || response.Pagination["items_per_page"] != 4 || response.Pagination["total_pages"] != 2 {
+		t.Errorf("Unexpected pagination: %v", response.Pagination)
+	}
+
+	// per_page ограничен сверху
+	doRequest(t, router, "/news?per_page=1000", &response)
+	if response.Pagination["items_per_page"] != maxItemsPerPage {
+		t.Errorf("Expected per_page to be capped at %d, got %d", maxItemsPerPage, response.Pagination["items_per_page"])
+	}
+
+	// Дата без времени в to включает весь день
+	doRequest(t, router, "/news?to=2024-03-04", &response)
+	if response.Pagination["total_items"] != 21 {
+		t.Errorf("Expected all posts up to the end of day, got %d", response.Pagination["total_items"])
+	}
+
+	for _, target := range []string{
+		"/news?sort=random",
+		"/news?from=yesterday",
+		"/news?from=2024-03-05&to=2024-03-04",
+		"/news?per_page=0",
+	} {
+		if rec := doRequest(t, router, target, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", target, rec.Code)
+		}
+	}
+}
+
+func TestGetFeeds(t *testing.T) {
+	router, db := newTestRouter(t, 0)
+	db.SaveFeedStatus(context.Background(), storage.FeedStatus{URL: "http://example.com/rss", ConsecutiveFailures: 3})
+
+	var feeds []storage.FeedStatus
+	rec := doRequest(t, router, "/feeds", &feeds)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if len(feeds) != 1 || feeds[0].ConsecutiveFailures != 3 {
+		t.Errorf("Unexpected feeds: %+v", feeds)
+	}
+}
@@ -0,0 +1,336 @@
+package api
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"Task36a41/pkg/rss"
+	"Task36a41/pkg/storage"
+	"Task36a41/pkg/tracing"
+
+	"github.com/gorilla/mux"
+)
+
+// Параметры выходных лент.
+const (
+	feedTitle        = "News aggregator"
+	feedDescription  = "Новости из всех лент агрегатора"
+	defaultFeedItems = 50 // Если параметр per_page не передан
+)
+
+// registerFeedRoutes регистрирует выходные ленты агрегатора.
+func (api *API) registerFeedRoutes(router *mux.Router) {
+	router.HandleFunc("/feed.rss", api.getFeedRSS).Methods(http.MethodGet)
+	router.HandleFunc("/feed.atom", api.getFeedAtom).Methods(http.MethodGet)
+}
+
+// rssOutput — документ RSS 2.0, который отдаёт /feed.rss.
+type rssOutput struct {
+	XMLName xml.Name         `xml:"rss"`
+	Version string           `xml:"version,attr"`
+	AtomNS  string           `xml:"xmlns:atom,attr"`
+	DCNS    string           `xml:"xmlns:dc,attr"`
+	Channel rssOutputChannel `xml:"channel"`
+}
+
+type rssOutputChannel struct {
+	Title         string          `xml:"title"`
+	Link          string          `xml:"link"`
+	Description   string          `xml:"description"`
+	SelfLink      atomOutputLink  `xml:"atom:link"`
+	LastBuildDate string          `xml:"lastBuildDate,omitempty"`
+	Items         []rssOutputItem `xml:"item"`
+}
+
+type rssOutputItem struct {
+	Title       string               `xml:"title"`
+	Link        string               `xml:"link"`
+	Description string               `xml:"description"`
+	PubDate     string               `xml:"pubDate"`
+	GUID        rssOutputGUID        `xml:"guid"`
+	Creator     string               `xml:"dc:creator,omitempty"`
+	Categories  []string             `xml:"category"`
+	Enclosures  []rssOutputEnclosure `xml:"enclosure"`
+	Source      *rssOutputSource     `xml:"source"`
+}
+
+type rssOutputGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssOutputEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length int64  `xml:"length,attr"`
+}
+
+type rssOutputSource struct {
+	URL  string `xml:"url,attr"`
+	Name string `xml:",chardata"`
+}
+
+// atomOutput — документ Atom, который отдаёт /feed.atom.
+type atomOutput struct {
+	XMLName xml.Name          `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string            `xml:"title"`
+	ID      string            `xml:"id"`
+	Updated string            `xml:"updated"`
+	Links   []atomOutputLink  `xml:"link"`
+	Entries []atomOutputEntry `xml:"entry"`
+}
+
+type atomOutputLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr,omitempty"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+type atomOutputEntry struct {
+	Title      string               `xml:"title"`
+	ID         string               `xml:"id"`
+	Updated    string               `xml:"updated"`
+	Published  string               `xml:"published"`
+	Links      []atomOutputLink     `xml:"link"`
+	Authors    []atomOutputAuthor   `xml:"author"`
+	Categories []atomOutputCategory `xml:"category"`
+	Content    atomOutputContent    `xml:"content"`
+}
+
+type atomOutputAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomOutputCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomOutputContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// feedPosts выбирает публикации для выходной ленты с теми же фильтрами, что и /news.
+// По умолчанию публикации идут от новых к старым даже при поисковом запросе:
+// читатели лент ожидают хронологический порядок. При ошибке ответ уже отправлен.
+func (api *API) feedPosts(w http.ResponseWriter, r *http.Request) ([]rss.Post, bool) {
+	requestID := tracing.TraceID(r.Context())
+	values := r.URL.Query()
+
+	filter, err := parseFilter(values)
+	if err != nil {
+		log.Printf("[Request ID: %s] %v", requestID, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	if filter.Sort == "" {
+		filter.Sort = storage.SortNewest
+	}
+
+	filter.Limit = defaultFeedItems
+	if perPageStr := values.Get("per_page"); perPageStr != "" {
+		perPage, err := strconv.Atoi(perPageStr)
+		if err != nil || perPage <= 0 {
+			log.Printf("[Request ID: %s] Invalid 'per_page' parameter: %s", requestID, perPageStr)
+			http.Error(w, "Invalid 'per_page' parameter: must be a positive number", http.StatusBadRequest)
+			return nil, false
+		}
+		filter.Limit = min(perPage, maxItemsPerPage)
+	}
+
+	posts, _, err := api.storage.SearchPosts(r.Context(), filter)
+	if err != nil {
+		log.Printf("[Request ID: %s] Error retrieving feed posts: %v", requestID, err)
+		http.Error(w, "Error retrieving posts", http.StatusInternalServerError)
+		return nil, false
+	}
+	log.Printf("[Request ID: %s] Retrieved %d posts for feed", requestID, len(posts))
+	return posts, true
+}
+
+// SetPublicURL задаёт внешний адрес агрегатора, от которого строятся ссылки
+// на сайт и на саму ленту в /feed.rss и /feed.atom, например адрес шлюза.
+// Пустой адрес означает, что ссылки строятся от адреса запроса.
+func (api *API) SetPublicURL(publicURL string) error {
+	if publicURL == "" {
+		api.publicURL = nil
+		return nil
+	}
+	u, err := url.Parse(publicURL)
+	if err != nil {
+		return fmt.Errorf("invalid public URL: %v", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid public URL %q: must be an absolute http or https URL", publicURL)
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+	u.RawPath, u.RawQuery, u.Fragment = "", "", ""
+	api.publicURL = u
+	return nil
+}
+
+// feedURLs возвращает адрес сайта и адрес самой ленты с параметрами запроса.
+// Заголовки X-Forwarded-* не учитываются: их может передать любой клиент,
+// поэтому адрес за шлюзом задаётся через SetPublicURL.
+func (api *API) feedURLs(r *http.Request) (site, self string) {
+	base := api.publicURL
+	if base == nil {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		base = &url.URL{Scheme: scheme, Host: r.Host, Path: "/"}
+	}
+	site = base.String()
+	self = base.ResolveReference(&url.URL{Path: strings.TrimPrefix(r.URL.Path, "/"), RawQuery: r.URL.RawQuery}).String()
+	return site, self
+}
+
+// postTime возвращает время публикации; дата из хранилища всегда в формате RFC1123Z.
+func postTime(post rss.Post) time.Time {
+	t, err := time.Parse(time.RFC1123Z, post.PubDate)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// entryID возвращает постоянный идентификатор записи: GUID из исходной ленты,
+// если это URI, иначе ссылку на публикацию.
+func entryID(post rss.Post) string {
+	if u, err := url.Parse(post.GUID); err == nil && post.GUID != "" && u.IsAbs() {
+		return post.GUID
+	}
+	return post.Link
+}
+
+// writeFeed отправляет XML-документ ленты.
+func writeFeed(w http.ResponseWriter, r *http.Request, contentType string, doc interface{}) {
+	w.Header().Set("Content-Type", contentType)
+	w.Write([]byte(xml.Header))
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		log.Printf("[Request ID: %s] Error encoding feed: %v", tracing.TraceID(r.Context()), err)
+	}
+}
+
+// getFeedRSS отдаёт публикации агрегатора лентой RSS 2.0.
+// Поддерживает параметры s, source, from, to, sort, collapse и per_page, как /news.
+func (api *API) getFeedRSS(w http.ResponseWriter, r *http.Request) {
+	requestID := tracing.TraceID(r.Context())
+	log.Printf("[Request ID: %s] Processing getFeedRSS", requestID)
+
+	posts, ok := api.feedPosts(w, r)
+	if !ok {
+		return
+	}
+
+	site, self := api.feedURLs(r)
+	doc := rssOutput{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		DCNS:    "http://purl.org/dc/elements/1.1/",
+		Channel: rssOutputChannel{
+			Title:       feedTitle,
+			Link:        site,
+			Description: feedDescription,
+			SelfLink:    atomOutputLink{Href: self, Rel: "self", Type: "application/rss+xml"},
+		},
+	}
+	if len(posts) > 0 {
+		doc.Channel.LastBuildDate = latestPostTime(posts).Format(time.RFC1123Z)
+	}
+
+	for _, post := range posts {
+		item := rssOutputItem{
+			Title:       post.Title,
+			Link:        post.Link,
+			Description: post.Content,
+			PubDate:     post.PubDate,
+			GUID:        rssOutputGUID{IsPermaLink: entryID(post) == post.Link, Value: entryID(post)},
+			Creator:     post.Author,
+			Categories:  post.Categories,
+		}
+		for _, e := range post.Enclosures {
+			item.Enclosures = append(item.Enclosures, rssOutputEnclosure{URL: e.URL, Type: e.Type, Length: e.Length})
+		}
+		if post.Source != "" {
+			item.Source = &rssOutputSource{URL: post.Source, Name: post.Source}
+		}
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+
+	writeFeed(w, r, "application/rss+xml; charset=utf-8", doc)
+}
+
+// getFeedAtom отдаёт публикации агрегатора лентой Atom.
+// Поддерживает параметры s, source, from, to, sort, collapse и per_page, как /news.
+func (api *API) getFeedAtom(w http.ResponseWriter, r *http.Request) {
+	requestID := tracing.TraceID(r.Context())
+	log.Printf("[Request ID: %s] Processing getFeedAtom", requestID)
+
+	posts, ok := api.feedPosts(w, r)
+	if !ok {
+		return
+	}
+
+	site, self := api.feedURLs(r)
+	updated := time.Now().UTC()
+	if len(posts) > 0 {
+		updated = latestPostTime(posts)
+	}
+	doc := atomOutput{
+		Title:   feedTitle,
+		ID:      self,
+		Updated: updated.Format(time.RFC3339),
+		Links: []atomOutputLink{
+			{Href: self, Rel: "self", Type: "application/atom+xml"},
+			{Href: site, Rel: "alternate", Type: "text/html"},
+		},
+	}
+
+	for _, post := range posts {
+		published := postTime(post).Format(time.RFC3339)
+		entry := atomOutputEntry{
+			Title:     post.Title,
+			ID:        entryID(post),
+			Updated:   published,
+			Published: published,
+			Links:     []atomOutputLink{{Href: post.Link, Rel: "alternate", Type: "text/html"}},
+			Content:   atomOutputContent{Type: "html", Value: post.Content},
+		}
+		if post.Author != "" {
+			entry.Authors = []atomOutputAuthor{{Name: post.Author}}
+		}
+		for _, c := range post.Categories {
+			entry.Categories = append(entry.Categories, atomOutputCategory{Term: c})
+		}
+		for _, e := range post.Enclosures {
+			entry.Links = append(entry.Links, atomOutputLink{Href: e.URL, Rel: "enclosure", Type: e.Type, Length: e.Length})
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	writeFeed(w, r, "application/atom+xml; charset=utf-8", doc)
+}
+
+// latestPostTime возвращает время самой новой публикации.
+func latestPostTime(posts []rss.Post) time.Time {
+	var latest time.Time
+	for _, post := range posts {
+		if t := postTime(post); t.After(latest) {
+			latest = t
+		}
+	}
+	return latest.UTC()
+}
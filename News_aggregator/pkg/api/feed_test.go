@@ -0,0 +1,114 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"Task36a41/pkg/rss"
+	"Task36a41/pkg/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// getFeed запрашивает выходную ленту и разбирает её парсером лент агрегатора.
+func getFeed(t *testing.T, router http.Handler, req *http.Request, contentType string) []rss.Post {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for %s, got %d: %s", req.URL, rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Type"); got != contentType {
+		t.Errorf("Expected Content-Type %q, got %q", contentType, got)
+	}
+	posts, err := rss.Parse(rec.Body.Bytes())
+	if err != nil {
+		t.Fatalf("Error parsing feed %s: %v\n%s", req.URL, err, rec.Body.String())
+	}
+	return posts
+}
+
+func TestFeedRSS(t *testing.T) {
+	router, _ := newTestRouter(t, 5)
+
+	req := httptest.NewRequest(http.MethodGet, "/feed.rss?s=Go&per_page=3", nil)
+	posts := getFeed(t, router, req, "application/rss+xml; charset=utf-8")
+	if got := postTitles(posts); got != "Go news #5, Go news #4, Go news #3" {
+		t.Errorf("Unexpected items: %s", got)
+	}
+	if posts[0].Link != "http://example.com/5" || posts[0].Content != "Content #5" {
+		t.Errorf("Unexpected first item: %+v", posts[0])
+	}
+	if posts[0].PubDate != "Mon, 04 Mar 2024 09:05:00 +0000" {
+		t.Errorf("Unexpected pubDate: %s", posts[0].PubDate)
+	}
+
+	// Фильтр по источнику, как в /news
+	req = httptest.NewRequest(http.MethodGet, "/feed.rss?source=http://example.com/other", nil)
+	if posts := getFeed(t, router, req, "application/rss+xml; charset=utf-8"); len(posts) != 0 {
+		t.Errorf("Expected empty feed for unknown source, got %d items", len(posts))
+	}
+}
+
+func TestFeedAtom(t *testing.T) {
+	router, _ := newTestRouter(t, 2)
+
+	req := httptest.NewRequest(http.MethodGet, "/feed.atom", nil)
+	posts := getFeed(t, router, req, "application/atom+xml; charset=utf-8")
+	if got := postTitles(posts); got != "Go news #2, Go news #1, Python release" {
+		t.Errorf("Unexpected entries: %s", got)
+	}
+	if posts[0].Link != "http://example.com/2" || posts[0].Content != "Content #2" {
+		t.Errorf("Unexpected first entry: %+v", posts[0])
+	}
+
+	// Без внешнего адреса ссылки строятся от адреса запроса, X-Forwarded-* не учитываются
+	req.Header.Set("X-Forwarded-Host", "evil.example")
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), `<link href="http://example.com/feed.atom" rel="self"`) {
+		t.Errorf("Expected self link from request host, got:\n%s", rec.Body.String())
+	}
+}
+
+func TestFeedPublicURL(t *testing.T) {
+	api := New(storage.NewMemory())
+	if err := api.SetPublicURL("https://news.example.com/aggregator"); err != nil {
+		t.Fatalf("Error setting public URL: %v", err)
+	}
+	router := mux.NewRouter()
+	api.RegisterRoutes(router)
+
+	req := httptest.NewRequest(http.MethodGet, "/feed.atom?s=go", nil)
+	req.Header.Set("X-Forwarded-Host", "evil.example")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	body := rec.Body.String()
+	for _, want := range []string{
+		`<link href="https://news.example.com/aggregator/feed.atom?s=go" rel="self"`,
+		`<link href="https://news.example.com/aggregator/" rel="alternate"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %s, got:\n%s", want, body)
+		}
+	}
+
+	for _, invalid := range []string{"news.example.com", "/feed", "ftp://news.example.com/"} {
+		if err := api.SetPublicURL(invalid); err == nil {
+			t.Errorf("Expected error for public URL %q", invalid)
+		}
+	}
+}
+
+func TestFeedInvalidParams(t *testing.T) {
+	router, _ := newTestRouter(t, 1)
+
+	for _, target := range []string{"/feed.rss?per_page=0", "/feed.atom?sort=random", "/feed.rss?from=yesterday"} {
+		if rec := doRequest(t, router, target, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", target, rec.Code)
+		}
+	}
+}
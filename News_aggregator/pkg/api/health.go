@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"Task36a41/pkg/scheduler"
+	"Task36a41/pkg/tracing"
+
+	"github.com/gorilla/mux"
+)
+
+// healthCheckTimeout ограничивает время одной проверки зависимости.
+const healthCheckTimeout = 2 * time.Second
+
+// maxPollAge — если ни одна лента не опрошена успешно дольше, сервис не готов.
+// Неработающую ленту планировщик опрашивает не реже раза в scheduler.MaxBackoff,
+// поэтому порог вдвое больше: один неудачный опрос на максимальной задержке
+// ещё не делает сервис неготовым.
+const maxPollAge = 2 * scheduler.MaxBackoff
+
+// Статусы проверок.
+const (
+	statusOK          = "ok"
+	statusUnavailable = "unavailable"
+)
+
+// checkResult — результат проверки одной зависимости.
+type checkResult struct {
+	Status        string     `json:"status"`
+	LatencyMS     int64      `json:"latency_ms"`
+	Error         string     `json:"error,omitempty"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"` // Для опроса лент
+}
+
+// healthResponse — ответ /healthz и /readyz.
+type healthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks,omitempty"`
+}
+
+// registerHealthRoutes регистрирует проверки для оркестратора и шлюза.
+func (api *API) registerHealthRoutes(router *mux.Router) {
+	router.HandleFunc("/healthz", api.healthz).Methods(http.MethodGet)
+	router.HandleFunc("/readyz", api.readyz).Methods(http.MethodGet)
+}
+
+// healthz сообщает, что процесс жив и обрабатывает запросы. Зависимости не проверяются:
+// их недоступность не исправить перезапуском.
+func (api *API) healthz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, r, healthResponse{Status: statusOK})
+}
+
+// readyz сообщает, готов ли сервис отдавать новости: база данных отвечает,
+// а хотя бы одна лента успешно опрошена не раньше maxPollAge назад.
+// До первого опроса лент сервис считается готовым: он отдаёт уже сохранённые новости.
+func (api *API) readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	response := healthResponse{
+		Status: statusOK,
+		Checks: map[string]checkResult{
+			"database": timedCheck(func() error { return api.storage.Ping(ctx) }),
+			"poller":   api.checkPoller(ctx),
+		},
+	}
+	for _, check := range response.Checks {
+		if check.Status != statusOK {
+			response.Status = statusUnavailable
+		}
+	}
+	writeHealth(w, r, response)
+}
+
+// checkPoller проверяет время последнего успешного опроса лент.
+func (api *API) checkPoller(ctx context.Context) checkResult {
+	var lastSuccess *time.Time
+	var polled bool
+	result := timedCheck(func() error {
+		statuses, err := api.storage.GetFeedStatuses(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			polled = polled || status.LastSuccessAt != nil || status.LastErrorAt != nil
+			if status.LastSuccessAt != nil && (lastSuccess == nil || status.LastSuccessAt.After(*lastSuccess)) {
+				lastSuccess = status.LastSuccessAt
+			}
+		}
+		if !polled {
+			return nil
+		}
+		if lastSuccess == nil {
+			return fmt.Errorf("no feed has been polled successfully")
+		}
+		if age := time.Since(*lastSuccess); age > maxPollAge {
+			return fmt.Errorf("last successful poll was %v ago", age.Round(time.Minute))
+		}
+		return nil
+	})
+	result.LastSuccessAt = lastSuccess
+	return result
+}
+
+// timedCheck выполняет проверку и замеряет её время.
+func timedCheck(check func() error) checkResult {
+	start := time.Now()
+	err := check()
+	result := checkResult{Status: statusOK, LatencyMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = statusUnavailable
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// writeHealth отправляет результат проверки: 200, если всё в порядке, иначе 503.
+func writeHealth(w http.ResponseWriter, r *http.Request, response healthResponse) {
+	code := http.StatusOK
+	if response.Status != statusOK {
+		code = http.StatusServiceUnavailable
+		log.Printf("[Request ID: %s] Service is not ready: %+v", tracing.TraceID(r.Context()), response.Checks)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("[Request ID: %s] Error encoding response: %v", tracing.TraceID(r.Context()), err)
+	}
+}
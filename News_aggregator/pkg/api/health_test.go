@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"Task36a41/pkg/scheduler"
+	"Task36a41/pkg/storage"
+
+	"github.com/gorilla/mux"
+)
+
+func TestHealthz(t *testing.T) {
+	router, _ := newTestRouter(t, 1)
+
+	var response healthResponse
+	rec := doRequest(t, router, "/healthz", &response)
+	if rec.Code != http.StatusOK || response.Status != statusOK {
+		t.Errorf("Expected ok, got %d %+v", rec.Code, response)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	router, db := newTestRouter(t, 1)
+
+	// До первого опроса лент сервис готов отдавать сохранённые новости
+	var response healthResponse
+	if rec := doRequest(t, router, "/readyz", &response); rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 before first poll, got %d", rec.Code)
+	}
+	if response.Checks["database"].Status != statusOK || response.Checks["poller"].Status != statusOK {
+		t.Errorf("Unexpected checks: %+v", response.Checks)
+	}
+
+	now := time.Now()
+	stale := now.Add(-maxPollAge - time.Hour)
+	backedOff := now.Add(-scheduler.MaxBackoff - time.Minute)
+	tests := []struct {
+		name   string
+		status storage.FeedStatus
+		code   int
+	}{
+		{"only failures", storage.FeedStatus{URL: "http://example.com/feed", LastErrorAt: &now}, http.StatusServiceUnavailable},
+		{"stale success", storage.FeedStatus{URL: "http://example.com/feed", LastSuccessAt: &stale}, http.StatusServiceUnavailable},
+		{"success before max backoff", storage.FeedStatus{URL: "http://example.com/feed", LastSuccessAt: &backedOff}, http.StatusOK},
+		{"recent success", storage.FeedStatus{URL: "http://example.com/feed", LastSuccessAt: &now}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if err := db.SaveFeedStatus(context.Background(), tt.status); err != nil {
+			t.Fatalf("Error saving feed status: %v", err)
+		}
+		rec := doRequest(t, router, "/readyz", nil)
+		if rec.Code != tt.code {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.name, tt.code, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+// blockingFeedStore отвечает на запрос состояния лент только после отмены контекста.
+type blockingFeedStore struct {
+	*storage.Memory
+}
+
+func (s blockingFeedStore) GetFeedStatuses(ctx context.Context) ([]storage.FeedStatus, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestReadyzPollerTimeout(t *testing.T) {
+	api := New(blockingFeedStore{storage.NewMemory()})
+	router := mux.NewRouter()
+	api.RegisterRoutes(router)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil).WithContext(ctx))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 when feed statuses time out, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
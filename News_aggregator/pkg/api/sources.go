@@ -0,0 +1,366 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"Task36a41/pkg/opml"
+	"Task36a41/pkg/rss"
+	"Task36a41/pkg/storage"
+	"Task36a41/pkg/tracing"
+
+	"github.com/gorilla/mux"
+)
+
+// SetAdminToken задаёт токен, который маршруты /admin ждут в заголовке
+// "Authorization: Bearer <токен>". Пока токен не задан, они отключены.
+func (api *API) SetAdminToken(token string) {
+	api.adminToken = token
+}
+
+// registerSourceRoutes регистрирует маршруты управления источниками и OPML.
+// Они доступны только с токеном администратора.
+func (api *API) registerSourceRoutes(router *mux.Router) {
+	admin := router.PathPrefix("/admin").Subrouter()
+	admin.Use(api.requireAdmin)
+	admin.HandleFunc("/sources", api.getSources).Methods(http.MethodGet)
+	admin.HandleFunc("/sources", api.addSource).Methods(http.MethodPost)
+	admin.HandleFunc("/sources/discover", api.discoverSources).Methods(http.MethodGet)
+	admin.HandleFunc("/sources/{id:[0-9]+}", api.getSource).Methods(http.MethodGet)
+	admin.HandleFunc("/sources/{id:[0-9]+}", api.updateSource).Methods(http.MethodPatch)
+	admin.HandleFunc("/sources/{id:[0-9]+}", api.deleteSource).Methods(http.MethodDelete)
+	admin.HandleFunc("/opml", api.exportOPML).Methods(http.MethodGet)
+	admin.HandleFunc("/opml", api.importOPML).Methods(http.MethodPost)
+}
+
+// requireAdmin пропускает только запросы с токеном администратора.
+func (api *API) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if api.adminToken == "" {
+			http.Error(w, "Admin API is disabled: admin token is not configured", http.StatusForbidden)
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(api.adminToken)) != 1 {
+			log.Printf("[Request ID: %s] Unauthorized admin request: %s %s", tracing.TraceID(r.Context()), r.Method, r.URL.Path)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// maxOPMLSize ограничивает размер импортируемого файла OPML.
+const maxOPMLSize = 1 << 20
+
+// sourceRequest — тело запросов на добавление и изменение источника.
+// Незаданные поля при изменении остаются прежними.
+type sourceRequest struct {
+	URL          string  `json:"url"`
+	Name         *string `json:"name"`
+	Enabled      *bool   `json:"enabled"`
+	PollInterval *int    `json:"poll_interval"`
+	Category     *string `json:"category"`
+	FullText     *bool   `json:"full_text"`
+}
+
+// apply переносит заданные поля запроса в источник.
+func (req sourceRequest) apply(source *storage.Source) error {
+	if req.Name != nil {
+		source.Name = *req.Name
+	}
+	if req.Enabled != nil {
+		source.Enabled = *req.Enabled
+	}
+	if req.PollInterval != nil {
+		if *req.PollInterval < 0 {
+			return fmt.Errorf("poll_interval must not be negative")
+		}
+		source.PollInterval = *req.PollInterval
+	}
+	if req.Category != nil {
+		source.Category = *req.Category
+	}
+	if req.FullText != nil {
+		source.FullText = *req.FullText
+	}
+	return nil
+}
+
+// writeJSON отправляет значение в формате JSON с заданным кодом ответа.
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("[Request ID: %s] Error encoding response: %v", tracing.TraceID(r.Context()), err)
+	}
+}
+
+// getSources возвращает все источники.
+func (api *API) getSources(w http.ResponseWriter, r *http.Request) {
+	requestID := tracing.TraceID(r.Context())
+	log.Printf("[Request ID: %s] Processing getSources", requestID)
+
+	sources, err := api.storage.GetSources(r.Context())
+	if err != nil {
+		log.Printf("[Request ID: %s] Error retrieving sources: %v", requestID, err)
+		http.Error(w, "Error retrieving sources", http.StatusInternalServerError)
+		return
+	}
+	if sources == nil {
+		sources = []storage.Source{}
+	}
+	writeJSON(w, r, http.StatusOK, sources)
+}
+
+// addSource добавляет источник. Вместо адреса ленты можно передать адрес сайта:
+// если на нём найдена ровно одна лента, добавляется она, иначе возвращается 422
+// со списком найденных лент. По умолчанию источник сразу включён
+// и начнёт опрашиваться на ближайшем шаге планировщика.
+func (api *API) addSource(w http.ResponseWriter, r *http.Request) {
+	requestID := tracing.TraceID(r.Context())
+	log.Printf("[Request ID: %s] Processing addSource", requestID)
+
+	var req sourceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := storage.ValidateSourceURL(req.URL); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	source := storage.Source{URL: req.URL, Enabled: true}
+	if err := req.apply(&source); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Адрес сайта заменяем найденной на нём лентой. Если лент несколько,
+	// возвращаем их список, чтобы клиент повторил запрос с выбранной
+	candidates, err := api.discover(req.URL)
+	if errors.Is(err, rss.ErrForbiddenAddress) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("[Request ID: %s] Error discovering feeds at %s: %v", requestID, req.URL, err)
+		http.Error(w, fmt.Sprintf("Could not fetch %s: %v", req.URL, err), http.StatusUnprocessableEntity)
+		return
+	}
+	if len(candidates) != 1 {
+		writeJSON(w, r, http.StatusUnprocessableEntity, discoveryFailure(candidates))
+		return
+	}
+	source.URL = candidates[0].URL
+	if source.Name == "" {
+		source.Name = candidates[0].Title
+	}
+
+	source, err = api.storage.AddSource(r.Context(), source)
+	if errors.Is(err, storage.ErrSourceExists) {
+		http.Error(w, "Source with this URL already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("[Request ID: %s] Error adding source: %v", requestID, err)
+		http.Error(w, "Error adding source", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[Request ID: %s] Source %d added: %s", requestID, source.ID, source.URL)
+	writeJSON(w, r, http.StatusCreated, source)
+}
+
+// discoveryResponse — ответ, когда по адресу не удалось однозначно определить ленту.
+type discoveryResponse struct {
+	Error      string          `json:"error"`
+	Candidates []rss.Candidate `json:"candidates"`
+}
+
+// discoveryFailure описывает, почему источник не добавлен: лент не найдено или их несколько.
+func discoveryFailure(candidates []rss.Candidate) discoveryResponse {
+	if len(candidates) == 0 {
+		return discoveryResponse{Error: "No feeds found at this URL", Candidates: []rss.Candidate{}}
+	}
+	return discoveryResponse{Error: "Several feeds found at this URL, choose one of the candidates", Candidates: candidates}
+}
+
+// discoverSources возвращает ленты, найденные по адресу сайта из параметра url.
+// Адреса внутренней сети отклоняются с кодом 400.
+func (api *API) discoverSources(w http.ResponseWriter, r *http.Request) {
+	requestID := tracing.TraceID(r.Context())
+	log.Printf("[Request ID: %s] Processing discoverSources", requestID)
+
+	pageURL := r.URL.Query().Get("url")
+	if err := storage.ValidateSourceURL(pageURL); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	candidates, err := api.discover(pageURL)
+	if errors.Is(err, rss.ErrForbiddenAddress) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("[Request ID: %s] Error discovering feeds at %s: %v", requestID, pageURL, err)
+		http.Error(w, fmt.Sprintf("Could not fetch %s: %v", pageURL, err), http.StatusBadGateway)
+		return
+	}
+	if candidates == nil {
+		candidates = []rss.Candidate{}
+	}
+	writeJSON(w, r, http.StatusOK, candidates)
+}
+
+// sourceByID находит источник по ID из пути запроса. Если источника нет
+// или произошла ошибка, отправляет ответ с ошибкой и возвращает nil.
+func (api *API) sourceByID(w http.ResponseWriter, r *http.Request) *storage.Source {
+	requestID := tracing.TraceID(r.Context())
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid source ID", http.StatusBadRequest)
+		return nil
+	}
+
+	source, err := api.storage.GetSource(r.Context(), id)
+	if err != nil {
+		log.Printf("[Request ID: %s] Error retrieving source: %v", requestID, err)
+		http.Error(w, "Error retrieving source", http.StatusInternalServerError)
+		return nil
+	}
+	if source == nil {
+		http.Error(w, "Source not found", http.StatusNotFound)
+		return nil
+	}
+	return source
+}
+
+// getSource возвращает источник по ID.
+func (api *API) getSource(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[Request ID: %s] Processing getSource", tracing.TraceID(r.Context()))
+
+	if source := api.sourceByID(w, r); source != nil {
+		writeJSON(w, r, http.StatusOK, source)
+	}
+}
+
+// updateSource изменяет название, категорию, интервал опроса источника
+// или включает и отключает его. URL источника изменить нельзя.
+func (api *API) updateSource(w http.ResponseWriter, r *http.Request) {
+	requestID := tracing.TraceID(r.Context())
+	log.Printf("[Request ID: %s] Processing updateSource", requestID)
+
+	source := api.sourceByID(w, r)
+	if source == nil {
+		return
+	}
+
+	var req sourceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.URL != "" && req.URL != source.URL {
+		http.Error(w, "Source URL cannot be changed", http.StatusBadRequest)
+		return
+	}
+	if err := req.apply(source); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := api.storage.UpdateSource(r.Context(), *source)
+	if errors.Is(err, storage.ErrSourceNotFound) {
+		http.Error(w, "Source not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[Request ID: %s] Error updating source: %v", requestID, err)
+		http.Error(w, "Error updating source", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[Request ID: %s] Source %d updated: enabled=%t", requestID, source.ID, source.Enabled)
+	writeJSON(w, r, http.StatusOK, source)
+}
+
+// deleteSource удаляет источник. Собранные из него публикации сохраняются.
+func (api *API) deleteSource(w http.ResponseWriter, r *http.Request) {
+	requestID := tracing.TraceID(r.Context())
+	log.Printf("[Request ID: %s] Processing deleteSource", requestID)
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid source ID", http.StatusBadRequest)
+		return
+	}
+
+	err = api.storage.DeleteSource(r.Context(), id)
+	if errors.Is(err, storage.ErrSourceNotFound) {
+		http.Error(w, "Source not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[Request ID: %s] Error deleting source: %v", requestID, err)
+		http.Error(w, "Error deleting source", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[Request ID: %s] Source %d deleted", requestID, id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// exportOPML выгружает список источников в формате OPML 2.0 с папками по категориям.
+func (api *API) exportOPML(w http.ResponseWriter, r *http.Request) {
+	requestID := tracing.TraceID(r.Context())
+	log.Printf("[Request ID: %s] Processing exportOPML", requestID)
+
+	doc, err := opml.Export(r.Context(), api.storage, "News aggregator subscriptions", time.Now())
+	if err != nil {
+		log.Printf("[Request ID: %s] Error exporting sources: %v", requestID, err)
+		http.Error(w, "Error exporting sources", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/x-opml; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="subscriptions.opml"`)
+	if err := doc.Write(w); err != nil {
+		log.Printf("[Request ID: %s] Error writing OPML: %v", requestID, err)
+	}
+}
+
+// importOPML добавляет источники из файла OPML в теле запроса
+// и возвращает отчёт о добавленных, уже известных и пропущенных лентах.
+func (api *API) importOPML(w http.ResponseWriter, r *http.Request) {
+	requestID := tracing.TraceID(r.Context())
+	log.Printf("[Request ID: %s] Processing importOPML", requestID)
+
+	doc, err := opml.Parse(http.MaxBytesReader(w, r.Body, maxOPMLSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := opml.Import(r.Context(), api.storage, doc)
+	if err != nil {
+		log.Printf("[Request ID: %s] Error importing sources: %v", requestID, err)
+		http.Error(w, "Error importing sources", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("[Request ID: %s] OPML imported: added=%d existing=%d skipped=%d",
+		requestID, report.Added, report.Existing, len(report.Skipped))
+	writeJSON(w, r, http.StatusOK, report)
+}
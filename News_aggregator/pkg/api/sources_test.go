@@ -0,0 +1,255 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"Task36a41/pkg/opml"
+	"Task36a41/pkg/rss"
+	"Task36a41/pkg/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// doJSONRequest выполняет запрос с JSON-телом и декодирует успешный ответ.
+func doJSONRequest(t *testing.T, router http.Handler, method, target, body string, out interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	router.ServeHTTP(rec, req)
+	if out != nil && rec.Code < 300 {
+		if err := json.NewDecoder(rec.Body).Decode(out); err != nil {
+			t.Fatalf("Error decoding response of %s %s: %v", method, target, err)
+		}
+	}
+	return rec
+}
+
+func TestSourcesCRUD(t *testing.T) {
+	router, db := newTestRouter(t, 0)
+	db.SavePosts(context.Background(), []rss.Post{{
+		Title:   "Old post",
+		PubDate: "Mon, 04 Mar 2024 09:00:00 +0000",
+		Link:    "http://example.com/old",
+		Source:  "http://example.com/rss",
+	}})
+
+	var source storage.Source
+	rec := doJSONRequest(t, router, http.MethodPost, "/admin/sources",
+		`{"url": "http://example.com/rss", "name": "Example", "category": "go", "poll_interval": 30}`, &source)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", rec.Code, rec.Body)
+	}
+	if source.ID == 0 || !source.Enabled || source.PollInterval != 30 || source.Category != "go" {
+		t.Errorf("Unexpected source: %+v", source)
+	}
+
+	// Уже собранные публикации привязываются к источнику
+	var posts []rss.Post
+	doRequest(t, router, "/news/1", &posts)
+	if len(posts) != 1 || posts[0].SourceID != source.ID {
+		t.Errorf("Expected post to be linked to source %d: %+v", source.ID, posts)
+	}
+
+	rec = doJSONRequest(t, router, http.MethodPost, "/admin/sources", `{"url": "http://example.com/rss"}`, nil)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for duplicate URL, got %d", rec.Code)
+	}
+
+	var updated storage.Source
+	rec = doJSONRequest(t, router, http.MethodPatch, "/admin/sources/1", `{"enabled": false, "full_text": true}`, &updated)
+	if rec.Code != http.StatusOK || updated.Enabled || !updated.FullText || updated.Name != "Example" {
+		t.Errorf("Unexpected update response %d: %+v", rec.Code, updated)
+	}
+
+	var sources []storage.Source
+	doRequest(t, router, "/admin/sources", &sources)
+	if len(sources) != 1 || sources[0].Enabled {
+		t.Errorf("Unexpected sources: %+v", sources)
+	}
+
+	rec = doJSONRequest(t, router, http.MethodDelete, "/admin/sources/1", "", nil)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("Expected status 204, got %d", rec.Code)
+	}
+	if rec := doRequest(t, router, "/admin/sources/1", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 after delete, got %d", rec.Code)
+	}
+	if post, _ := db.GetPostByID(context.Background(), posts[0].ID); post == nil || post.SourceID != 0 {
+		t.Errorf("Expected post to be kept without source: %+v", post)
+	}
+}
+
+func TestAdminRequiresToken(t *testing.T) {
+	router, db := newTestRouter(t, 0)
+
+	requests := []struct {
+		method, target, body string
+	}{
+		{http.MethodGet, "/admin/sources", ""},
+		{http.MethodPost, "/admin/sources", `{"url": "http://example.com/rss"}`},
+		{http.MethodGet, "/admin/sources/discover?url=" + url.QueryEscape("http://example.com/"), ""},
+		{http.MethodPatch, "/admin/sources/1", `{"enabled": false}`},
+		{http.MethodDelete, "/admin/sources/1", ""},
+		{http.MethodGet, "/admin/opml", ""},
+		{http.MethodPost, "/admin/opml", `<opml version="2.0"><body><outline xmlUrl="http://example.com/rss"/></body></opml>`},
+	}
+	for _, tc := range requests {
+		for _, header := range []string{"", "Bearer wrong-token", testAdminToken} {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			if header != "" {
+				req.Header.Set("Authorization", header)
+			}
+			router.ServeHTTP(rec, req)
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("%s %s with Authorization %q: expected status 401, got %d", tc.method, tc.target, header, rec.Code)
+			}
+		}
+	}
+	if sources, _ := db.GetSources(context.Background()); len(sources) != 0 {
+		t.Errorf("Expected no sources to be added without token, got %d", len(sources))
+	}
+
+	// Без настроенного токена управление источниками отключено
+	api := New(db)
+	disabled := mux.NewRouter()
+	api.RegisterRoutes(disabled)
+	for _, target := range []string{"/admin/sources", "/admin/opml"} {
+		if rec := doRequest(t, disabled, target, nil); rec.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403 for %s without configured token, got %d", target, rec.Code)
+		}
+	}
+}
+
+func TestSourcesValidation(t *testing.T) {
+	router, _ := newTestRouter(t, 0)
+
+	tests := []struct {
+		method string
+		target string
+		body   string
+		status int
+	}{
+		{http.MethodPost, "/admin/sources", `{"url": "ftp://example.com/rss"}`, http.StatusBadRequest},
+		{http.MethodPost, "/admin/sources", `{"url": "/rss"}`, http.StatusBadRequest},
+		{http.MethodPost, "/admin/sources", `not json`, http.StatusBadRequest},
+		{http.MethodPost, "/admin/sources", `{"url": "http://example.com/rss", "poll_interval": -1}`, http.StatusBadRequest},
+		{http.MethodPatch, "/admin/sources/42", `{"enabled": false}`, http.StatusNotFound},
+		{http.MethodDelete, "/admin/sources/42", ``, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if rec := doJSONRequest(t, router, tt.method, tt.target, tt.body, nil); rec.Code != tt.status {
+			t.Errorf("%s %s %s: expected status %d, got %d", tt.method, tt.target, tt.body, tt.status, rec.Code)
+		}
+	}
+}
+
+func TestOPMLImportExport(t *testing.T) {
+	router, _ := newTestRouter(t, 0)
+
+	body := `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="2.0"><body>
+  <outline text="Go">
+    <outline text="Go Blog" type="rss" xmlUrl="https://go.dev/blog/feed.atom"/>
+  </outline>
+  <outline text="Broken" xmlUrl="mailto:someone@example.com"/>
+</body></opml>`
+
+	var report opml.ImportReport
+	rec := doJSONRequest(t, router, http.MethodPost, "/admin/opml", body, &report)
+	if rec.Code != http.StatusOK || report.Added != 1 || len(report.Skipped) != 1 {
+		t.Fatalf("Unexpected import response %d: %+v", rec.Code, report)
+	}
+
+	rec = doJSONRequest(t, router, http.MethodGet, "/admin/opml", "", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/x-opml; charset=utf-8" {
+		t.Fatalf("Unexpected export response %d: %v", rec.Code, rec.Header())
+	}
+	doc, err := opml.Parse(rec.Body)
+	if err != nil {
+		t.Fatalf("Error parsing exported OPML: %v", err)
+	}
+	feeds := doc.Feeds()
+	if len(feeds) != 1 || feeds[0].URL != "https://go.dev/blog/feed.atom" || feeds[0].Category != "Go" {
+		t.Errorf("Unexpected exported feeds: %+v", feeds)
+	}
+
+	if rec := doJSONRequest(t, router, http.MethodPost, "/admin/opml", "not xml", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid OPML, got %d", rec.Code)
+	}
+}
+
+func TestAddSourceDiscovery(t *testing.T) {
+	db := storage.NewMemory()
+	api := New(db)
+	api.SetAdminToken(testAdminToken)
+	api.discover = func(url string) ([]rss.Candidate, error) {
+		switch url {
+		case "https://blog.example.com/":
+			return []rss.Candidate{{URL: "https://blog.example.com/rss.xml", Title: "Blog", Type: "application/rss+xml"}}, nil
+		case "https://news.example.com/":
+			return []rss.Candidate{
+				{URL: "https://news.example.com/all.xml", Title: "All"},
+				{URL: "https://news.example.com/go.xml", Title: "Go"},
+			}, nil
+		case "https://down.example.com/":
+			return nil, errors.New("connection refused")
+		case "http://intranet.example.com/":
+			return nil, fmt.Errorf("failed to fetch page: %w", rss.ErrForbiddenAddress)
+		}
+		return nil, nil
+	}
+	router := mux.NewRouter()
+	api.RegisterRoutes(router)
+
+	var source storage.Source
+	rec := doJSONRequest(t, router, http.MethodPost, "/admin/sources", `{"url": "https://blog.example.com/"}`, &source)
+	if rec.Code != http.StatusCreated || source.URL != "https://blog.example.com/rss.xml" || source.Name != "Blog" {
+		t.Errorf("Expected discovered feed to be added, got %d: %+v", rec.Code, source)
+	}
+
+	rec = doJSONRequest(t, router, http.MethodPost, "/admin/sources", `{"url": "https://news.example.com/"}`, nil)
+	var failure discoveryResponse
+	if err := json.NewDecoder(rec.Body).Decode(&failure); err != nil || rec.Code != http.StatusUnprocessableEntity || len(failure.Candidates) != 2 {
+		t.Errorf("Expected 422 with candidates, got %d: %+v", rec.Code, failure)
+	}
+
+	for _, target := range []string{"https://empty.example.com/", "https://down.example.com/"} {
+		rec = doJSONRequest(t, router, http.MethodPost, "/admin/sources", `{"url": "`+target+`"}`, nil)
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected status 422, got %d", target, rec.Code)
+		}
+	}
+	if sources, _ := db.GetSources(context.Background()); len(sources) != 1 {
+		t.Errorf("Expected only one source to be added, got %+v", sources)
+	}
+
+	var candidates []rss.Candidate
+	rec = doJSONRequest(t, router, http.MethodGet, "/admin/sources/discover?url="+url.QueryEscape("https://news.example.com/"), "", &candidates)
+	if rec.Code != http.StatusOK || len(candidates) != 2 {
+		t.Errorf("Unexpected discover response %d: %+v", rec.Code, candidates)
+	}
+	// Внутренние адреса отклоняются как ошибка запроса
+	intranet := "http://intranet.example.com/"
+	if rec := doJSONRequest(t, router, http.MethodPost, "/admin/sources", `{"url": "`+intranet+`"}`, nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for internal address, got %d", rec.Code)
+	}
+	if rec := doRequest(t, router, "/admin/sources/discover?url="+url.QueryEscape(intranet), nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for internal address, got %d", rec.Code)
+	}
+	if rec := doRequest(t, router, "/admin/sources/discover?url="+url.QueryEscape("https://down.example.com/"), nil); rec.Code != http.StatusBadGateway {
+		t.Errorf("Expected status 502 for unreachable site, got %d", rec.Code)
+	}
+	if rec := doRequest(t, router, "/admin/sources/discover", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without url, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"Task36a41/pkg/tracing"
+)
+
+const (
+	streamBatch     = 100              // Сколько публикаций читается из хранилища за раз
+	streamHeartbeat = 30 * time.Second // Как часто отправляется комментарий, чтобы прокси не закрыли соединение
+	streamRetry     = 5000             // Через сколько миллисекунд браузер переподключается после обрыва
+)
+
+// broker оповещает открытые потоки о том, что в хранилище появились новые публикации.
+// Сами публикации потоки читают из хранилища, поэтому пропущенные оповещения
+// не теряют данных: следующее оповещение отдаст всё, что накопилось.
+type broker struct {
+	mu          sync.Mutex
+	subscribers map[chan struct{}]struct{}
+	done        chan struct{} // Закрывается при остановке сервера
+	closeOnce   sync.Once
+}
+
+func newBroker() *broker {
+	return &broker{subscribers: make(map[chan struct{}]struct{}), done: make(chan struct{})}
+}
+
+// subscribe возвращает канал оповещений и функцию отписки.
+func (b *broker) subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subscribers, ch)
+		b.mu.Unlock()
+	}
+}
+
+// publish оповещает всех подписчиков, не дожидаясь медленных: у каждого
+// в канале остаётся не более одного оповещения.
+func (b *broker) publish() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// close завершает все потоки.
+func (b *broker) close() {
+	b.closeOnce.Do(func() { close(b.done) })
+}
+
+// NotifyNewPosts сообщает открытым потокам /news/stream, что в хранилище
+// появились новые публикации. Безопасна для вызова из нескольких горутин.
+func (api *API) NotifyNewPosts() {
+	api.events.publish()
+}
+
+// CloseStreams завершает открытые потоки /news/stream. Вызывается при остановке
+// сервера: иначе http.Server.Shutdown ждал бы бесконечные ответы до истечения таймаута.
+// Браузеры переподключатся к новому экземпляру с Last-Event-ID.
+func (api *API) CloseStreams() {
+	api.events.close()
+}
+
+// lastEventID возвращает ID последней полученной клиентом публикации:
+// из заголовка Last-Event-ID, который браузер отправляет при переподключении,
+// или из параметра last_event_id для первого подключения.
+// Если клиент ничего не получал, поток начинается с последней сохранённой публикации.
+func (api *API) lastEventID(r *http.Request) (int, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	if value == "" {
+		return api.storage.LastPostID(r.Context())
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil || id < 0 {
+		return 0, fmt.Errorf("invalid 'Last-Event-ID': must be a non-negative number")
+	}
+	return id, nil
+}
+
+// streamNews отправляет новые публикации по мере их сохранения в формате Server-Sent Events.
+// Каждая публикация — событие post с её ID и JSON публикации в data.
+// После обрыва браузер переподключается с заголовком Last-Event-ID
+// и получает всё, что было сохранено за время обрыва.
+func (api *API) streamNews(w http.ResponseWriter, r *http.Request) {
+	requestID := tracing.TraceID(r.Context())
+	log.Printf("[Request ID: %s] Processing streamNews", requestID)
+
+	// Подписываемся до чтения хранилища, чтобы не пропустить публикации,
+	// сохранённые между чтением и подпиской
+	notifications, unsubscribe := api.events.subscribe()
+	defer unsubscribe()
+
+	lastID, err := api.lastEventID(r)
+	if err != nil {
+		log.Printf("[Request ID: %s] %v", requestID, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Отключаем буферизацию в nginx
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetry)
+	if err := rc.Flush(); err != nil {
+		log.Printf("[Request ID: %s] Streaming is not supported: %v", requestID, err)
+		return
+	}
+	log.Printf("[Request ID: %s] Streaming posts after ID %d", requestID, lastID)
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		if lastID, err = api.sendPostsAfter(r.Context(), w, lastID); err != nil {
+			log.Printf("[Request ID: %s] Error streaming posts: %v", requestID, err)
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			log.Printf("[Request ID: %s] Stream closed by client", requestID)
+			return
+		case <-api.events.done:
+			log.Printf("[Request ID: %s] Stream closed on shutdown", requestID)
+			return
+		case <-notifications:
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+	}
+}
+
+// sendPostsAfter отправляет все публикации, сохранённые после lastID,
+// и возвращает ID последней отправленной.
+func (api *API) sendPostsAfter(ctx context.Context, w http.ResponseWriter, lastID int) (int, error) {
+	for {
+		posts, err := api.storage.GetPostsAfter(ctx, lastID, streamBatch)
+		if err != nil {
+			return lastID, err
+		}
+		for _, post := range posts {
+			data, err := json.Marshal(post)
+			if err != nil {
+				return lastID, fmt.Errorf("could not encode post %d: %v", post.ID, err)
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: post\ndata: %s\n\n", post.ID, data); err != nil {
+				return lastID, err
+			}
+			lastID = post.ID
+		}
+		if len(posts) < streamBatch {
+			return lastID, nil
+		}
+	}
+}
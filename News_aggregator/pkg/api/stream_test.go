@@ -0,0 +1,141 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"Task36a41/pkg/rss"
+	"Task36a41/pkg/storage"
+
+	"github.com/gorilla/mux"
+)
+
+// sseEvent — событие Server-Sent Events.
+type sseEvent struct {
+	id, event, data string
+}
+
+// readEvent читает из потока следующее событие с данными, пропуская комментарии и retry.
+func readEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+	var ev sseEvent
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Error reading stream: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			if ev.data != "" {
+				return ev
+			}
+		case strings.HasPrefix(line, "id: "):
+			ev.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			ev.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			ev.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+// openStream подключается к /news/stream с заданным Last-Event-ID.
+func openStream(t *testing.T, ctx context.Context, baseURL, lastEventID string) *bufio.Reader {
+	t.Helper()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/news/stream", nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error opening stream: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Unexpected response: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	return bufio.NewReader(resp.Body)
+}
+
+func savePost(t *testing.T, db storage.Interface, title string) {
+	t.Helper()
+	post := rss.Post{Title: title, PubDate: time.Now().Format(time.RFC1123Z), Link: "http://example.com/" + title}
+	if _, err := db.SavePosts(context.Background(), []rss.Post{post}); err != nil {
+		t.Fatalf("Error saving post: %v", err)
+	}
+}
+
+func TestStreamNews(t *testing.T) {
+	db := storage.NewMemory()
+	savePost(t, db, "first")
+	savePost(t, db, "second")
+
+	api := New(db)
+	router := mux.NewRouter()
+	api.RegisterRoutes(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Переподключение получает публикации, сохранённые после Last-Event-ID
+	resumed := openStream(t, ctx, server.URL, "1")
+	ev := readEvent(t, resumed)
+	var post rss.Post
+	if err := json.Unmarshal([]byte(ev.data), &post); err != nil {
+		t.Fatalf("Error decoding event data: %v", err)
+	}
+	if ev.id != "2" || ev.event != "post" || post.Title != "second" {
+		t.Errorf("Unexpected resumed event: %+v", ev)
+	}
+
+	// Новое подключение начинается с текущего момента и получает только новые публикации
+	live := openStream(t, ctx, server.URL, "")
+	savePost(t, db, "third")
+	api.NotifyNewPosts()
+
+	for name, stream := range map[string]*bufio.Reader{"resumed": resumed, "live": live} {
+		if ev := readEvent(t, stream); ev.id != "3" || !strings.Contains(ev.data, `"third"`) {
+			t.Errorf("Unexpected %s event: %+v", name, ev)
+		}
+	}
+}
+
+func TestCloseStreams(t *testing.T) {
+	db := storage.NewMemory()
+	api := New(db)
+	router := mux.NewRouter()
+	api.RegisterRoutes(router)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream := openStream(t, ctx, server.URL, "")
+
+	api.CloseStreams()
+	if _, err := io.ReadAll(stream); err != nil {
+		t.Errorf("Expected stream to end cleanly, got %v", err)
+	}
+}
+
+func TestStreamNewsInvalidLastEventID(t *testing.T) {
+	router, _ := newTestRouter(t, 1)
+
+	req := httptest.NewRequest(http.MethodGet, "/news/stream", nil)
+	req.Header.Set("Last-Event-ID", "abc")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rec.Code)
+	}
+}
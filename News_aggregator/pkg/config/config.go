@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
@@ -9,12 +10,26 @@ import (
 
 // Config - структура для хранения конфигурации приложения.
 type Config struct {
-	DatabaseURL   string   `json:"database_url"`   // URL для подключения к базе данных
-	RSS           []string `json:"rss"`            // Ссылки на RSS-ленты
-	RequestPeriod int      `json:"request_period"` // Интервал опроса (в минутах)
-	ServerPort    int      `json:"server_port"`    // Порт для запуска сервера
+	Storage       string         `json:"storage"`        // Хранилище: "postgres" (по умолчанию) или "memory"
+	DatabaseURL   string         `json:"database_url"`   // URL для подключения к базе данных
+	RSS           []string       `json:"rss"`            // Ссылки на RSS-ленты
+	RequestPeriod int            `json:"request_period"` // Интервал опроса (в минутах)
+	FeedIntervals map[string]int `json:"feed_intervals"` // Индивидуальные интервалы опроса лент (в минутах)
+	ServerPort    int            `json:"server_port"`    // Порт для запуска сервера
+	AdminToken    string         `json:"admin_token"`    // Токен доступа к /admin; пустой отключает эти маршруты
+	PublicURL     string         `json:"public_url"`     // Внешний адрес агрегатора (шлюза) для ссылок в выходных лентах
 }
 
+// Допустимые значения поля Storage.
+const (
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory"
+)
+
+// adminTokenEnv — переменная окружения, которая переопределяет admin_token,
+// чтобы не хранить токен в config.json.
+const adminTokenEnv = "NEWS_ADMIN_TOKEN"
+
 // LoadConfig загружает конфигурационный файл.
 func LoadConfig(filename string) (*Config, error) {
 	file, err := os.Open(filename)
@@ -33,6 +48,23 @@ func LoadConfig(filename string) (*Config, error) {
 		return nil, err
 	}
 
-	log.Println("Config loaded successfully:", config)
+	switch config.Storage {
+	case "":
+		config.Storage = StoragePostgres
+	case StoragePostgres, StorageMemory:
+	default:
+		return nil, fmt.Errorf("unknown storage %q: expected %q or %q", config.Storage, StoragePostgres, StorageMemory)
+	}
+
+	if token := os.Getenv(adminTokenEnv); token != "" {
+		config.AdminToken = token
+	}
+
+	// Токен не должен попадать в журнал
+	logged := config
+	if logged.AdminToken != "" {
+		logged.AdminToken = "***"
+	}
+	log.Println("Config loaded successfully:", logged)
 	return &config, nil
 }
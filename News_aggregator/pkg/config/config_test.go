@@ -1,6 +1,8 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -18,3 +20,59 @@ func TestLoadConfig(t *testing.T) {
 		t.Error("Request period should be greater than 0")
 	}
 }
+
+func TestLoadConfigStorage(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+		return path
+	}
+
+	config, err := LoadConfig(write("default.json", `{"rss": ["https://example.com/rss"]}`))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.Storage != StoragePostgres {
+		t.Errorf("Expected default storage %q, got %q", StoragePostgres, config.Storage)
+	}
+
+	config, err = LoadConfig(write("memory.json", `{"storage": "memory"}`))
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.Storage != StorageMemory {
+		t.Errorf("Expected storage %q, got %q", StorageMemory, config.Storage)
+	}
+
+	if _, err := LoadConfig(write("unknown.json", `{"storage": "mysql"}`)); err == nil {
+		t.Error("Expected error for unknown storage")
+	}
+}
+
+func TestLoadConfigAdminToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"admin_token": "from-file"}`), 0o644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	t.Setenv(adminTokenEnv, "")
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.AdminToken != "from-file" {
+		t.Errorf("Expected admin token from file, got %q", config.AdminToken)
+	}
+
+	t.Setenv(adminTokenEnv, "from-env")
+	config, err = LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.AdminToken != "from-env" {
+		t.Errorf("Expected admin token from %s, got %q", adminTokenEnv, config.AdminToken)
+	}
+}
@@ -0,0 +1,304 @@
+// Пакет extract извлекает основной текст статьи со страницы публикации
+// для лент, которые отдают в description только анонс.
+//
+// Эвристики повторяют идеи Readability: абзацы начисляют очки своим
+// родителям, классы и id вроде "comment" или "sidebar" штрафуются,
+// а итоговый счёт блока уменьшается пропорционально доле текста в ссылках.
+package extract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"Task36a41/pkg/netguard"
+	"Task36a41/pkg/sanitize"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// ErrNoArticle возвращается, если на странице не нашлось блока, похожего на статью.
+var ErrNoArticle = errors.New("no article found on page")
+
+const (
+	maxPageSize      = 5 << 20 // Ограничение размера загружаемой страницы
+	minParagraphText = 25      // Более короткие абзацы не учитываются
+	minArticleText   = 250     // Минимальная длина текста найденной статьи
+)
+
+// client загружает страницы публикаций. Ссылки берутся из лент, поэтому
+// клиент обращается только к публичным адресам, см. netguard.
+var client = netguard.NewClient(15 * time.Second)
+
+var (
+	// unlikelyPattern — классы и id служебных блоков страницы.
+	unlikelyPattern = regexp.MustCompile(`(?i)comment|sidebar|footer|nav|menu|share|social|related|advert|promo|banner|cookie|subscribe|popup|breadcrumb|widget`)
+	// positivePattern — классы и id блоков с содержанием.
+	positivePattern = regexp.MustCompile(`(?i)article|content|post|entry|body|text|main|story`)
+)
+
+// removedTags — теги, которые не могут быть частью статьи.
+var removedTags = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Iframe: true,
+	atom.Nav: true, atom.Aside: true, atom.Footer: true, atom.Header: true,
+	atom.Form: true, atom.Button: true, atom.Svg: true, atom.Template: true,
+}
+
+// Fetch загружает страницу публикации и возвращает очищенный HTML основного текста статьи.
+// Загружаются только адреса http и https, внутренние адреса отклоняются
+// с ошибкой netguard.ErrForbiddenAddress. Отмена ctx прерывает загрузку.
+func Fetch(ctx context.Context, pageURL string) (string, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid page URL: %v", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("unsupported page URL scheme: %q", u.Scheme)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create page request: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch page: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch page: %s", resp.Status)
+	}
+	// Страница без Content-Type или с нераспознанным типом не разбирается:
+	// это может быть файл любого формата
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("page has invalid content type %q: %v", contentType, err)
+	}
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return "", fmt.Errorf("page is not html: %s", mediaType)
+	}
+
+	// Кодировка определяется по Content-Type, BOM или <meta charset>
+	body, err := charset.NewReader(io.LimitReader(resp.Body, maxPageSize), contentType)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode page: %v", err)
+	}
+	return Article(body, resp.Request.URL)
+}
+
+// Article извлекает основной текст статьи из HTML-страницы. Относительные ссылки
+// и адреса изображений разрешаются относительно pageURL, результат очищается
+// так же, как содержание из лент.
+func Article(r io.Reader, pageURL *url.URL) (string, error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse page: %v", err)
+	}
+
+	base := pageURL
+	if href := findBase(doc); href != "" {
+		if u, err := pageURL.Parse(href); err == nil {
+			base = u
+		}
+	}
+
+	prune(doc)
+	best := bestCandidate(doc)
+	if best == nil || len([]rune(textOf(best))) < minArticleText {
+		return "", ErrNoArticle
+	}
+
+	resolveURLs(best, base)
+	var buf strings.Builder
+	for c := best.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&buf, c); err != nil {
+			return "", fmt.Errorf("failed to render article: %v", err)
+		}
+	}
+	return sanitize.HTML(buf.String()), nil
+}
+
+// findBase возвращает href первого тега <base> или пустую строку.
+func findBase(n *html.Node) string {
+	if n.Type == html.ElementNode && n.DataAtom == atom.Base {
+		return attr(n, "href")
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if href := findBase(c); href != "" {
+			return href
+		}
+	}
+	return ""
+}
+
+// prune удаляет из дерева служебные элементы: скрипты, навигацию,
+// а также блоки, которые по классу или id похожи на комментарии и рекламу.
+func prune(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.CommentNode || (c.Type == html.ElementNode && unlikely(c)) {
+			n.RemoveChild(c)
+		} else {
+			prune(c)
+		}
+		c = next
+	}
+}
+
+// unlikely сообщает, что элемент не может содержать статью.
+func unlikely(n *html.Node) bool {
+	if removedTags[n.DataAtom] {
+		return true
+	}
+	if n.DataAtom == atom.Body || n.DataAtom == atom.Html || n.DataAtom == atom.Article || n.DataAtom == atom.Main {
+		return false
+	}
+	names := attr(n, "class") + " " + attr(n, "id")
+	return unlikelyPattern.MatchString(names) && !positivePattern.MatchString(names)
+}
+
+// bestCandidate начисляет очки блокам, содержащим абзацы, и возвращает блок с наибольшим счётом.
+func bestCandidate(doc *html.Node) *html.Node {
+	scores := make(map[*html.Node]float64)
+	var candidates []*html.Node // В порядке документа, чтобы при равном счёте выбор был устойчивым
+	addScore := func(n *html.Node, score float64) {
+		if n == nil || n.Type != html.ElementNode {
+			return
+		}
+		if _, ok := scores[n]; !ok {
+			scores[n] = initialScore(n)
+			candidates = append(candidates, n)
+		}
+		scores[n] += score
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.DataAtom == atom.P || n.DataAtom == atom.Pre || n.DataAtom == atom.Blockquote) {
+			text := textOf(n)
+			if len([]rune(text)) >= minParagraphText {
+				// Очко за абзац, за каждую запятую и за каждые 100 символов, но не более трёх
+				score := 1 + float64(strings.Count(text, ",")+strings.Count(text, "，"))
+				score += min(float64(len([]rune(text))/100), 3)
+				addScore(n.Parent, score)
+				if n.Parent != nil {
+					addScore(n.Parent.Parent, score/2)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	var best *html.Node
+	var bestScore float64
+	for _, n := range candidates {
+		score := scores[n] * (1 - linkDensity(n))
+		if best == nil || score > bestScore {
+			best, bestScore = n, score
+		}
+	}
+	return best
+}
+
+// initialScore — начальный счёт блока по тегу и по классу и id.
+func initialScore(n *html.Node) float64 {
+	var score float64
+	switch n.DataAtom {
+	case atom.Article, atom.Main:
+		score = 10
+	case atom.Div:
+		score = 5
+	case atom.Pre, atom.Td, atom.Blockquote:
+		score = 3
+	case atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li:
+		score = -3
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+		score = -5
+	}
+	names := attr(n, "class") + " " + attr(n, "id")
+	if positivePattern.MatchString(names) {
+		score += 25
+	}
+	if unlikelyPattern.MatchString(names) {
+		score -= 25
+	}
+	return score
+}
+
+// linkDensity возвращает долю текста блока, находящуюся внутри ссылок.
+func linkDensity(n *html.Node) float64 {
+	total := len([]rune(textOf(n)))
+	if total == 0 {
+		return 0
+	}
+	var links int
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.A {
+			links += len([]rune(textOf(n)))
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return float64(links) / float64(total)
+}
+
+// resolveURLs делает ссылки и адреса изображений в блоке абсолютными.
+func resolveURLs(n *html.Node, base *url.URL) {
+	if n.Type == html.ElementNode {
+		for i, a := range n.Attr {
+			if a.Key != "href" && a.Key != "src" {
+				continue
+			}
+			if u, err := base.Parse(strings.TrimSpace(a.Val)); err == nil {
+				n.Attr[i].Val = u.String()
+			}
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		resolveURLs(c, base)
+	}
+}
+
+// textOf возвращает текст узла со схлопнутыми пробелами.
+func textOf(n *html.Node) string {
+	var buf strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			buf.WriteString(n.Data)
+			buf.WriteString(" ")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+// attr возвращает значение атрибута элемента или пустую строку.
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,115 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"Task36a41/pkg/netguard"
+)
+
+func TestArticle(t *testing.T) {
+	f, err := os.Open("testdata/article.html")
+	if err != nil {
+		t.Fatalf("не удалось открыть страницу: %v", err)
+	}
+	defer f.Close()
+
+	pageURL, _ := url.Parse("https://blog.example.com/posts/goroutines")
+	article, err := Article(f, pageURL)
+	if err != nil {
+		t.Fatalf("ошибка извлечения статьи: %v", err)
+	}
+
+	for _, want := range []string{
+		"Планировщик Go распределяет горутины",
+		"украсть половину работы",
+		`<img src="https://blog.example.com/posts/scheduler.png" alt="Схема планировщика">`,
+		`<a href="https://blog.example.com/netpoll" target="_blank" rel="noopener noreferrer">`,
+	} {
+		if !strings.Contains(article, want) {
+			t.Errorf("в статье нет %q:\n%s", want, article)
+		}
+	}
+	for _, unwanted := range []string{"Подпишитесь", "Отличная статья", "О нас", "track(", "onerror", "Все права"} {
+		if strings.Contains(article, unwanted) {
+			t.Errorf("в статью попал лишний фрагмент %q:\n%s", unwanted, article)
+		}
+	}
+}
+
+func TestArticleNotFound(t *testing.T) {
+	pageURL, _ := url.Parse("https://example.com/")
+	_, err := Article(strings.NewReader(`<html><body><p>Коротко.</p></body></html>`), pageURL)
+	if err != ErrNoArticle {
+		t.Errorf("ожидалась ошибка ErrNoArticle, получено %v", err)
+	}
+}
+
+// allowLoopback разрешает загрузку страниц с тестовых серверов на 127.0.0.1.
+func allowLoopback(t *testing.T) {
+	t.Helper()
+	saved := client
+	client = &http.Client{Timeout: 15 * time.Second}
+	t.Cleanup(func() { client = saved })
+}
+
+func TestFetch(t *testing.T) {
+	allowLoopback(t)
+	page, err := os.ReadFile("testdata/article.html")
+	if err != nil {
+		t.Fatalf("не удалось прочитать страницу: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/article":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write(page)
+		case "/feed":
+			w.Header().Set("Content-Type", "application/rss+xml")
+			w.Write([]byte(`<rss version="2.0"></rss>`))
+		case "/untyped":
+			// Без заголовка net/http сам определил бы тип как text/html
+			w.Header()["Content-Type"] = nil
+			w.Write(page)
+		case "/invalid":
+			w.Header().Set("Content-Type", "text/html; charset")
+			w.Write(page)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	article, err := Fetch(context.Background(), server.URL+"/article")
+	if err != nil || !strings.Contains(article, "Планировщик Go") {
+		t.Errorf("не удалось загрузить статью: %v\n%s", err, article)
+	}
+	for _, path := range []string{"/feed", "/untyped", "/invalid", "/missing"} {
+		if _, err := Fetch(context.Background(), server.URL+path); err == nil {
+			t.Errorf("%s: ожидалась ошибка", path)
+		}
+	}
+}
+
+func TestFetchForbidden(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("запрос не должен был дойти до сервера на 127.0.0.1")
+	}))
+	defer server.Close()
+
+	if _, err := Fetch(context.Background(), server.URL+"/article"); !errors.Is(err, netguard.ErrForbiddenAddress) {
+		t.Errorf("ожидался отказ загружать страницу с 127.0.0.1, получено %v", err)
+	}
+	for _, link := range []string{"file:///etc/passwd", "ftp://example.com/article", "javascript:alert(1)", "/relative"} {
+		if _, err := Fetch(context.Background(), link); err == nil || !strings.Contains(err.Error(), "scheme") {
+			t.Errorf("%s: ожидалась ошибка схемы адреса, получено %v", link, err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Пакет metrics собирает метрики сервиса новостей в формате Prometheus:
+// запросы к API, опрос лент и запросы к базе данных. Метрики регистрируются
+// в реестре по умолчанию и отдаются обработчиком Handler.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Результаты опроса ленты.
+const (
+	FetchSuccess     = "success"      // Лента загружена и сохранена
+	FetchNotModified = "not_modified" // Сервер ответил 304
+	FetchError       = "error"        // Ошибка загрузки, разбора или сохранения
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "news_http_requests_total",
+		Help: "Количество обработанных HTTP-запросов по маршруту, методу и коду ответа.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "news_http_request_duration_seconds",
+		Help:    "Время обработки HTTP-запросов по маршруту, методу и коду ответа.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	feedFetches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "news_feed_fetches_total",
+		Help: "Количество опросов лент по источнику и результату.",
+	}, []string{"source", "result"})
+
+	feedItems = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "news_feed_items",
+		Help: "Количество публикаций в ленте при последнем успешном опросе.",
+	}, []string{"source"})
+
+	feedInserted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "news_feed_posts_inserted_total",
+		Help: "Количество новых публикаций, сохранённых из ленты.",
+	}, []string{"source"})
+
+	dbQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "news_db_query_duration_seconds",
+		Help:    "Время выполнения операций с базой данных.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"query"})
+)
+
+// Handler возвращает обработчик /metrics.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveRequest учитывает обработанный HTTP-запрос. route — шаблон маршрута,
+// а не путь запроса, чтобы число рядов не зависело от параметров в пути.
+func ObserveRequest(route, method string, status int, duration time.Duration) {
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(route, method, code).Inc()
+	httpDuration.WithLabelValues(route, method, code).Observe(duration.Seconds())
+}
+
+// ObserveFetch учитывает опрос ленты с результатом FetchSuccess, FetchNotModified или FetchError.
+func ObserveFetch(source, result string) {
+	feedFetches.WithLabelValues(source, result).Inc()
+}
+
+// ObserveItems учитывает публикации успешно опрошенной ленты: сколько их
+// было в ленте и сколько из них новых.
+func ObserveItems(source string, items, inserted int) {
+	feedItems.WithLabelValues(source).Set(float64(items))
+	feedInserted.WithLabelValues(source).Add(float64(inserted))
+}
+
+// ObserveQuery начинает замер операции с базой данных и возвращает функцию,
+// которая его завершает:
+//
+//	defer metrics.ObserveQuery("get_sources")()
+func ObserveQuery(query string) func() {
+	start := time.Now()
+	return func() {
+		dbQueryDuration.WithLabelValues(query).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ForgetSource удаляет ряды удалённой ленты, чтобы её последние значения
+// не оставались в выдаче.
+func ForgetSource(source string) {
+	feedItems.DeleteLabelValues(source)
+}
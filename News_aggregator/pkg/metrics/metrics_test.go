@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserve(t *testing.T) {
+	ObserveRequest("/news/{n}", http.MethodGet, http.StatusOK, 20*time.Millisecond)
+	ObserveRequest("/news/{n}", http.MethodGet, http.StatusOK, 30*time.Millisecond)
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues("/news/{n}", "GET", "200")); got != 2 {
+		t.Errorf("ожидалось 2 запроса, получено %v", got)
+	}
+
+	ObserveFetch("http://example.com/feed", FetchError)
+	ObserveItems("http://example.com/feed", 10, 3)
+	ObserveItems("http://example.com/feed", 12, 2)
+	if got := testutil.ToFloat64(feedFetches.WithLabelValues("http://example.com/feed", FetchError)); got != 1 {
+		t.Errorf("ожидалась 1 ошибка опроса, получено %v", got)
+	}
+	if got := testutil.ToFloat64(feedItems.WithLabelValues("http://example.com/feed")); got != 12 {
+		t.Errorf("ожидалось 12 публикаций в ленте, получено %v", got)
+	}
+	if got := testutil.ToFloat64(feedInserted.WithLabelValues("http://example.com/feed")); got != 5 {
+		t.Errorf("ожидалось 5 новых публикаций, получено %v", got)
+	}
+
+	ObserveQuery("get_sources")()
+	if got := testutil.CollectAndCount(dbQueryDuration, "news_db_query_duration_seconds"); got != 1 {
+		t.Errorf("ожидался 1 ряд длительности запросов, получено %d", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	ObserveFetch("http://example.com/handler", FetchSuccess)
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, `news_feed_fetches_total{result="success",source="http://example.com/handler"} 1`) {
+		t.Errorf("метрика опроса не найдена в выдаче:\n%s", body)
+	}
+	if !strings.Contains(body, "go_goroutines") {
+		t.Error("ожидались метрики среды выполнения Go")
+	}
+}
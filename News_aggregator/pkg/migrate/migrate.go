@@ -0,0 +1,135 @@
+// Пакет migrate применяет версионированные миграции схемы PostgreSQL.
+// Им пользуются хранилище новостей и сервис комментариев: каждый встраивает
+// свои миграции в бинарный файл и передаёт их сюда вместе с ключом блокировки.
+//
+// Имя файла миграции имеет вид NNNN_описание.sql, где NNNN — номер версии.
+// Применённые версии записываются в таблицу schema_migrations; schema.sql сервиса,
+// разворачивающий схему вручную, записывает их туда же, поэтому сервер
+// не применяет миграции повторно.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migration описывает одну миграцию схемы.
+type Migration struct {
+	Version int
+	Name    string // Имя файла
+	SQL     string
+}
+
+// Load читает миграции из каталога dir и сортирует их по версии.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	files, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read migrations: %v", err)
+	}
+
+	var migrations []Migration
+	seen := make(map[int]string)
+	for _, file := range files {
+		name := file.Name()
+		prefix, _, ok := strings.Cut(name, "_")
+		if !ok || !strings.HasSuffix(name, ".sql") {
+			return nil, fmt.Errorf("invalid migration file name: %s", name)
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %v", name, err)
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, other, name)
+		}
+		seen[version] = name
+
+		body, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("could not read migration %s: %v", name, err)
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(body)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Apply применяет к базе данных ещё не применённые миграции.
+// Каждая миграция выполняется в отдельной транзакции вместе с записью в schema_migrations.
+// lockID — ключ advisory-блокировки, чтобы несколько экземпляров сервиса
+// не применяли миграции одновременно; у каждого сервиса он свой.
+func Apply(db *sql.DB, migrations []Migration, lockID int64) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get connection: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("could not acquire migration lock: %v", err)
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, lockID)
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("could not create schema_migrations table: %v", err)
+	}
+
+	applied := make(map[int]bool)
+	rows, err := conn.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return fmt.Errorf("could not get applied migrations: %v", err)
+	}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return fmt.Errorf("could not scan migration version: %v", err)
+		}
+		applied[version] = true
+	}
+	rows.Close()
+
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+		if err := applyOne(ctx, conn, m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyOne выполняет одну миграцию и отмечает её как применённую.
+func applyOne(ctx context.Context, conn *sql.Conn, m Migration) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %v", err)
+	}
+	defer tx.Rollback() // откатим транзакцию в случае ошибки
+
+	if _, err := tx.ExecContext(ctx, m.SQL); err != nil {
+		return fmt.Errorf("could not apply migration %s: %v", m.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name); err != nil {
+		return fmt.Errorf("could not record migration %s: %v", m.Name, err)
+	}
+
+	return tx.Commit()
+}
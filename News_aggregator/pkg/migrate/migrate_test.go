@@ -0,0 +1,47 @@
+package migrate
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/0002_add_column.sql": {Data: []byte("ALTER TABLE t ADD COLUMN c INT;")},
+		"migrations/0001_create.sql":     {Data: []byte("CREATE TABLE t (id INT);")},
+		"migrations/0010_index.sql":      {Data: []byte("CREATE INDEX ON t (c);")},
+	}
+	migrations, err := Load(fsys, "migrations")
+	if err != nil {
+		t.Fatalf("ошибка загрузки миграций: %v", err)
+	}
+
+	var names []string
+	for _, m := range migrations {
+		names = append(names, m.Name)
+	}
+	if got := strings.Join(names, ", "); got != "0001_create.sql, 0002_add_column.sql, 0010_index.sql" {
+		t.Errorf("миграции должны идти по версиям, получено: %s", got)
+	}
+	if migrations[2].Version != 10 || migrations[0].SQL != "CREATE TABLE t (id INT);" {
+		t.Errorf("неверно разобрана миграция: %+v", migrations[2])
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"без номера версии": {"migrations/create.sql": {}},
+		"версия не число":   {"migrations/abc_create.sql": {}},
+		"не SQL":            {"migrations/0001_create.txt": {}},
+		"повтор версии": {
+			"migrations/0001_create.sql": {},
+			"migrations/0001_other.sql":  {},
+		},
+	}
+	for name, fsys := range cases {
+		if _, err := Load(fsys, "migrations"); err == nil {
+			t.Errorf("%s: ожидалась ошибка", name)
+		}
+	}
+}
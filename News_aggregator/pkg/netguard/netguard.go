@@ -0,0 +1,56 @@
+// Пакет netguard не даёт сервису обращаться к внутренней сети по адресам,
+// полученным извне: ссылкам из лент, адресам источников и страниц для поиска лент.
+package netguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress возвращается, если адрес, в том числе после редиректа,
+// разрешается во внутренний адрес: loopback, частную сеть, link-local и т. п.
+var ErrForbiddenAddress = errors.New("address is not public")
+
+// sharedAddressSpace — диапазон 100.64.0.0/10 (RFC 6598) для адресов за NAT провайдера.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// NewClient возвращает HTTP-клиент, который соединяется только с публичными адресами.
+// Адрес проверяется при установке соединения, то есть уже после разрешения имени
+// в DNS, поэтому его не обойти ни редиректом, ни подменой DNS-записи.
+// Прокси из окружения не используется: иначе проверялся бы адрес прокси.
+func NewClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout: 5 * time.Second,
+				Control: rejectInternalAddress,
+			}).DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+		},
+	}
+}
+
+// rejectInternalAddress запрещает соединения с адресами, не доступными из интернета.
+func rejectInternalAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || sharedAddressSpace.Contains(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, ip)
+	}
+	return nil
+}
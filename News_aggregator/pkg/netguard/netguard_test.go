@@ -0,0 +1,39 @@
+package netguard
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRejectInternalAddress(t *testing.T) {
+	forbidden := []string{
+		"127.0.0.1:80", "10.1.2.3:80", "172.16.0.1:80", "192.168.1.1:443", "169.254.169.254:80",
+		"0.0.0.0:80", "100.64.0.1:80", "224.0.0.1:80", "[::1]:80", "[fe80::1]:80", "[fd00::1]:80",
+		"[::ffff:127.0.0.1]:80",
+	}
+	for _, address := range forbidden {
+		if err := rejectInternalAddress("tcp", address, nil); !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("адрес %s должен быть запрещён, получено %v", address, err)
+		}
+	}
+	for _, address := range []string{"93.184.216.34:80", "[2606:4700:4700::1111]:443"} {
+		if err := rejectInternalAddress("tcp", address, nil); err != nil {
+			t.Errorf("адрес %s должен быть разрешён, получено %v", address, err)
+		}
+	}
+}
+
+func TestClientRefusesLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("запрос не должен был дойти до сервера на 127.0.0.1")
+	}))
+	defer server.Close()
+
+	_, err := NewClient(time.Second).Get(server.URL)
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("ожидалась ошибка ErrForbiddenAddress, получено %v", err)
+	}
+}
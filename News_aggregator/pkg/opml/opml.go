@@ -0,0 +1,206 @@
+// Package opml читает и записывает списки подписок в формате OPML 2.0,
+// которым обмениваются программы для чтения лент.
+package opml
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"Task36a41/pkg/storage"
+)
+
+// OPML описывает документ OPML.
+type OPML struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    Head     `xml:"head"`
+	Body    Body     `xml:"body"`
+}
+
+// Head содержит метаданные документа.
+type Head struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+// Body содержит outline-элементы верхнего уровня.
+type Body struct {
+	Outlines []Outline `xml:"outline"`
+}
+
+// Outline — подписка на ленту (с атрибутом xmlUrl) или папка с вложенными элементами.
+type Outline struct {
+	Text     string    `xml:"text,attr"`
+	Title    string    `xml:"title,attr,omitempty"`
+	Type     string    `xml:"type,attr,omitempty"`
+	XMLURL   string    `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string    `xml:"htmlUrl,attr,omitempty"`
+	Category string    `xml:"category,attr,omitempty"`
+	Outlines []Outline `xml:"outline"`
+}
+
+// Feed — подписка из документа OPML.
+type Feed struct {
+	URL      string // Адрес ленты
+	Title    string // Название ленты
+	Category string // Путь вложенных папок через "/", например "Tech/Go"
+}
+
+// Parse читает документ OPML.
+func Parse(r io.Reader) (*OPML, error) {
+	var doc OPML
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse OPML: %v", err)
+	}
+	return &doc, nil
+}
+
+// Feeds возвращает все подписки документа. Категорией подписки становится
+// путь вложенных папок; для подписок вне папок используется атрибут category.
+func (doc *OPML) Feeds() []Feed {
+	var feeds []Feed
+	collectFeeds(doc.Body.Outlines, nil, &feeds)
+	return feeds
+}
+
+// collectFeeds обходит outline-элементы и собирает подписки в feeds.
+func collectFeeds(outlines []Outline, path []string, feeds *[]Feed) {
+	for _, o := range outlines {
+		if o.XMLURL != "" {
+			category := strings.Join(path, "/")
+			if category == "" {
+				category = firstCategory(o.Category)
+			}
+			*feeds = append(*feeds, Feed{
+				URL:      strings.TrimSpace(o.XMLURL),
+				Title:    firstNonEmpty(o.Title, o.Text),
+				Category: category,
+			})
+			continue
+		}
+		folder := firstNonEmpty(o.Text, o.Title)
+		collectFeeds(o.Outlines, append(path[:len(path):len(path)], folder), feeds)
+	}
+}
+
+// firstCategory возвращает первую категорию из атрибута category,
+// который содержит список путей через запятую, например "/Tech/Go,/News".
+func firstCategory(value string) string {
+	first := strings.Split(value, ",")[0]
+	return strings.Trim(strings.TrimSpace(first), "/")
+}
+
+// firstNonEmpty возвращает первую непустую строку.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// New создаёт документ с подписками, разложенными по папкам согласно их категориям.
+// Подписки без категории находятся на верхнем уровне.
+func New(title string, feeds []Feed, created time.Time) *OPML {
+	doc := &OPML{
+		Version: "2.0",
+		Head:    Head{Title: title, DateCreated: created.Format(time.RFC1123Z)},
+	}
+
+	sorted := append([]Feed(nil), feeds...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Category < sorted[j].Category })
+
+	for _, feed := range sorted {
+		outlines := &doc.Body.Outlines
+		if feed.Category != "" {
+			for _, folder := range strings.Split(feed.Category, "/") {
+				outlines = &folderOutline(outlines, folder).Outlines
+			}
+		}
+		title := firstNonEmpty(feed.Title, feed.URL)
+		*outlines = append(*outlines, Outline{Text: title, Title: title, Type: "rss", XMLURL: feed.URL})
+	}
+	return doc
+}
+
+// folderOutline возвращает папку с заданным названием среди outlines, создавая её при необходимости.
+func folderOutline(outlines *[]Outline, name string) *Outline {
+	for i := range *outlines {
+		if o := &(*outlines)[i]; o.XMLURL == "" && o.Text == name {
+			return o
+		}
+	}
+	*outlines = append(*outlines, Outline{Text: name})
+	return &(*outlines)[len(*outlines)-1]
+}
+
+// Write записывает документ с XML-заголовком.
+func (doc *OPML) Write(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write OPML: %v", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// ImportReport — итог импорта подписок.
+type ImportReport struct {
+	Added    int          `json:"added"`    // Количество добавленных источников
+	Existing int          `json:"existing"` // Количество уже известных источников
+	Skipped  []SkippedURL `json:"skipped"`  // Подписки, которые не удалось импортировать
+}
+
+// SkippedURL описывает подписку, которую не удалось импортировать.
+type SkippedURL struct {
+	URL    string `json:"url"`
+	Reason string `json:"reason"`
+}
+
+// Import добавляет подписки документа в хранилище как включённые источники.
+// Уже известные источники не изменяются, подписки с некорректным адресом пропускаются.
+func Import(ctx context.Context, store storage.SourceStore, doc *OPML) (ImportReport, error) {
+	var report ImportReport
+	for _, feed := range doc.Feeds() {
+		if err := storage.ValidateSourceURL(feed.URL); err != nil {
+			report.Skipped = append(report.Skipped, SkippedURL{URL: feed.URL, Reason: err.Error()})
+			continue
+		}
+
+		_, err := store.AddSource(ctx, storage.Source{URL: feed.URL, Name: feed.Title, Enabled: true, Category: feed.Category})
+		switch {
+		case errors.Is(err, storage.ErrSourceExists):
+			report.Existing++
+		case err != nil:
+			return report, err
+		default:
+			report.Added++
+		}
+	}
+	return report, nil
+}
+
+// Export создаёт документ со всеми источниками хранилища.
+func Export(ctx context.Context, store storage.SourceStore, title string, now time.Time) (*OPML, error) {
+	sources, err := store.GetSources(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	feeds := make([]Feed, 0, len(sources))
+	for _, source := range sources {
+		feeds = append(feeds, Feed{URL: source.URL, Title: source.Name, Category: source.Category})
+	}
+	return New(title, feeds, now), nil
+}
@@ -0,0 +1,117 @@
+package opml
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"Task36a41/pkg/storage"
+)
+
+func parseFixture(t *testing.T) *OPML {
+	t.Helper()
+	f, err := os.Open("testdata/subscriptions.opml")
+	if err != nil {
+		t.Fatalf("Error opening fixture: %v", err)
+	}
+	defer f.Close()
+
+	doc, err := Parse(f)
+	if err != nil {
+		t.Fatalf("Error parsing OPML: %v", err)
+	}
+	return doc
+}
+
+func TestFeeds(t *testing.T) {
+	feeds := parseFixture(t).Feeds()
+
+	want := []Feed{
+		{URL: "https://habr.com/ru/rss/hub/go/all/?fl=ru", Title: "Хабр: Go", Category: "Tech/Go"},
+		{URL: "https://cprss.s3.amazonaws.com/golangweekly.com.xml", Title: "Golang Weekly", Category: "Tech/Go"},
+		{URL: "https://go.dev/blog/feed.atom", Title: "Go Blog", Category: "Tech"},
+		{URL: "https://habr.com/ru/rss/best/daily/?fl=ru", Title: "Хабр: лучшее", Category: "News/Daily"},
+		{URL: "not a url", Title: "Broken", Category: ""},
+	}
+	if !reflect.DeepEqual(feeds, want) {
+		t.Errorf("Unexpected feeds:\n got %+v\nwant %+v", feeds, want)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	if _, err := Parse(strings.NewReader("<opml><body>")); err == nil {
+		t.Error("Expected error for malformed OPML")
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	feeds := []Feed{
+		{URL: "https://example.com/top.xml", Title: "Top"},
+		{URL: "https://example.com/go.xml", Title: "Go", Category: "Tech/Go"},
+		{URL: "https://example.com/rust.xml", Category: "Tech/Rust"},
+		{URL: "https://example.com/tech.xml", Title: "Tech news", Category: "Tech"},
+	}
+	doc := New("Export", feeds, time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC))
+
+	var buf bytes.Buffer
+	if err := doc.Write(&buf); err != nil {
+		t.Fatalf("Error writing OPML: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), `<?xml version="1.0" encoding="UTF-8"?>`) || !strings.Contains(buf.String(), `<opml version="2.0">`) {
+		t.Errorf("Unexpected document header:\n%s", buf.String())
+	}
+
+	parsed, err := Parse(&buf)
+	if err != nil {
+		t.Fatalf("Error parsing written OPML: %v", err)
+	}
+	if len(parsed.Body.Outlines) != 2 {
+		t.Errorf("Expected one top-level feed and one folder, got %+v", parsed.Body.Outlines)
+	}
+
+	got := map[string]Feed{}
+	for _, feed := range parsed.Feeds() {
+		got[feed.URL] = feed
+	}
+	for _, feed := range feeds {
+		if feed.Title == "" {
+			feed.Title = feed.URL
+		}
+		if got[feed.URL] != feed {
+			t.Errorf("Expected %+v after round trip, got %+v", feed, got[feed.URL])
+		}
+	}
+}
+
+func TestImportExport(t *testing.T) {
+	store := storage.NewMemory()
+	store.AddSource(context.Background(), storage.Source{URL: "https://go.dev/blog/feed.atom", Enabled: false})
+
+	report, err := Import(context.Background(), store, parseFixture(t))
+	if err != nil {
+		t.Fatalf("Error importing OPML: %v", err)
+	}
+	if report.Added != 3 || report.Existing != 1 || len(report.Skipped) != 1 || report.Skipped[0].URL != "not a url" {
+		t.Errorf("Unexpected import report: %+v", report)
+	}
+
+	sources, _ := store.GetSources(context.Background())
+	if sources[0].Enabled {
+		t.Error("Expected existing source to stay unchanged")
+	}
+	if sources[1].Category != "Tech/Go" || sources[1].Name != "Хабр: Go" || !sources[1].Enabled {
+		t.Errorf("Unexpected imported source: %+v", sources[1])
+	}
+
+	doc, err := Export(context.Background(), store, "Export", time.Now())
+	if err != nil {
+		t.Fatalf("Error exporting OPML: %v", err)
+	}
+	if feeds := doc.Feeds(); len(feeds) != 4 {
+		t.Errorf("Expected 4 exported feeds, got %+v", feeds)
+	}
+}
@@ -0,0 +1,136 @@
+package rss
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AtomFeed описывает структуру ленты в формате Atom 1.0.
+type AtomFeed struct {
+	Title   string      `xml:"title"` // Название ленты
+	Entries []AtomEntry `xml:"entry"` // Массив записей
+}
+
+// AtomEntry описывает одну запись ленты Atom.
+type AtomEntry struct {
+	ID         string         `xml:"id"`        // Постоянный идентификатор записи
+	Authors    []AtomPerson   `xml:"author"`    // Авторы записи
+	Categories []AtomCategory `xml:"category"`  // Категории записи
+	Title      AtomText       `xml:"title"`     // Заголовок записи
+	Links      []AtomLink     `xml:"link"`      // Ссылки записи
+	Updated    string         `xml:"updated"`   // Дата последнего изменения
+	Published  string         `xml:"published"` // Дата первой публикации
+	Summary    AtomText       `xml:"summary"`   // Краткое содержание
+	Content    AtomText       `xml:"content"`   // Полное содержание
+}
+
+// AtomLink описывает элемент <link> записи Atom.
+type AtomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+// AtomPerson описывает автора записи Atom.
+type AtomPerson struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email"`
+}
+
+// AtomCategory описывает категорию записи Atom.
+type AtomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr"`
+}
+
+// AtomText описывает текстовую конструкцию Atom (text, html или xhtml).
+type AtomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+// String возвращает содержимое текстовой конструкции с учётом её типа.
+func (t AtomText) String() string {
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.Inner)
+	}
+	return strings.TrimSpace(t.Text)
+}
+
+// enclosures возвращает вложения записи — ссылки с rel="enclosure".
+func (e AtomEntry) enclosures() []Enclosure {
+	var result []Enclosure
+	for _, l := range e.Links {
+		if l.Rel == "enclosure" {
+			result = appendEnclosure(result, l.Href, l.Type, l.Length)
+		}
+	}
+	return result
+}
+
+// author возвращает имена авторов записи через запятую.
+func (e AtomEntry) author() string {
+	var names []string
+	for _, a := range e.Authors {
+		if name := firstNonEmpty(strings.TrimSpace(a.Name), strings.TrimSpace(a.Email)); name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// categories возвращает категории записи: подпись, а если её нет — термин.
+func (e AtomEntry) categories() []string {
+	values := make([]string, 0, len(e.Categories))
+	for _, c := range e.Categories {
+		values = append(values, firstNonEmpty(c.Label, c.Term))
+	}
+	return cleanCategories(values)
+}
+
+// link возвращает ссылку на оригинальную статью: rel="alternate" или ссылку без rel.
+func (e AtomEntry) link() string {
+	for _, l := range e.Links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			return l.Href
+		}
+	}
+	if len(e.Links) > 0 {
+		return e.Links[0].Href
+	}
+	return ""
+}
+
+// parseAtom разбирает ленту в формате Atom 1.0 и приводит записи к Post.
+func parseAtom(data []byte, fetchedAt time.Time) (*Feed, error) {
+	var feed AtomFeed
+	if err := unmarshalXML(data, &feed); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal Atom XML: %v", err)
+	}
+
+	posts := make([]Post, 0, len(feed.Entries))
+	for _, entry := range feed.Entries {
+		post := Post{
+			Title:      entry.Title.String(),
+			Link:       entry.link(),
+			Content:    entry.Summary.String(),
+			GUID:       strings.TrimSpace(entry.ID),
+			Author:     entry.author(),
+			Categories: entry.categories(),
+			Enclosures: entry.enclosures(),
+		}
+		if post.Content == "" {
+			post.Content = entry.Content.String()
+		}
+
+		// В Atom даты записываются в формате RFC 3339
+		post.PubDate = postDate(post.Title, fetchedAt, entry.Published, entry.Updated)
+
+		posts = append(posts, post)
+	}
+
+	return &Feed{Title: feed.Title, Posts: posts}, nil
+}
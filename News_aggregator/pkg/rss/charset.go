@@ -0,0 +1,59 @@
+package rss
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"regexp"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+)
+
+// unmarshalXML разбирает XML-документ в v. Кодировки, отличные от UTF-8
+// (например, windows-1251 и koi8-r), определяются по объявлению <?xml encoding="...">.
+func unmarshalXML(data []byte, v interface{}) error {
+	return newXMLDecoder(data).Decode(v)
+}
+
+// newXMLDecoder создаёт XML-декодер, понимающий кодировки из объявления документа.
+func newXMLDecoder(data []byte) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = charset.NewReaderLabel
+	return decoder
+}
+
+// xmlEncodingDecl находит атрибут encoding в объявлении XML в начале документа.
+var xmlEncodingDecl = regexp.MustCompile(`^(\s*<\?xml[^>]*?encoding\s*=\s*)(?:"[^"]*"|'[^']*')`)
+
+// decodeCharset перекодирует тело ответа в UTF-8 согласно параметру charset
+// заголовка Content-Type, который по RFC 7303 важнее объявления в самом документе.
+// После перекодирования объявление XML исправляется на UTF-8, чтобы декодер
+// не перекодировал документ повторно. Если charset не указан или неизвестен,
+// тело возвращается без изменений. Заявленный UTF-8 игнорируется, если тело
+// им не является: такие серверы обычно просто не настроены, и тогда верить
+// стоит объявлению в документе.
+func decodeCharset(body []byte, contentType string) ([]byte, error) {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil || params["charset"] == "" {
+		return body, nil
+	}
+
+	enc, name := charset.Lookup(params["charset"])
+	if enc == nil {
+		return body, nil
+	}
+	if name == "utf-8" {
+		if !utf8.Valid(body) {
+			return body, nil
+		}
+	} else {
+		decoded, err := enc.NewDecoder().Bytes(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s body: %v", name, err)
+		}
+		body = decoded
+	}
+	return xmlEncodingDecl.ReplaceAll(body, []byte(`${1}"UTF-8"`)), nil
+}
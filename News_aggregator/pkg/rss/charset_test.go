@@ -0,0 +1,95 @@
+package rss
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const (
+	wantCharsetTitle   = "Ёжик в тумане: премьера реставрированной версии"
+	wantCharsetContent = "Съешь же ещё этих мягких французских булок, да выпей чаю."
+)
+
+// checkCharsetFeed проверяет, что русский текст ленты разобран без искажений.
+func checkCharsetFeed(t *testing.T, feed *Feed, wantTitle string) {
+	t.Helper()
+	if feed.Title != wantTitle {
+		t.Errorf("заголовок ленты: ожидалось %q, получено %q", wantTitle, feed.Title)
+	}
+	if len(feed.Posts) != 1 {
+		t.Fatalf("ожидалась 1 публикация, получено %d", len(feed.Posts))
+	}
+	if feed.Posts[0].Title != wantCharsetTitle || feed.Posts[0].Content != wantCharsetContent {
+		t.Errorf("текст публикации искажён: %+v", feed.Posts[0])
+	}
+}
+
+func TestParseFeedDeclaredCharset(t *testing.T) {
+	tests := []struct {
+		fixture string
+		title   string
+	}{
+		{"windows1251.xml", "Новости windows-1251"},
+		{"koi8r.xml", "Новости KOI8-R"},
+	}
+	for _, tt := range tests {
+		feed, err := ParseFeed(readFixture(t, tt.fixture))
+		if err != nil {
+			t.Fatalf("%s: ошибка разбора: %v", tt.fixture, err)
+		}
+		checkCharsetFeed(t, feed, tt.title)
+	}
+}
+
+func TestFetchRSSContentTypeCharset(t *testing.T) {
+	allowLoopback(t)
+	tests := []struct {
+		fixture     string
+		contentType string
+	}{
+		// Кодировку сообщает только заголовок
+		{"windows1251_nodecl.xml", "application/rss+xml; charset=windows-1251"},
+		// Заголовок и объявление совпадают
+		{"koi8r.xml", "text/xml; charset=koi8-r"},
+		// Сервер ошибочно заявляет UTF-8, верим объявлению в документе
+		{"windows1251.xml", "text/xml; charset=utf-8"},
+		// Кодировка в заголовке не указана
+		{"windows1251.xml", "application/rss+xml"},
+	}
+	for _, tt := range tests {
+		data := readFixture(t, tt.fixture)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", tt.contentType)
+			w.Write(data)
+		}))
+
+		feed, _, err := FetchRSSConditional(context.Background(), server.URL, Validators{})
+		server.Close()
+		if err != nil {
+			t.Fatalf("%s (%s): ошибка загрузки: %v", tt.fixture, tt.contentType, err)
+		}
+		if !strings.HasPrefix(feed.Title, "Новости ") {
+			t.Errorf("%s (%s): заголовок ленты искажён: %q", tt.fixture, tt.contentType, feed.Title)
+		}
+		checkCharsetFeed(t, feed, feed.Title)
+	}
+}
+
+func TestDecodeCharset(t *testing.T) {
+	body := []byte("<?xml version='1.0' encoding='windows-1251'?><rss/>")
+	got, err := decodeCharset(body, "text/xml; charset=windows-1251")
+	if err != nil {
+		t.Fatalf("ошибка перекодирования: %v", err)
+	}
+	if string(got) != `<?xml version='1.0' encoding="UTF-8"?><rss/>` {
+		t.Errorf("объявление кодировки не исправлено: %s", got)
+	}
+
+	// Неизвестная кодировка — тело не меняется
+	if got, _ := decodeCharset(body, "text/xml; charset=x-unknown"); string(got) != string(body) {
+		t.Errorf("тело изменено при неизвестной кодировке: %s", got)
+	}
+}
@@ -0,0 +1,127 @@
+package rss
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ErrNoDate возвращается ParseDate для пустой даты.
+var ErrNoDate = errors.New("no publication date")
+
+// isoLayouts — варианты ISO 8601 / RFC 3339 (Atom updated, dc:date, JSON Feed).
+// Дата без часового пояса считается датой в UTC.
+var isoLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// rfc822Layouts — варианты RFC 822 / RFC 1123 (pubDate в RSS 2.0) без дня недели:
+// с двузначным или четырёхзначным годом, с секундами или без, с сокращённым
+// или полным названием месяца, с числовым часовым поясом или без него.
+var rfc822Layouts = []string{
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 -07:00",
+	"2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04 -0700",
+	"2 Jan 2006 15:04 MST",
+	"2 Jan 2006 15:04:05",
+	"2 Jan 06 15:04:05 -0700",
+	"2 Jan 06 15:04:05 MST",
+	"2 Jan 06 15:04 -0700",
+	"2 Jan 06 15:04 MST",
+	"2 January 2006 15:04:05 -0700",
+	"2 January 2006 15:04:05 MST",
+	"2 January 2006 15:04 -0700",
+}
+
+// zoneOffsets — смещения часовых поясов, которые встречаются в лентах буквенными
+// сокращениями. Go знает только пояса из локальной базы, а остальные
+// сокращения разбирает с нулевым смещением, поэтому заменяем их заранее.
+var zoneOffsets = map[string]string{
+	"UT": "+0000", "UTC": "+0000", "GMT": "+0000", "Z": "+0000",
+	"EST": "-0500", "EDT": "-0400",
+	"CST": "-0600", "CDT": "-0500",
+	"MST": "-0700", "MDT": "-0600",
+	"PST": "-0800", "PDT": "-0700",
+	"CET": "+0100", "CEST": "+0200",
+	"EET": "+0200", "EEST": "+0300",
+	"MSK": "+0300",
+}
+
+// ParseDate разбирает дату публикации из ленты любого поддерживаемого формата:
+// pubDate в вариантах RFC 822 (в том числе без дня недели, с двузначным годом
+// и буквенным часовым поясом), dc:date, Atom updated и даты JSON Feed в ISO 8601.
+// Возвращает время в UTC.
+func ParseDate(value string) (time.Time, error) {
+	value = strings.Join(strings.Fields(value), " ")
+	if value == "" {
+		return time.Time{}, ErrNoDate
+	}
+
+	for _, layout := range isoLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC(), nil
+		}
+	}
+
+	normalized := normalizeRFC822(value)
+	for _, layout := range rfc822Layouts {
+		if t, err := time.Parse(layout, normalized); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("couldn't parse publication time %q", value)
+}
+
+// normalizeRFC822 убирает необязательный день недели и заменяет
+// известные буквенные часовые пояса числовыми смещениями.
+func normalizeRFC822(value string) string {
+	fields := strings.Fields(value)
+	if len(fields) > 0 && strings.HasSuffix(fields[0], ",") {
+		fields = fields[1:]
+	} else if len(fields) > 0 && len(fields[0]) >= 3 && isLetters(fields[0]) {
+		// День недели без запятой: "Mon 04 Mar 2024 ..."
+		fields = fields[1:]
+	}
+	if n := len(fields); n > 0 {
+		if offset, ok := zoneOffsets[strings.ToUpper(fields[n-1])]; ok {
+			fields[n-1] = offset
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+// isLetters сообщает, состоит ли строка только из латинских букв.
+func isLetters(s string) bool {
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// postDate возвращает дату публикации в формате RFC1123Z (UTC) по первому из значений,
+// которое удалось разобрать. Если дата не указана или не разбирается,
+// используется время загрузки ленты.
+func postDate(title string, fetchedAt time.Time, values ...string) string {
+	for _, value := range values {
+		t, err := ParseDate(value)
+		if err == nil {
+			return t.Format(time.RFC1123Z)
+		}
+		if err != ErrNoDate {
+			log.Printf("Ошибка парсинга даты у статьи %s: %v", title, err)
+		}
+	}
+	return fetchedAt.UTC().Format(time.RFC1123Z)
+}
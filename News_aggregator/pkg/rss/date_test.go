@@ -0,0 +1,98 @@
+package rss
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	want := time.Date(2024, 3, 4, 9, 15, 0, 0, time.UTC)
+	tests := []string{
+		// RFC 822 / RFC 1123
+		"Mon, 04 Mar 2024 09:15:00 GMT",
+		"Mon, 04 Mar 2024 09:15:00 +0000",
+		"Mon, 4 Mar 2024 12:15:00 +0300",
+		"Mon, 04 Mar 2024 12:15:00 MSK",
+		"Mon, 04 Mar 2024 04:15:00 EST",
+		"Mon, 04 Mar 2024 01:15:00 PST",
+		"Mon, 04 Mar 2024 12:15:00 +03:00",
+		"04 Mar 2024 09:15:00 UT",
+		"Mon 04 Mar 2024 09:15:00 Z",
+		"Monday, 04 March 2024 09:15:00 +0000",
+		"Mon, 04 Mar 2024 09:15 GMT",
+		"  Mon,  04 Mar 2024\n09:15:00 GMT ",
+		"Mon, 04 Mar 2024 09:15:00",
+		// Двузначный год
+		"Mon, 04 Mar 24 09:15:00 GMT",
+		"04 Mar 24 10:15 +0100",
+		// ISO 8601: Atom updated, dc:date, JSON Feed
+		"2024-03-04T09:15:00Z",
+		"2024-03-04T12:15:00+03:00",
+		"2024-03-04T09:15:00.000Z",
+		"2024-03-04T12:15:00+0300",
+		"2024-03-04T12:15+03:00",
+		"2024-03-04T09:15:00",
+		"2024-03-04T09:15",
+		"2024-03-04 09:15:00",
+	}
+	for _, value := range tests {
+		got, err := ParseDate(value)
+		if err != nil {
+			t.Errorf("%q: ошибка разбора: %v", value, err)
+			continue
+		}
+		if !got.Equal(want) || got.Location() != time.UTC {
+			t.Errorf("%q: ожидалось %v, получено %v", value, want, got)
+		}
+	}
+
+	if got, err := ParseDate("2024-03-04"); err != nil || !got.Equal(time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("дата без времени: получено %v, %v", got, err)
+	}
+	if got, _ := ParseDate("Thu, 01 Jan 98 00:00:00 GMT"); got.Year() != 1998 {
+		t.Errorf("двузначный год 98: ожидался 1998, получено %d", got.Year())
+	}
+}
+
+func TestParseDateErrors(t *testing.T) {
+	if _, err := ParseDate("  "); err != ErrNoDate {
+		t.Errorf("для пустой даты ожидалась ErrNoDate, получено %v", err)
+	}
+	for _, value := range []string{"вчера", "32 Mar 2024 09:15:00 GMT", "2024-13-01"} {
+		if _, err := ParseDate(value); err == nil {
+			t.Errorf("%q: ожидалась ошибка", value)
+		}
+	}
+}
+
+func TestPostDateFallback(t *testing.T) {
+	fetchedAt := time.Date(2024, 3, 4, 12, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+
+	if got := postDate("post", fetchedAt, "", "2024-03-01T10:00:00Z"); got != "Fri, 01 Mar 2024 10:00:00 +0000" {
+		t.Errorf("ожидалась дата из второго значения, получено %q", got)
+	}
+	if got := postDate("post", fetchedAt, "не дата", ""); got != "Mon, 04 Mar 2024 09:00:00 +0000" {
+		t.Errorf("ожидалось время загрузки в UTC, получено %q", got)
+	}
+}
+
+func TestParseFeedMissingDates(t *testing.T) {
+	data := []byte(`<?xml version="1.0"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/"><channel>
+  <item><title>dc:date</title><link>https://example.com/1</link><dc:date>2024-03-04T12:15:00+03:00</dc:date></item>
+  <item><title>без даты</title><link>https://example.com/2</link></item>
+</channel></rss>`)
+
+	before := time.Now().Add(-time.Second)
+	feed, err := ParseFeed(data)
+	if err != nil {
+		t.Fatalf("ошибка разбора: %v", err)
+	}
+	if feed.Posts[0].PubDate != "Mon, 04 Mar 2024 09:15:00 +0000" {
+		t.Errorf("dc:date: неверная дата %q", feed.Posts[0].PubDate)
+	}
+	fallback, err := time.Parse(time.RFC1123Z, feed.Posts[1].PubDate)
+	if err != nil || fallback.Before(before) || fallback.After(time.Now()) {
+		t.Errorf("публикация без даты должна получить время загрузки, получено %q", feed.Posts[1].PubDate)
+	}
+}
@@ -0,0 +1,191 @@
+package rss
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"Task36a41/pkg/netguard"
+
+	"golang.org/x/net/html"
+)
+
+// Candidate — лента, найденная при поиске по адресу сайта.
+type Candidate struct {
+	URL   string `json:"url"`   // Адрес ленты
+	Title string `json:"title"` // Название из <link title> или из самой ленты
+	Type  string `json:"type"`  // MIME-тип ленты
+}
+
+// feedMIMETypes — типы в <link rel="alternate">, которые указывают на ленту.
+var feedMIMETypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/rdf+xml":   true,
+	"application/feed+json": true,
+}
+
+// commonFeedPaths — адреса лент, которые проверяются, если страница не ссылается на ленты.
+var commonFeedPaths = []string{"/feed", "/rss", "/rss.xml", "/atom.xml", "/feed.xml", "/index.xml"}
+
+// maxDiscoverySize ограничивает размер загружаемой при поиске страницы.
+const maxDiscoverySize = 5 << 20
+
+// ErrForbiddenAddress возвращается, если адрес ленты или страницы для поиска лент,
+// в том числе после редиректа, разрешается во внутренний адрес: loopback,
+// частную сеть, link-local и т. п. Так планировщик и поиск лент нельзя
+// использовать для обращения к сервисам внутренней сети.
+var ErrForbiddenAddress = netguard.ErrForbiddenAddress
+
+// discoveryClient загружает страницы при поиске лент и обращается только к публичным адресам.
+var discoveryClient = netguard.NewClient(10 * time.Second)
+
+// Discover находит ленты по адресу страницы. Если адрес уже указывает на ленту,
+// возвращается она сама. Иначе ищутся теги <link rel="alternate"> с типом ленты,
+// а если их нет — проверяются распространённые адреса вроде /feed и /rss.xml.
+// Пустой результат без ошибки означает, что лент не найдено.
+// Внутренние адреса не загружаются, см. ErrForbiddenAddress.
+func Discover(pageURL string) ([]Candidate, error) {
+	page, err := fetchForDiscovery(pageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if feed, err := ParseFeed(page.body); err == nil {
+		return []Candidate{{URL: pageURL, Title: feed.Title, Type: page.mediaType}}, nil
+	}
+
+	if candidates := discoverLinks(page.body, page.url); len(candidates) > 0 {
+		return candidates, nil
+	}
+	return probeCommonPaths(page.url), nil
+}
+
+// discoveredPage — загруженная страница и адрес, по которому она получена после редиректов.
+type discoveredPage struct {
+	url       *url.URL
+	body      []byte
+	mediaType string
+}
+
+// fetchForDiscovery загружает страницу для поиска лент.
+func fetchForDiscovery(pageURL string) (*discoveredPage, error) {
+	resp, err := discoveryClient.Get(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch page: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch page: %w", &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status})
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDiscoverySize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page: %v", err)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if body, err = decodeCharset(body, contentType); err != nil {
+		return nil, err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return &discoveredPage{url: resp.Request.URL, body: body, mediaType: mediaType}, nil
+}
+
+// discoverLinks извлекает из HTML-страницы ленты, объявленные тегами
+// <link rel="alternate" type="application/rss+xml"> и аналогичными.
+// Относительные адреса разрешаются относительно <base href> или адреса страницы.
+func discoverLinks(body []byte, pageURL *url.URL) []Candidate {
+	base := pageURL
+	var candidates []Candidate
+	seen := make(map[string]bool)
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return candidates
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.Data {
+			case "base":
+				if href, err := base.Parse(attr(token, "href")); err == nil {
+					base = href
+				}
+			case "link":
+				if !hasToken(attr(token, "rel"), "alternate") {
+					continue
+				}
+				mediaType := strings.ToLower(strings.TrimSpace(attr(token, "type")))
+				if !feedMIMETypes[mediaType] {
+					continue
+				}
+				href, err := base.Parse(strings.TrimSpace(attr(token, "href")))
+				if err != nil || attr(token, "href") == "" || seen[href.String()] {
+					continue
+				}
+				seen[href.String()] = true
+				candidates = append(candidates, Candidate{URL: href.String(), Title: strings.TrimSpace(attr(token, "title")), Type: mediaType})
+			}
+		}
+	}
+}
+
+// attr возвращает значение атрибута тега или пустую строку.
+func attr(token html.Token, name string) string {
+	for _, a := range token.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// hasToken сообщает, содержит ли список через пробел (например, rel) заданное значение.
+func hasToken(list, value string) bool {
+	for _, field := range strings.Fields(list) {
+		if strings.EqualFold(field, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// probeCommonPaths параллельно проверяет распространённые адреса лент на сайте
+// и возвращает те, что отдают разбираемую ленту, в порядке commonFeedPaths.
+func probeCommonPaths(pageURL *url.URL) []Candidate {
+	found := make([]*Candidate, len(commonFeedPaths))
+	var wg sync.WaitGroup
+	for i, path := range commonFeedPaths {
+		wg.Add(1)
+		go func(i int, feedURL string) {
+			defer wg.Done()
+			page, err := fetchForDiscovery(feedURL)
+			if err != nil {
+				return
+			}
+			if feed, err := ParseFeed(page.body); err == nil {
+				found[i] = &Candidate{URL: page.url.String(), Title: feed.Title, Type: page.mediaType}
+			}
+		}(i, pageURL.ResolveReference(&url.URL{Path: path}).String())
+	}
+	wg.Wait()
+
+	var candidates []Candidate
+	seen := make(map[string]bool)
+	for _, c := range found {
+		if c != nil && !seen[c.URL] {
+			seen[c.URL] = true
+			candidates = append(candidates, *c)
+		}
+	}
+	return candidates
+}
@@ -0,0 +1,109 @@
+package rss
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestDiscoverFeedURL(t *testing.T) {
+	allowLoopback(t)
+	server := newFixtureServer(t)
+
+	candidates, err := Discover(server.URL + "/atom.xml")
+	if err != nil {
+		t.Fatalf("Error discovering feeds: %v", err)
+	}
+	if len(candidates) != 1 || candidates[0].URL != server.URL+"/atom.xml" || candidates[0].Title == "" {
+		t.Errorf("Expected the feed itself, got %+v", candidates)
+	}
+}
+
+func TestDiscoverLinks(t *testing.T) {
+	allowLoopback(t)
+	server := newFixtureServer(t)
+
+	candidates, err := Discover(server.URL + "/page.html")
+	if err != nil {
+		t.Fatalf("Error discovering feeds: %v", err)
+	}
+
+	want := []Candidate{
+		{URL: server.URL + "/rss.xml", Title: "Все статьи", Type: "application/rss+xml"},
+		{URL: "https://blog.example.com/atom.xml", Title: "Atom", Type: "application/atom+xml"},
+	}
+	if len(candidates) != len(want) {
+		t.Fatalf("Expected %d candidates, got %+v", len(want), candidates)
+	}
+	for i := range want {
+		if candidates[i] != want[i] {
+			t.Errorf("Candidate %d: expected %+v, got %+v", i, want[i], candidates[i])
+		}
+	}
+}
+
+func TestDiscoverLinksBase(t *testing.T) {
+	page := []byte(`<html><head><base href="https://cdn.example.com/blog/">
+		<link rel="alternate feed" type="application/feed+json" href="feed.json"></head></html>`)
+	pageURL, _ := url.Parse("https://example.com/")
+
+	candidates := discoverLinks(page, pageURL)
+	if len(candidates) != 1 || candidates[0].URL != "https://cdn.example.com/blog/feed.json" {
+		t.Errorf("Expected link resolved against <base>, got %+v", candidates)
+	}
+}
+
+func TestDiscoverCommonPaths(t *testing.T) {
+	allowLoopback(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><head><title>Без ссылок на ленты</title></head></html>`))
+	})
+	mux.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/feed.xml", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/feed.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write(readFixture(t, "rss2.xml"))
+	})
+	mux.HandleFunc("/rss", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html>not a feed</html>`))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	candidates, err := Discover(server.URL + "/")
+	if err != nil {
+		t.Fatalf("Error discovering feeds: %v", err)
+	}
+	if len(candidates) != 1 || candidates[0].URL != server.URL+"/feed.xml" || candidates[0].Type != "application/rss+xml" {
+		t.Errorf("Expected /feed redirecting to /feed.xml, got %+v", candidates)
+	}
+}
+
+func TestDiscoverErrors(t *testing.T) {
+	allowLoopback(t)
+	server := newFixtureServer(t)
+
+	if _, err := Discover(server.URL + "/missing.html"); err == nil {
+		t.Error("Expected error for missing page")
+	}
+	if _, err := Discover("http://127.0.0.1:1/"); err == nil {
+		t.Error("Expected error for unreachable host")
+	}
+}
+
+func TestDiscoverForbiddenAddress(t *testing.T) {
+	server := newFixtureServer(t)
+
+	if _, err := Discover(server.URL + "/atom.xml"); !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("Expected ErrForbiddenAddress for loopback server, got %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package rss
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JSONFeed описывает структуру ленты в формате JSON Feed 1.1.
+type JSONFeed struct {
+	Version string         `json:"version"` // URL версии формата
+	Title   string         `json:"title"`   // Название ленты
+	Items   []JSONFeedItem `json:"items"`   // Массив публикаций
+}
+
+// JSONFeedItem описывает одну публикацию JSON Feed.
+type JSONFeedItem struct {
+	ID            string `json:"id"`
+	URL           string `json:"url"`
+	ExternalURL   string `json:"external_url"`
+	Title         string `json:"title"`
+	ContentHTML   string `json:"content_html"`
+	ContentText   string `json:"content_text"`
+	Summary       string `json:"summary"`
+	DatePublished string `json:"date_published"`
+	DateModified  string `json:"date_modified"`
+
+	Authors     []JSONFeedAuthor     `json:"authors"`     // Авторы (JSON Feed 1.1)
+	Author      *JSONFeedAuthor      `json:"author"`      // Автор (JSON Feed 1.0)
+	Tags        []string             `json:"tags"`        // Теги
+	Attachments []JSONFeedAttachment `json:"attachments"` // Вложения
+}
+
+// JSONFeedAuthor описывает автора публикации JSON Feed.
+type JSONFeedAuthor struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// JSONFeedAttachment описывает вложение публикации JSON Feed.
+type JSONFeedAttachment struct {
+	URL         string `json:"url"`
+	MIMEType    string `json:"mime_type"`
+	SizeInBytes int64  `json:"size_in_bytes"`
+}
+
+// author возвращает имена авторов публикации через запятую.
+func (item JSONFeedItem) author() string {
+	authors := item.Authors
+	if len(authors) == 0 && item.Author != nil {
+		authors = []JSONFeedAuthor{*item.Author}
+	}
+	var names []string
+	for _, a := range authors {
+		if a.Name != "" {
+			names = append(names, a.Name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// enclosures возвращает вложения публикации.
+func (item JSONFeedItem) enclosures() []Enclosure {
+	var result []Enclosure
+	for _, a := range item.Attachments {
+		result = appendEnclosure(result, a.URL, a.MIMEType, strconv.FormatInt(a.SizeInBytes, 10))
+	}
+	return result
+}
+
+// parseJSONFeed разбирает ленту в формате JSON Feed и приводит записи к Post.
+func parseJSONFeed(data []byte, fetchedAt time.Time) (*Feed, error) {
+	var feed JSONFeed
+	if err := json.Unmarshal(data, &feed); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON Feed: %v", err)
+	}
+
+	posts := make([]Post, 0, len(feed.Items))
+	for _, item := range feed.Items {
+		post := Post{
+			Title:      item.Title,
+			Link:       firstNonEmpty(item.URL, item.ExternalURL),
+			Content:    firstNonEmpty(item.Summary, item.ContentHTML, item.ContentText),
+			GUID:       item.ID,
+			Author:     item.author(),
+			Categories: cleanCategories(item.Tags),
+			Enclosures: item.enclosures(),
+		}
+		post.PubDate = postDate(post.Title, fetchedAt, item.DatePublished, item.DateModified)
+		posts = append(posts, post)
+	}
+
+	return &Feed{Title: feed.Title, Posts: posts}, nil
+}
+
+// firstNonEmpty возвращает первую непустую строку из списка.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
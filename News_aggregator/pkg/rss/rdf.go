@@ -0,0 +1,51 @@
+package rss
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RDFFeed описывает структуру ленты в формате RSS 1.0 (RDF).
+// В отличие от RSS 2.0, элементы <item> находятся вне <channel>.
+type RDFFeed struct {
+	Channel struct {
+		Title string `xml:"title"` // Название канала
+	} `xml:"channel"`
+	Items []RDFItem `xml:"item"` // Массив публикаций
+}
+
+// RDFItem описывает одну публикацию ленты RSS 1.0.
+type RDFItem struct {
+	About       string   `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"` // rdf:about — идентификатор записи
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	Date        string   `xml:"http://purl.org/dc/elements/1.1/ date"`    // dc:date в формате ISO 8601
+	Creator     string   `xml:"http://purl.org/dc/elements/1.1/ creator"` // dc:creator — автор
+	Subjects    []string `xml:"http://purl.org/dc/elements/1.1/ subject"` // dc:subject — категории
+}
+
+// parseRDF разбирает ленту в формате RSS 1.0 (RDF) и приводит записи к Post.
+func parseRDF(data []byte, fetchedAt time.Time) (*Feed, error) {
+	var feed RDFFeed
+	if err := unmarshalXML(data, &feed); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal RDF XML: %v", err)
+	}
+
+	posts := make([]Post, 0, len(feed.Items))
+	for _, item := range feed.Items {
+		post := Post{
+			Title:      strings.TrimSpace(item.Title),
+			Link:       strings.TrimSpace(item.Link),
+			Content:    strings.TrimSpace(item.Description),
+			GUID:       strings.TrimSpace(item.About),
+			Author:     strings.TrimSpace(item.Creator),
+			Categories: cleanCategories(item.Subjects),
+		}
+		post.PubDate = postDate(post.Title, fetchedAt, item.Date)
+		posts = append(posts, post)
+	}
+
+	return &Feed{Title: feed.Channel.Title, Posts: posts}, nil
+}
@@ -1,99 +1,361 @@
 package rss
 
 import (
+	"bytes"
+	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
-	"io/ioutil"
-	"log"
+	"io"
 	"net/http"
-	"sync"
+	"strconv"
+	"strings"
 	"time"
+
+	"Task36a41/pkg/netguard"
 )
 
 // Post представляет собой структуру для одной публикации (статьи) в RSS.
 type Post struct {
-	ID      int    `json:"id"`         // Уникальный идентификатор
-	Title   string `xml:"title"`       // Заголовок статьи
-	Link    string `xml:"link"`        // Ссылка на оригинальную статью
-	PubDate string `xml:"pubDate"`     // Дата публикации
-	Content string `xml:"description"` // Описание или краткое содержание статьи
+	ID          int    `json:"id"`                             // Уникальный идентификатор
+	Title       string `xml:"title"`                           // Заголовок статьи
+	Link        string `xml:"link"`                            // Ссылка на оригинальную статью
+	PubDate     string `xml:"pubDate"`                         // Дата публикации
+	Content     string `xml:"description"`                     // Описание или краткое содержание статьи
+	ContentText string `json:"content_text,omitempty" xml:"-"` // Текст содержания без разметки для поиска и превью
+	FullContent string `json:"full_content,omitempty" xml:"-"` // Полный текст статьи со страницы публикации, если источник его загружает
+	Source      string `json:"source" xml:"-"`                 // URL ленты, из которой получена публикация
+	SourceID    int    `json:"source_id,omitempty" xml:"-"`    // ID источника в хранилище, 0 — источник неизвестен
+
+	// Метаданные записи ленты
+	GUID       string      `json:"guid,omitempty" xml:"-"`       // Постоянный идентификатор записи в ленте
+	Author     string      `json:"author,omitempty" xml:"-"`     // Автор
+	Categories []string    `json:"categories,omitempty" xml:"-"` // Категории и теги
+	Enclosures []Enclosure `json:"enclosures,omitempty" xml:"-"` // Вложения: изображения, аудио, видео
+
+	// Кластер почти одинаковых публикаций из разных лент, заполняется хранилищем
+	ClusterID  int         `json:"cluster_id,omitempty" xml:"-"` // ID первой публикации кластера
+	Alternates []Alternate `json:"alternates,omitempty" xml:"-"` // Другие публикации кластера при свёрнутой выдаче
+
+	// Поля результатов поиска, заполняются хранилищем
+	Rank      float64 `json:"rank,omitempty" xml:"-"`      // Релевантность запросу
+	Highlight string  `json:"highlight,omitempty" xml:"-"` // Фрагмент с подсвеченными совпадениями: экранированный текст с тегами <mark>
+}
+
+// Alternate — другая публикация той же истории, например из другой ленты.
+type Alternate struct {
+	ID     int    `json:"id"`
+	Title  string `json:"title"`
+	Link   string `json:"link"`
+	Source string `json:"source"`
+}
+
+// Enclosure описывает вложение публикации: <enclosure> и media:content в RSS,
+// <link rel="enclosure"> в Atom, attachments в JSON Feed.
+type Enclosure struct {
+	URL    string `json:"url"`
+	Type   string `json:"type,omitempty"`   // MIME-тип или вид вложения, например image
+	Length int64  `json:"length,omitempty"` // Размер в байтах, 0 если неизвестен
 }
 
 // RSSFeed описывает структуру RSS-ленты.
 type RSSFeed struct {
 	Channel struct {
-		Title string `xml:"title"` // Название канала RSS
-		Items []Post `xml:"item"`  // Массив публикаций
+		Title string    `xml:"title"` // Название канала RSS
+		TTL   int       `xml:"ttl"`   // Рекомендуемый интервал опроса в минутах
+		Items []RSSItem `xml:"item"`  // Массив публикаций
 	} `xml:"channel"`
 }
 
-// FetchRSS делает HTTP-запрос к RSS-ленте и возвращает массив публикаций.
+// RSSItem описывает элемент <item> ленты RSS 2.0.
+type RSSItem struct {
+	Title        string         `xml:"title"`
+	Link         string         `xml:"link"`
+	Description  string         `xml:"description"`
+	PubDate      string         `xml:"pubDate"`
+	Date         string         `xml:"http://purl.org/dc/elements/1.1/ date"`    // dc:date, если pubDate не указан
+	GUID         string         `xml:"guid"`                                     // Идентификатор записи
+	Author       string         `xml:"author"`                                   // Email автора, часто с именем в скобках
+	Creator      string         `xml:"http://purl.org/dc/elements/1.1/ creator"` // dc:creator — имя автора
+	Categories   []string       `xml:"category"`                                 // Категории
+	Enclosures   []RSSEnclosure `xml:"enclosure"`                                // Вложения
+	MediaContent []MediaContent `xml:"http://search.yahoo.com/mrss/ content"`    // media:content из Media RSS
+}
+
+// RSSEnclosure описывает элемент <enclosure> RSS 2.0.
+type RSSEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+// MediaContent описывает элемент media:content из Media RSS.
+type MediaContent struct {
+	URL      string `xml:"url,attr"`
+	Type     string `xml:"type,attr"`
+	Medium   string `xml:"medium,attr"` // image, audio, video
+	FileSize string `xml:"fileSize,attr"`
+}
+
+// enclosures собирает вложения элемента из <enclosure> и media:content.
+func (item RSSItem) enclosures() []Enclosure {
+	var result []Enclosure
+	for _, e := range item.Enclosures {
+		result = appendEnclosure(result, e.URL, e.Type, e.Length)
+	}
+	for _, m := range item.MediaContent {
+		result = appendEnclosure(result, m.URL, firstNonEmpty(m.Type, m.Medium), m.FileSize)
+	}
+	return result
+}
+
+// appendEnclosure добавляет вложение, пропуская пустые адреса и повторы.
+func appendEnclosure(enclosures []Enclosure, url, mediaType, length string) []Enclosure {
+	url = strings.TrimSpace(url)
+	if url == "" {
+		return enclosures
+	}
+	for _, e := range enclosures {
+		if e.URL == url {
+			return enclosures
+		}
+	}
+	size, _ := strconv.ParseInt(strings.TrimSpace(length), 10, 64)
+	return append(enclosures, Enclosure{URL: url, Type: strings.TrimSpace(mediaType), Length: size})
+}
+
+// authorName возвращает имя автора из <author> RSS 2.0, который по стандарту содержит
+// email, нередко с именем в скобках: "editor@example.com (Иван Петров)".
+func authorName(value string) string {
+	value = strings.TrimSpace(value)
+	if open, close := strings.Index(value, "("), strings.LastIndex(value, ")"); open >= 0 && close > open {
+		if name := strings.TrimSpace(value[open+1 : close]); name != "" {
+			return name
+		}
+	}
+	return value
+}
+
+// cleanCategories убирает пробелы по краям, пустые значения и повторы.
+func cleanCategories(values []string) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v != "" && !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// Feed — результат разбора ленты любого поддерживаемого формата.
+type Feed struct {
+	Title string        // Название ленты
+	TTL   time.Duration // Рекомендуемый интервал опроса (<ttl> в RSS 2.0), 0 если не указан
+	Posts []Post        // Массив публикаций
+}
+
+// Validators — валидаторы кэша HTTP, полученные при прошлом запросе ленты.
+type Validators struct {
+	ETag         string // Значение заголовка ETag
+	LastModified string // Значение заголовка Last-Modified
+}
+
+// ValidatorStore хранит валидаторы кэша для каждой ленты между опросами.
+type ValidatorStore interface {
+	GetValidators(ctx context.Context, url string) (Validators, error)
+	SaveValidators(ctx context.Context, url string, v Validators) error
+}
+
+// ErrNotModified возвращается, если лента не изменилась с прошлого запроса (HTTP 304).
+var ErrNotModified = errors.New("feed not modified")
+
+// HTTPError описывает неуспешный ответ сервера ленты.
+type HTTPError struct {
+	StatusCode int           // Код ответа
+	Status     string        // Текст статуса
+	RetryAfter time.Duration // Значение заголовка Retry-After, 0 если он не передан
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("unexpected status %s", e.Status)
+}
+
+// maxFeedSize ограничивает размер загружаемой ленты: сломанный или враждебный
+// источник не должен заставить планировщик прочитать в память неограниченный ответ.
+const maxFeedSize = 10 << 20
+
+// feedClient загружает ленты. Адреса лент задают администраторы и файлы OPML,
+// поэтому клиент, как и при поиске лент, обращается только к публичным адресам.
+var feedClient = netguard.NewClient(10 * time.Second)
+
+// FetchRSS делает HTTP-запрос к ленте (RSS, Atom, RDF или JSON Feed) и возвращает массив публикаций.
 func FetchRSS(url string) ([]Post, error) {
-	client := &http.Client{Timeout: 10 * time.Second} // Устанавливаем таймаут для запроса
+	feed, _, err := FetchRSSConditional(context.Background(), url, Validators{})
+	if err != nil {
+		return nil, err
+	}
+	return feed.Posts, nil
+}
+
+// FetchRSSConditional делает условный HTTP-запрос к ленте, передавая If-None-Match и
+// If-Modified-Since из сохранённых валидаторов. Возвращает разобранную ленту и новые валидаторы.
+// Если сервер ответил 304 Not Modified, возвращается ErrNotModified,
+// при остальных неуспешных ответах — ошибка *HTTPError. Отмена ctx прерывает запрос.
+func FetchRSSConditional(ctx context.Context, url string, v Validators) (*Feed, Validators, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, v, fmt.Errorf("failed to create RSS request: %v", err)
+	}
+	if v.ETag != "" {
+		req.Header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
+	}
 
-	resp, err := client.Get(url) // Выполняем запрос по ссылке RSS
+	resp, err := feedClient.Do(req) // Выполняем запрос по ссылке RSS
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch RSS: %v", err)
+		return nil, v, fmt.Errorf("failed to fetch RSS: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// Читаем содержимое ответа
-	body, err := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, v, ErrNotModified
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, v, fmt.Errorf("failed to fetch RSS: %w", &HTTPError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		})
+	}
+
+	// Читаем содержимое ответа: на байт больше ограничения, чтобы отличить
+	// ленту ровно предельного размера от слишком большой
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize+1))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read RSS response body: %v", err)
+		return nil, v, fmt.Errorf("failed to read RSS response body: %v", err)
+	}
+	if len(body) > maxFeedSize {
+		return nil, v, fmt.Errorf("feed is larger than %d bytes", maxFeedSize)
 	}
 
-	// Парсим XML-ответ в структуру RSSFeed
-	var feed RSSFeed
-	if err := xml.Unmarshal(body, &feed); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal RSS XML: %v", err)
+	body, err = decodeCharset(body, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, v, err
 	}
 
-	// Парсим дату публикации в каждом посте в формате Unix
-	for i, item := range feed.Channel.Items {
-		pubDate, err := time.Parse("Mon, 02 Jan 2006 15:04:05 MST", item.PubDate)
-		if err != nil {
-			log.Printf("Ошибка парсинга даты у статьи %s: %v. Дата: %s", item.Title, err, item.PubDate)
-			continue
-		}
-		// Перезаписываем поле PubDate в формате Unix (для совместимости с базой данных)
-		feed.Channel.Items[i].PubDate = time.Unix(pubDate.Unix(), 0).Format(time.RFC1123Z)
+	feed, err := ParseFeed(body)
+	if err != nil {
+		return nil, v, err
 	}
 
-	// Возвращаем массив публикаций
-	return feed.Channel.Items, nil
+	validators := Validators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	return feed, validators, nil
 }
 
-// FetchAllRSS принимает список URL и собирает публикации из всех источников асинхронно.
-func FetchAllRSS(urls []string) []Post {
-	var allPosts []Post
-	var wg sync.WaitGroup
-	postsChan := make(chan []Post, len(urls)) // Канал для публикаций
+// parseRetryAfter разбирает заголовок Retry-After, заданный в секундах или HTTP-датой.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
 
-	// Обрабатываем каждый URL в списке в горутине
-	for _, url := range urls {
-		wg.Add(1)
-		go func(url string) {
-			defer wg.Done()
-			posts, err := FetchRSS(url)
-			if err != nil {
-				log.Printf("Ошибка при получении RSS с %s: %v", url, err)
-				return
-			}
-			postsChan <- posts
-		}(url)
+// Parse определяет формат ленты и разбирает её в массив публикаций.
+func Parse(data []byte) ([]Post, error) {
+	feed, err := ParseFeed(data)
+	if err != nil {
+		return nil, err
 	}
+	return feed.Posts, nil
+}
+
+// ParseFeed определяет формат ленты и разбирает её.
+// JSON Feed распознаётся по первому символу документа, XML-форматы — по корневому элементу.
+// Даты публикаций приводятся к RFC1123Z в UTC; публикации без даты
+// получают время разбора ленты.
+func ParseFeed(data []byte) (*Feed, error) {
+	fetchedAt := time.Now()
+
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // Убираем BOM, если он есть
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return parseJSONFeed(trimmed, fetchedAt)
+	}
+
+	root, err := rootElement(data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch root {
+	case "rss":
+		return parseRSS(data, fetchedAt)
+	case "feed":
+		return parseAtom(data, fetchedAt)
+	case "RDF":
+		return parseRDF(data, fetchedAt)
+	default:
+		return nil, fmt.Errorf("unsupported feed format: root element <%s>", root)
+	}
+}
+
+// rootElement возвращает локальное имя корневого элемента XML-документа.
+func rootElement(data []byte) (string, error) {
+	decoder := newXMLDecoder(data)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return "", fmt.Errorf("failed to detect feed format: no root element")
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to detect feed format: %v", err)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
 
-	// Закрываем канал после завершения всех горутин
-	go func() {
-		wg.Wait()
-		close(postsChan)
-	}()
+// parseRSS разбирает ленту в формате RSS 2.0.
+func parseRSS(data []byte, fetchedAt time.Time) (*Feed, error) {
+	// Парсим XML-ответ в структуру RSSFeed
+	var feed RSSFeed
+	if err := unmarshalXML(data, &feed); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal RSS XML: %v", err)
+	}
 
-	// Обрабатываем результаты после завершения всех горутин
-	for posts := range postsChan {
-		allPosts = append(allPosts, posts...) // Добавляем публикации в общий массив
+	posts := make([]Post, 0, len(feed.Channel.Items))
+	for _, item := range feed.Channel.Items {
+		post := Post{
+			Title:      item.Title,
+			Link:       item.Link,
+			Content:    item.Description,
+			GUID:       strings.TrimSpace(item.GUID),
+			Author:     firstNonEmpty(strings.TrimSpace(item.Creator), authorName(item.Author)),
+			Categories: cleanCategories(item.Categories),
+			Enclosures: item.enclosures(),
+		}
+		post.PubDate = postDate(post.Title, fetchedAt, item.PubDate, item.Date)
+		posts = append(posts, post)
 	}
 
-	return allPosts
+	// Возвращаем ленту с массивом публикаций
+	return &Feed{
+		Title: feed.Channel.Title,
+		TTL:   time.Duration(feed.Channel.TTL) * time.Minute,
+		Posts: posts,
+	}, nil
 }
@@ -1,65 +1,330 @@
 package rss
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
 
-// Тест для функции FetchRSS, проверяющий успешное получение и парсинг RSS-фида из URL.
+// readFixture читает файл фикстуры из каталога testdata.
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("не удалось прочитать фикстуру %s: %v", name, err)
+	}
+	return data
+}
+
+// allowLoopback разрешает загрузке и поиску лент обращаться к тестовым серверам на 127.0.0.1.
+func allowLoopback(t *testing.T) {
+	t.Helper()
+	savedFeed, savedDiscovery := feedClient, discoveryClient
+	feedClient = &http.Client{Timeout: 10 * time.Second}
+	discoveryClient = &http.Client{Timeout: 10 * time.Second}
+	t.Cleanup(func() { feedClient, discoveryClient = savedFeed, savedDiscovery })
+}
+
+// newFixtureServer поднимает HTTP-сервер, отдающий фикстуры из каталога testdata.
+func newFixtureServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Тест для функции FetchRSS, проверяющий успешное получение и парсинг RSS-фида по HTTP.
 func TestFetchRSS(t *testing.T) {
-	// Указываем URL с реальным RSS-фидом
-	url := "https://habr.com/ru/rss/best/daily/?fl=ru"
+	allowLoopback(t)
+	server := newFixtureServer(t)
 
 	// Вызываем функцию FetchRSS для получения данных фида
-	posts, err := FetchRSS(url)
+	posts, err := FetchRSS(server.URL + "/rss2.xml")
 	if err != nil {
 		t.Fatalf("ошибка при получении RSS фида: %v", err)
 	}
 
-	// Проверяем, что фид содержит хотя бы одну публикацию
-	if len(posts) == 0 {
-		t.Fatal("данные не декодированы или публикации отсутствуют")
+	// Проверяем, что фид содержит все публикации
+	if len(posts) != 3 {
+		t.Fatalf("ожидалось 3 публикации, получено %d", len(posts))
 	}
 
-	// Логируем количество и первые новости (для проверки успешного разбора)
-	t.Logf("получено %d новостей", len(posts))
-	for _, post := range posts[:3] { // Логируем первые три публикации для наглядности
-		t.Logf("Заголовок: %s, Ссылка: %s, Дата: %s", post.Title, post.Link, post.PubDate)
+	first := posts[0]
+	if first.Title != "Горутины изнутри" {
+		t.Errorf("неверный заголовок: %q", first.Title)
+	}
+	if first.Link != "https://habr.com/ru/articles/100001/" {
+		t.Errorf("неверная ссылка: %q", first.Link)
+	}
+	if first.Content != "<p>Как устроен планировщик</p>" {
+		t.Errorf("неверное содержание: %q", first.Content)
+	}
+	if first.PubDate != "Mon, 04 Mar 2024 09:15:00 +0000" {
+		t.Errorf("неверная дата: %q", first.PubDate)
+	}
+	if first.GUID != "https://habr.com/ru/articles/100001/" || first.Author != "gopher" {
+		t.Errorf("неверные GUID или автор: %q, %q", first.GUID, first.Author)
+	}
+	if !reflect.DeepEqual(first.Categories, []string{"Go", "Системное программирование"}) {
+		t.Errorf("неверные категории: %q", first.Categories)
+	}
+	// Картинка из media:content совпадает с enclosure и не дублируется
+	wantEnclosures := []Enclosure{
+		{URL: "https://habr.com/img/100001.png", Type: "image/png", Length: 4096},
+		{URL: "https://habr.com/video/100001.mp4", Type: "video/mp4", Length: 1048576},
+	}
+	if !reflect.DeepEqual(first.Enclosures, wantEnclosures) {
+		t.Errorf("неверные вложения: %+v", first.Enclosures)
+	}
+
+	second := posts[1]
+	if second.GUID != "habr-100002" || second.Author != "Редакция Хабра" {
+		t.Errorf("неверные GUID или автор второй публикации: %q, %q", second.GUID, second.Author)
 	}
 }
 
-// Тест для функции FetchAllRSS, проверяющий асинхронное получение и парсинг из нескольких источников.
-func TestFetchAllRSS(t *testing.T) {
-	// URL нескольких RSS-фидов
-	urls := []string{
-		"https://habr.com/ru/rss/best/daily/?fl=ru",
-		"https://habr.com/ru/rss/news/?fl=ru",
+// newConditionalServer поднимает сервер, поддерживающий ETag и If-None-Match,
+// и считает количество полных ответов.
+func newConditionalServer(t *testing.T, fullResponses *int32) *httptest.Server {
+	t.Helper()
+	data := readFixture(t, "rss2.xml")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		atomic.AddInt32(fullResponses, 1)
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Mon, 04 Mar 2024 09:15:00 GMT")
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Тест для функции FetchRSSConditional, проверяющий отправку валидаторов и обработку 304.
+func TestFetchRSSConditional(t *testing.T) {
+	allowLoopback(t)
+	var fullResponses int32
+	server := newConditionalServer(t, &fullResponses)
+
+	feed, validators, err := FetchRSSConditional(context.Background(), server.URL, Validators{})
+	if err != nil {
+		t.Fatalf("ошибка при получении RSS фида: %v", err)
 	}
+	if len(feed.Posts) != 3 {
+		t.Errorf("ожидалось 3 публикации, получено %d", len(feed.Posts))
+	}
+	want := Validators{ETag: `"v1"`, LastModified: "Mon, 04 Mar 2024 09:15:00 GMT"}
+	if validators != want {
+		t.Errorf("ожидались валидаторы %+v, получено %+v", want, validators)
+	}
+
+	// Повторный запрос с валидаторами должен вернуть ErrNotModified
+	feed, validators, err = FetchRSSConditional(context.Background(), server.URL, validators)
+	if !errors.Is(err, ErrNotModified) {
+		t.Fatalf("ожидалась ошибка ErrNotModified, получено %v", err)
+	}
+	if feed != nil {
+		t.Errorf("при 304 не ожидалось ленты, получено %+v", feed)
+	}
+	if validators != want {
+		t.Errorf("при 304 валидаторы должны сохраниться, получено %+v", validators)
+	}
+	if fullResponses != 1 {
+		t.Errorf("ожидался 1 полный ответ, получено %d", fullResponses)
+	}
+}
+
+// Тест для функции FetchRSSConditional, проверяющий отказ загружать ленту с внутреннего адреса.
+func TestFetchRSSForbiddenAddress(t *testing.T) {
+	server := newFixtureServer(t)
+
+	_, _, err := FetchRSSConditional(context.Background(), server.URL+"/rss2.xml", Validators{})
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("ожидался отказ загружать ленту с 127.0.0.1, получено %v", err)
+	}
+}
+
+// Тест для функции FetchRSSConditional, проверяющий разбор Retry-After при ошибке сервера.
+func TestFetchRSSRetryAfter(t *testing.T) {
+	allowLoopback(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
 
-	// Устанавливаем ограничение времени выполнения теста
-	timeout := time.After(10 * time.Second)
-	done := make(chan bool)
+	_, _, err := FetchRSSConditional(context.Background(), server.URL, Validators{})
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("ожидалась ошибка *HTTPError, получено %v", err)
+	}
+	if httpErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("ожидался код 503, получено %d", httpErr.StatusCode)
+	}
+	if httpErr.RetryAfter != 2*time.Minute {
+		t.Errorf("ожидался Retry-After 2m, получено %v", httpErr.RetryAfter)
+	}
+}
 
-	go func() {
-		// Вызываем функцию FetchAllRSS для получения данных из всех фидов
-		allPosts := FetchAllRSS(urls)
-		// Проверяем, что из всех фидов пришло хотя бы несколько публикаций
-		if len(allPosts) == 0 {
-			t.Error("данные не декодированы или публикации отсутствуют")
+// Тест для функции FetchRSSConditional, проверяющий ограничение размера ленты.
+func TestFetchRSSTooLarge(t *testing.T) {
+	allowLoopback(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<rss><channel>"))
+		w.Write(bytes.Repeat([]byte(" "), maxFeedSize))
+		w.Write([]byte("</channel></rss>"))
+	}))
+	defer server.Close()
+
+	_, _, err := FetchRSSConditional(context.Background(), server.URL, Validators{})
+	if err == nil || !strings.Contains(err.Error(), "larger than") {
+		t.Errorf("ожидалась ошибка размера ленты, получено %v", err)
+	}
+}
+
+// Тест для функции parseRetryAfter, проверяющий оба формата заголовка.
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"30", 30 * time.Second},
+		{"Mon, 04 Mar 2024 09:05:00 GMT", 5 * time.Minute},
+		{"Mon, 04 Mar 2024 08:00:00 GMT", 0},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, ожидалось %v", tt.value, got, tt.want)
 		}
-		// Логируем общее количество новостей и информацию о первых публикациях
-		t.Logf("получено %d новостей", len(allPosts))
-		for _, post := range allPosts[:3] { // Логируем первые три публикации для наглядности
-			t.Logf("Заголовок: %s, Ссылка: %s, Дата: %s", post.Title, post.Link, post.PubDate)
+	}
+}
+
+// Тест для функции ParseFeed, проверяющий чтение <ttl> из RSS 2.0.
+func TestParseFeedTTL(t *testing.T) {
+	feed, err := ParseFeed([]byte(`<rss><channel><title>T</title><ttl>45</ttl></channel></rss>`))
+	if err != nil {
+		t.Fatalf("ошибка разбора RSS: %v", err)
+	}
+	if feed.TTL != 45*time.Minute {
+		t.Errorf("ожидался TTL 45m, получено %v", feed.TTL)
+	}
+}
+
+// Тест для функции Parse, проверяющий разбор ленты в формате Atom.
+func TestParseAtom(t *testing.T) {
+	posts, err := Parse(readFixture(t, "atom.xml"))
+	if err != nil {
+		t.Fatalf("ошибка разбора Atom: %v", err)
+	}
+	if len(posts) != 2 {
+		t.Fatalf("ожидалось 2 публикации, получено %d", len(posts))
+	}
+
+	first := posts[0]
+	if first.Title != "Go 1.22 released" {
+		t.Errorf("неверный заголовок: %q", first.Title)
+	}
+	if first.Link != "https://example.com/go-1-22" {
+		t.Errorf("неверная ссылка: %q", first.Link)
+	}
+	if first.Content != "Range over integers and more." {
+		t.Errorf("неверное содержание: %q", first.Content)
+	}
+	if first.PubDate != "Sat, 02 Mar 2024 10:00:00 +0000" {
+		t.Errorf("неверная дата: %q", first.PubDate)
+	}
+	if first.GUID != "urn:uuid:1225c695-cfb8-4ebb-aaaa-80da344efa6a" || first.Author != "Gopher, Rob" {
+		t.Errorf("неверные GUID или автор: %q, %q", first.GUID, first.Author)
+	}
+	if !reflect.DeepEqual(first.Categories, []string{"Go", "releases"}) {
+		t.Errorf("неверные категории: %q", first.Categories)
+	}
+	wantEnclosures := []Enclosure{{URL: "https://example.com/go-1-22.mp3", Type: "audio/mpeg", Length: 1337}}
+	if !reflect.DeepEqual(first.Enclosures, wantEnclosures) {
+		t.Errorf("неверные вложения: %+v", first.Enclosures)
+	}
+
+	second := posts[1]
+	if second.Title != "Generics &amp; iterators" {
+		t.Errorf("неверный заголовок: %q", second.Title)
+	}
+	if second.Link != "https://example.com/iterators" {
+		t.Errorf("неверная ссылка: %q", second.Link)
+	}
+	if !strings.Contains(second.Content, "<p>Iterators proposal</p>") {
+		t.Errorf("содержимое xhtml не извлечено: %q", second.Content)
+	}
+	if second.PubDate != "Fri, 01 Mar 2024 09:30:00 +0000" {
+		t.Errorf("неверная дата: %q", second.PubDate)
+	}
+}
+
+// Тест для функции Parse, проверяющий разбор ленты в формате RSS 1.0 (RDF).
+func TestParseRDF(t *testing.T) {
+	posts, err := Parse(readFixture(t, "rdf.xml"))
+	if err != nil {
+		t.Fatalf("ошибка разбора RDF: %v", err)
+	}
+	if len(posts) != 2 {
+		t.Fatalf("ожидалось 2 публикации, получено %d", len(posts))
+	}
+
+	expected := []Post{
+		{Title: "First RDF item", Link: "https://example.org/a", Content: "About the first item", PubDate: "Tue, 05 Mar 2024 07:00:00 +0000",
+			GUID: "https://example.org/a", Author: "Jane Doe", Categories: []string{"Semantic Web"}},
+		{Title: "Second RDF item", Link: "https://example.org/b", Content: "About the second item", PubDate: "Mon, 04 Mar 2024 00:00:00 +0000",
+			GUID: "https://example.org/b"},
+	}
+	for i, want := range expected {
+		if !reflect.DeepEqual(posts[i], want) {
+			t.Errorf("публикация %d: ожидалось %+v, получено %+v", i, want, posts[i])
+		}
+	}
+}
+
+// Тест для функции Parse, проверяющий разбор ленты в формате JSON Feed 1.1.
+func TestParseJSONFeed(t *testing.T) {
+	posts, err := Parse(readFixture(t, "jsonfeed.json"))
+	if err != nil {
+		t.Fatalf("ошибка разбора JSON Feed: %v", err)
+	}
+	if len(posts) != 2 {
+		t.Fatalf("ожидалось 2 публикации, получено %d", len(posts))
+	}
+
+	expected := []Post{
+		{Title: "Second post", Link: "https://example.net/2", Content: "<p>Second post body</p>", PubDate: "Wed, 06 Mar 2024 12:00:00 +0000",
+			GUID: "2", Author: "Alice, Bob", Categories: []string{"go", "news"},
+			Enclosures: []Enclosure{{URL: "https://example.net/2.png", Type: "image/png", Length: 2048}}},
+		{Title: "First post", Link: "https://other.example/1", Content: "First post summary", PubDate: "Tue, 05 Mar 2024 06:30:00 +0000",
+			GUID: "1", Author: "Carol"},
+	}
+	for i, want := range expected {
+		if !reflect.DeepEqual(posts[i], want) {
+			t.Errorf("публикация %d: ожидалось %+v, получено %+v", i, want, posts[i])
 		}
-		done <- true
-	}()
-
-	// Завершаем тест, если функция не завершилась за заданное время
-	select {
-	case <-timeout:
-		t.Fatal("TestFetchAllRSS timed out")
-	case <-done:
-		// Успешное выполнение теста
+	}
+}
+
+// Тест для функции Parse, проверяющий отказ на неизвестном формате.
+func TestParseUnsupported(t *testing.T) {
+	if _, err := Parse([]byte(`<html><body>not a feed</body></html>`)); err == nil {
+		t.Error("ожидалась ошибка для HTML-документа")
+	}
+	if _, err := Parse([]byte(``)); err == nil {
+		t.Error("ожидалась ошибка для пустого документа")
 	}
 }
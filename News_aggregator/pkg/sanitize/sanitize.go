@@ -0,0 +1,232 @@
+// Пакет sanitize очищает HTML из лент перед сохранением: оставляет только
+// безопасные теги и атрибуты и извлекает текст для поиска и превью.
+package sanitize
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"Task36a41/pkg/rss"
+
+	"golang.org/x/net/html"
+)
+
+// allowedTags — разрешённые теги и их разрешённые атрибуты.
+// Остальные теги удаляются, а их текст сохраняется.
+var allowedTags = map[string]map[string]bool{
+	"a":          {"href": true, "title": true},
+	"abbr":       {"title": true},
+	"b":          {},
+	"blockquote": {"cite": true},
+	"br":         {},
+	"code":       {},
+	"del":        {},
+	"em":         {},
+	"figcaption": {},
+	"figure":     {},
+	"h1":         {},
+	"h2":         {},
+	"h3":         {},
+	"h4":         {},
+	"h5":         {},
+	"h6":         {},
+	"hr":         {},
+	"i":          {},
+	"img":        {"src": true, "alt": true, "title": true, "width": true, "height": true},
+	"ins":        {},
+	"li":         {},
+	"ol":         {},
+	"p":          {},
+	"pre":        {},
+	"s":          {},
+	"strong":     {},
+	"sub":        {},
+	"sup":        {},
+	"table":      {},
+	"tbody":      {},
+	"td":         {"colspan": true, "rowspan": true},
+	"tfoot":      {},
+	"th":         {"colspan": true, "rowspan": true},
+	"thead":      {},
+	"tr":         {},
+	"u":          {},
+	"ul":         {},
+}
+
+// voidTags — разрешённые теги без закрывающей пары.
+var voidTags = map[string]bool{"br": true, "hr": true, "img": true}
+
+// droppedTags — теги, которые удаляются вместе с содержимым.
+var droppedTags = map[string]bool{
+	"script": true, "style": true, "iframe": true, "frame": true, "frameset": true,
+	"object": true, "embed": true, "applet": true, "noscript": true, "template": true,
+	"svg": true, "math": true, "form": true, "textarea": true, "select": true,
+	"button": true, "head": true, "title": true,
+}
+
+// blockTags — теги, на границах которых в тексте ставится пробел.
+var blockTags = map[string]bool{
+	"address": true, "article": true, "blockquote": true, "br": true, "dd": true, "div": true,
+	"dl": true, "dt": true, "figcaption": true, "figure": true, "footer": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "header": true,
+	"hr": true, "li": true, "ol": true, "p": true, "pre": true, "section": true,
+	"table": true, "td": true, "th": true, "tr": true, "ul": true,
+}
+
+// urlAttrs — атрибуты-ссылки и схемы, которые в них допустимы.
+// Относительные ссылки допустимы всегда.
+var urlAttrs = map[string]map[string]bool{
+	"href": {"http": true, "https": true, "mailto": true},
+	"src":  {"http": true, "https": true},
+	"cite": {"http": true, "https": true},
+}
+
+// Post очищает содержание публикации и заполняет его текстовую версию.
+func Post(post *rss.Post) {
+	post.Content = HTML(post.Content)
+	post.ContentText = Text(post.Content)
+}
+
+// HTML оставляет в фрагменте только теги и атрибуты из белого списка.
+// Скрипты, стили, фреймы и встраиваемые объекты удаляются вместе с содержимым,
+// ссылки с опасными схемами (javascript:, data: и т. п.) — вместе с атрибутом.
+// Ссылки открываются в новой вкладке с rel="noopener noreferrer",
+// незакрытые теги закрываются в конце фрагмента.
+func HTML(fragment string) string {
+	var buf bytes.Buffer
+	var open []string // Открытые разрешённые теги
+	var skip skipper
+
+	tokenizer := html.NewTokenizer(strings.NewReader(fragment))
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		token := tokenizer.Token()
+		if skip.skipping(tt, token) {
+			continue
+		}
+
+		switch tt {
+		case html.TextToken:
+			buf.WriteString(html.EscapeString(token.Data))
+		case html.StartTagToken, html.SelfClosingTagToken:
+			attrs, ok := allowedTags[token.Data]
+			if !ok {
+				continue
+			}
+			writeStartTag(&buf, token, attrs)
+			switch {
+			case voidTags[token.Data]:
+			case tt == html.StartTagToken:
+				open = append(open, token.Data)
+			default:
+				// <p/> и подобные: браузер не считает их закрытыми
+				buf.WriteString("</" + token.Data + ">")
+			}
+		case html.EndTagToken:
+			// Закрываем тег, только если он открыт, вместе с вложенными незакрытыми
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] != token.Data {
+					continue
+				}
+				for j := len(open) - 1; j >= i; j-- {
+					buf.WriteString("</" + open[j] + ">")
+				}
+				open = open[:i]
+				break
+			}
+		}
+	}
+	for i := len(open) - 1; i >= 0; i-- {
+		buf.WriteString("</" + open[i] + ">")
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+// writeStartTag записывает открывающий тег с разрешёнными атрибутами.
+func writeStartTag(buf *bytes.Buffer, token html.Token, allowed map[string]bool) {
+	buf.WriteString("<" + token.Data)
+	for _, a := range token.Attr {
+		if a.Namespace != "" || !allowed[a.Key] {
+			continue
+		}
+		val := strings.TrimSpace(a.Val)
+		if schemes, ok := urlAttrs[a.Key]; ok && !safeURL(val, schemes) {
+			continue
+		}
+		buf.WriteString(" " + a.Key + `="` + html.EscapeString(val) + `"`)
+	}
+	if token.Data == "a" {
+		buf.WriteString(` target="_blank" rel="noopener noreferrer"`)
+	}
+	buf.WriteString(">")
+}
+
+// safeURL сообщает, является ли ссылка относительной или использует разрешённую схему.
+func safeURL(raw string, schemes map[string]bool) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	return u.Scheme == "" || schemes[strings.ToLower(u.Scheme)]
+}
+
+// Text возвращает текст фрагмента без тегов для поиска и превью.
+// Текст удалённых вместе с содержимым тегов не попадает в результат,
+// пробельные символы схлопываются.
+func Text(fragment string) string {
+	var buf strings.Builder
+	var skip skipper
+
+	tokenizer := html.NewTokenizer(strings.NewReader(fragment))
+	for {
+		tt := tokenizer.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		token := tokenizer.Token()
+		if skip.skipping(tt, token) {
+			continue
+		}
+
+		switch tt {
+		case html.TextToken:
+			buf.WriteString(token.Data)
+		case html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+			if blockTags[token.Data] {
+				buf.WriteString(" ")
+			}
+		}
+	}
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
+
+// skipper отслеживает, находится ли токенизатор внутри тега из droppedTags.
+type skipper struct {
+	tag   string // Тег, содержимое которого пропускается
+	depth int    // Глубина вложенности одноимённых тегов
+}
+
+// skipping сообщает, нужно ли пропустить токен.
+func (s *skipper) skipping(tt html.TokenType, token html.Token) bool {
+	if s.depth == 0 {
+		if tt == html.StartTagToken && droppedTags[token.Data] {
+			s.tag, s.depth = token.Data, 1
+			return true
+		}
+		// Одиночные и лишние закрывающие опасные теги просто пропускаются
+		return (tt == html.SelfClosingTagToken || tt == html.EndTagToken) && droppedTags[token.Data]
+	}
+	if token.Data == s.tag {
+		switch tt {
+		case html.StartTagToken:
+			s.depth++
+		case html.EndTagToken:
+			s.depth--
+		}
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package sanitize
+
+import (
+	"testing"
+
+	"Task36a41/pkg/rss"
+)
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "разрешённая разметка",
+			input: `<p>Текст <b>жирный</b> и <em>курсив</em><br/></p>`,
+			want:  `<p>Текст <b>жирный</b> и <em>курсив</em><br></p>`,
+		},
+		{
+			name:  "скрипты и стили удаляются с содержимым",
+			input: `<p>до</p><script>alert(1)</script><style>p{}</style><iframe src="https://evil.example"></iframe><p>после</p>`,
+			want:  `<p>до</p><p>после</p>`,
+		},
+		{
+			name:  "обработчики событий и стили удаляются",
+			input: `<p onclick="alert(1)" style="color:red" class="x">текст</p><img src="https://example.com/a.png" onerror="alert(1)" alt="a">`,
+			want:  `<p>текст</p><img src="https://example.com/a.png" alt="a">`,
+		},
+		{
+			name:  "ссылки получают noopener",
+			input: `<a href="https://example.com/" target="_self" rel="opener">ссылка</a>`,
+			want:  `<a href="https://example.com/" target="_blank" rel="noopener noreferrer">ссылка</a>`,
+		},
+		{
+			name:  "опасные схемы",
+			input: `<a href=" JavaScript:alert(1)">a</a><img src="data:image/svg+xml;base64,AAAA">`,
+			want:  `<a target="_blank" rel="noopener noreferrer">a</a><img>`,
+		},
+		{
+			name:  "неизвестные теги удаляются с сохранением текста",
+			input: `<div><font color="red">текст</font></div>`,
+			want:  `текст`,
+		},
+		{
+			name:  "незакрытые и лишние теги",
+			input: `<p><b>жирный<i>курсив</p></b></i><ul><li>пункт`,
+			want:  `<p><b>жирный<i>курсив</i></b></p><ul><li>пункт</li></ul>`,
+		},
+		{
+			name:  "текст экранируется",
+			input: `5 &lt; 6 &amp; "кавычки"`,
+			want:  `5 &lt; 6 &amp; &#34;кавычки&#34;`,
+		},
+		{
+			name:  "вложенные svg",
+			input: `<svg><svg><script>alert(1)</script></svg><a href="javascript:1">x</a></svg>после`,
+			want:  `после`,
+		},
+		{
+			name:  "комментарии",
+			input: `<!--[if IE]><script>alert(1)</script><![endif]-->текст`,
+			want:  `текст`,
+		},
+	}
+	for _, tt := range tests {
+		if got := HTML(tt.input); got != tt.want {
+			t.Errorf("%s: HTML(%q) = %q, ожидалось %q", tt.name, tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`<p>Первый абзац</p><p>Второй&nbsp;абзац</p>`, "Первый абзац Второй абзац"},
+		{`<ul><li>один</li><li>два</li></ul>`, "один два"},
+		{`<b>слитно</b><i>написано</i>`, "слитнонаписано"},
+		{`<script>alert(1)</script>  текст   с   пробелами `, "текст с пробелами"},
+		{`5 &lt; 6`, "5 < 6"},
+	}
+	for _, tt := range tests {
+		if got := Text(tt.input); got != tt.want {
+			t.Errorf("Text(%q) = %q, ожидалось %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestPost(t *testing.T) {
+	post := rss.Post{Content: `<p>Привет, <a href="https://go.dev">Go</a>!</p><script>alert(1)</script>`}
+	Post(&post)
+	if post.Content != `<p>Привет, <a href="https://go.dev" target="_blank" rel="noopener noreferrer">Go</a>!</p>` {
+		t.Errorf("неверное содержание: %q", post.Content)
+	}
+	if post.ContentText != "Привет, Go!" {
+		t.Errorf("неверный текст: %q", post.ContentText)
+	}
+}
@@ -0,0 +1,418 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"Task36a41/pkg/extract"
+	"Task36a41/pkg/metrics"
+	"Task36a41/pkg/rss"
+	"Task36a41/pkg/sanitize"
+	"Task36a41/pkg/storage"
+	"Task36a41/pkg/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tickInterval      = 15 * time.Second // Как часто планировщик проверяет, какие ленты пора опросить
+	extractionWorkers = 4                // Сколько страниц публикаций одной ленты загружается одновременно
+)
+
+// MaxBackoff — максимальная задержка между опросами неработающей ленты.
+// Ленту с интервалом опроса не больше MaxBackoff планировщик опрашивает
+// не реже раза в MaxBackoff, даже если она постоянно отвечает ошибкой
+// или просит повторить запрос позже в Retry-After.
+const MaxBackoff = 6 * time.Hour
+
+// Feed описывает ленту и её собственный интервал опроса.
+type Feed struct {
+	URL      string
+	Interval time.Duration
+	FullText bool // Загружать полный текст статей со страниц публикаций
+}
+
+// Store — хранилище, которое использует планировщик.
+type Store interface {
+	rss.ValidatorStore
+	SavePosts(ctx context.Context, posts []rss.Post) (storage.SaveReport, error)
+	SaveFeedStatus(ctx context.Context, status storage.FeedStatus) error
+	GetFeedStatuses(ctx context.Context) ([]storage.FeedStatus, error)
+	GetSources(ctx context.Context) ([]storage.Source, error)
+}
+
+// FetchFunc загружает ленту с учётом валидаторов кэша. Отмена ctx прерывает загрузку.
+type FetchFunc func(ctx context.Context, url string, v rss.Validators) (*rss.Feed, rss.Validators, error)
+
+// ExtractFunc загружает страницу публикации и возвращает полный текст статьи.
+type ExtractFunc func(ctx context.Context, url string) (string, error)
+
+// feedState — состояние ленты между опросами.
+type feedState struct {
+	status    storage.FeedStatus
+	ttl       time.Duration   // Последний <ttl> ленты
+	extracted map[string]bool // Ссылки публикаций, полный текст которых уже загружен
+}
+
+// Scheduler опрашивает каждую ленту по её собственному расписанию,
+// увеличивая интервал при ошибках и записывая состояние лент в хранилище.
+type Scheduler struct {
+	store           Store
+	defaultInterval time.Duration
+	feeds           func(ctx context.Context) ([]Feed, error)
+	fetch           FetchFunc
+	extract         ExtractFunc
+	onNewPosts      func() // Вызывается после сохранения новых публикаций
+	now             func() time.Time
+
+	mu       sync.Mutex
+	state    map[string]*feedState
+	inFlight map[string]bool // Ленты, опрос которых ещё идёт
+	polls    sync.WaitGroup  // Идущие опросы
+}
+
+// New создаёт планировщик для включённых источников из хранилища. Список источников
+// перечитывается на каждом шаге, поэтому изменения через API применяются без перезапуска.
+// Источники без собственного интервала опрашиваются раз в defaultInterval.
+func New(store Store, defaultInterval time.Duration) *Scheduler {
+	s := &Scheduler{
+		store:           store,
+		defaultInterval: defaultInterval,
+		fetch:           rss.FetchRSSConditional,
+		extract:         extract.Fetch,
+		onNewPosts:      func() {},
+		now:             time.Now,
+		state:           make(map[string]*feedState),
+		inFlight:        make(map[string]bool),
+	}
+	s.feeds = s.enabledFeeds
+	return s
+}
+
+// OnNewPosts задаёт функцию, которую планировщик вызывает каждый раз, когда
+// опрос ленты добавил в хранилище новые публикации. Функция вызывается
+// из нескольких горутин одновременно.
+func (s *Scheduler) OnNewPosts(f func()) {
+	s.onNewPosts = f
+}
+
+// enabledFeeds возвращает включённые источники из хранилища с их интервалами опроса.
+func (s *Scheduler) enabledFeeds(ctx context.Context) ([]Feed, error) {
+	sources, err := s.store.GetSources(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var feeds []Feed
+	for _, source := range sources {
+		if !source.Enabled {
+			continue
+		}
+		interval := s.defaultInterval
+		if source.PollInterval > 0 {
+			interval = time.Duration(source.PollInterval) * time.Minute
+		}
+		feeds = append(feeds, Feed{URL: source.URL, Interval: interval, FullText: source.FullText})
+	}
+	return feeds, nil
+}
+
+// Run восстанавливает состояние лент из хранилища и опрашивает их до отмены контекста.
+// Отмена прерывает идущие загрузки; Run возвращается, когда все опросы завершены,
+// после этого хранилище можно закрывать.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.restore(ctx)
+
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	for {
+		s.pollDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			s.wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// restore загружает сохранённое состояние лент, чтобы после перезапуска
+// не сбрасывать счётчики ошибок и время следующего опроса.
+func (s *Scheduler) restore(ctx context.Context) {
+	statuses, err := s.store.GetFeedStatuses(ctx)
+	if err != nil {
+		log.Printf("Ошибка при загрузке состояния лент: %v", err)
+		return
+	}
+	for _, status := range statuses {
+		s.state[status.URL] = &feedState{status: status}
+	}
+}
+
+// pollDue запускает опрос всех лент, для которых наступило время опроса, и не ждёт
+// его завершения: зависшая лента или долгая загрузка полного текста не задерживают
+// опрос остальных. Ленты, опрос которых ещё идёт, пропускаются до его завершения.
+// Состояние отключённых и удалённых лент забывается.
+func (s *Scheduler) pollDue(ctx context.Context) {
+	now := s.now()
+
+	feeds, err := s.feeds(ctx)
+	if err != nil {
+		log.Printf("Ошибка при загрузке списка источников: %v", err)
+		return
+	}
+	active := make(map[string]bool, len(feeds))
+	for _, feed := range feeds {
+		active[feed.URL] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for url := range s.state {
+		if !active[url] && !s.inFlight[url] {
+			delete(s.state, url)
+			metrics.ForgetSource(url)
+		}
+	}
+
+	for _, feed := range feeds {
+		if s.inFlight[feed.URL] {
+			continue
+		}
+		state, ok := s.state[feed.URL]
+		if !ok {
+			state = &feedState{status: storage.FeedStatus{URL: feed.URL}}
+			s.state[feed.URL] = state
+		}
+		if state.status.NextPollAt != nil && state.status.NextPollAt.After(now) {
+			continue
+		}
+
+		s.inFlight[feed.URL] = true
+		s.polls.Add(1)
+		go func(feed Feed, state feedState) {
+			defer s.polls.Done()
+			// Спан опроса охватывает и сохранение состояния ленты
+			ctx, span := tracing.Tracer().Start(ctx, "poll feed", trace.WithAttributes(attribute.String("feed.url", feed.URL)))
+			defer span.End()
+			s.finish(ctx, feed.URL, s.poll(ctx, feed, state))
+		}(feed, *state)
+	}
+}
+
+// finish запоминает и сохраняет состояние ленты после опроса.
+func (s *Scheduler) finish(ctx context.Context, url string, result feedState) {
+	s.mu.Lock()
+	s.state[url] = &result
+	delete(s.inFlight, url)
+	s.mu.Unlock()
+
+	// Состояние сохраняется и после отмены ctx, чтобы не потерять итог опроса при остановке
+	if err := s.store.SaveFeedStatus(context.WithoutCancel(ctx), result.status); err != nil {
+		log.Printf("Ошибка при сохранении состояния ленты %s: %v", url, err)
+	}
+}
+
+// wait дожидается завершения всех начатых опросов.
+func (s *Scheduler) wait() {
+	s.polls.Wait()
+}
+
+// poll опрашивает одну ленту и возвращает её новое состояние. Результат записывается
+// в спан опроса из ctx, запросы к хранилищу и загрузки становятся его дочерними спанами.
+// Опрос, прерванный отменой ctx, не считается ошибкой: состояние ленты не меняется,
+// и после перезапуска она будет опрошена сразу.
+func (s *Scheduler) poll(ctx context.Context, feed Feed, state feedState) feedState {
+	span := trace.SpanFromContext(ctx)
+	// observe записывает результат опроса в метрики и спан
+	observe := func(result string, err error) {
+		metrics.ObserveFetch(feed.URL, result)
+		span.SetAttributes(attribute.String("feed.result", result))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+
+	validators, err := s.store.GetValidators(ctx, feed.URL)
+	if err != nil {
+		log.Printf("Ошибка при чтении валидаторов кэша для %s: %v", feed.URL, err)
+	}
+
+	fetchCtx, fetchSpan := tracing.Tracer().Start(ctx, "fetch feed", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.URLFull(feed.URL)))
+	result, newValidators, err := s.fetch(fetchCtx, feed.URL, validators)
+	if errors.Is(err, rss.ErrNotModified) {
+		fetchSpan.End()
+	} else {
+		tracing.End(fetchSpan, err)
+	}
+	if ctx.Err() != nil {
+		log.Printf("Опрос %s прерван: %v", feed.URL, ctx.Err())
+		return state
+	}
+	if errors.Is(err, rss.ErrNotModified) {
+		log.Printf("Лента %s не изменилась с прошлого опроса", feed.URL)
+		observe(metrics.FetchNotModified, nil)
+		return s.succeeded(feed, state, state.status.ItemCount)
+	}
+	if err != nil {
+		log.Printf("Ошибка при получении RSS с %s: %v", feed.URL, err)
+		observe(metrics.FetchError, err)
+		return s.failed(feed, state, err)
+	}
+
+	// Содержание из ленты очищается от скриптов и опасных атрибутов до сохранения
+	for i := range result.Posts {
+		result.Posts[i].Source = feed.URL
+		sanitize.Post(&result.Posts[i])
+	}
+	var extracted map[string]bool
+	if feed.FullText {
+		extracted = s.extractFullText(ctx, feed, result.Posts, state.extracted)
+		// Без полного текста публикации не сохраняем: валидаторы тоже не сохранены,
+		// поэтому следующий опрос получит их снова
+		if ctx.Err() != nil {
+			log.Printf("Опрос %s прерван: %v", feed.URL, ctx.Err())
+			return state
+		}
+	}
+
+	// Валидаторы сохраняем только после публикаций, иначе при ошибке записи
+	// следующий опрос получит 304 и публикации будут потеряны.
+	// Загруженная лента сохраняется целиком, даже если ctx отменят во время записи.
+	storeCtx := context.WithoutCancel(ctx)
+	report, err := s.store.SavePosts(storeCtx, result.Posts)
+	if err != nil {
+		log.Printf("Ошибка при сохранении публикаций из %s: %v", feed.URL, err)
+		observe(metrics.FetchError, err)
+		return s.failed(feed, state, fmt.Errorf("could not save posts: %v", err))
+	}
+	log.Printf("Публикации из %s сохранены: %s", feed.URL, report)
+	observe(metrics.FetchSuccess, nil)
+	metrics.ObserveItems(feed.URL, len(result.Posts), report.Inserted)
+	if report.Inserted > 0 {
+		s.onNewPosts()
+	}
+	for _, skipped := range report.Skipped {
+		log.Printf("Публикация %q (%s) из %s пропущена: %s", skipped.Title, skipped.Link, feed.URL, skipped.Reason)
+	}
+	if newValidators != validators {
+		if err := s.store.SaveValidators(storeCtx, feed.URL, newValidators); err != nil {
+			log.Printf("Ошибка при сохранении валидаторов кэша для %s: %v", feed.URL, err)
+		}
+	}
+
+	state.ttl = result.TTL
+	state.extracted = extracted
+	return s.succeeded(feed, state, len(result.Posts))
+}
+
+// extractFullText загружает полный текст статей для публикаций, у которых его ещё нет,
+// и возвращает ссылки публикаций ленты, для которых текст загружен. Статьи, загруженные
+// при прошлых опросах, не запрашиваются повторно: хранилище сохраняет их текст,
+// пока публикация приходит без него. После перезапуска текст загружается заново один раз.
+// Ошибки загрузки не мешают сохранить публикацию с анонсом из ленты.
+func (s *Scheduler) extractFullText(ctx context.Context, feed Feed, posts []rss.Post, extracted map[string]bool) map[string]bool {
+	done := make(map[string]bool)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, extractionWorkers)
+
+	for i := range posts {
+		link := posts[i].Link
+		if link == "" {
+			continue
+		}
+		if extracted[link] {
+			done[link] = true
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(post *rss.Post) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			ctx, span := tracing.Tracer().Start(ctx, "extract full text", trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(semconv.URLFull(post.Link)))
+			content, err := s.extract(ctx, post.Link)
+			tracing.End(span, err)
+			if err != nil {
+				log.Printf("Ошибка при загрузке полного текста %s из %s: %v", post.Link, feed.URL, err)
+				return
+			}
+			post.FullContent = content
+			mu.Lock()
+			done[post.Link] = true
+			mu.Unlock()
+		}(&posts[i])
+	}
+	wg.Wait()
+	return done
+}
+
+// succeeded отмечает успешный опрос и планирует следующий с учётом <ttl> ленты.
+func (s *Scheduler) succeeded(feed Feed, state feedState, itemCount int) feedState {
+	now := s.now()
+	interval := feed.Interval
+	if state.ttl > interval {
+		interval = state.ttl
+	}
+	next := now.Add(interval)
+
+	state.status.LastSuccessAt = &now
+	state.status.ConsecutiveFailures = 0
+	state.status.ItemCount = itemCount
+	state.status.NextPollAt = &next
+	return state
+}
+
+// failed отмечает ошибку опроса и откладывает следующий с экспоненциальной задержкой
+// или на время из Retry-After, если оно больше. Retry-After ограничен так же,
+// как задержка: сервер ленты не может отложить её опрос больше чем на MaxBackoff.
+func (s *Scheduler) failed(feed Feed, state feedState, err error) feedState {
+	now := s.now()
+	state.status.ConsecutiveFailures++
+	state.status.LastError = err.Error()
+	state.status.LastErrorAt = &now
+
+	delay := backoff(feed.Interval, state.status.ConsecutiveFailures)
+	var httpErr *rss.HTTPError
+	if errors.As(err, &httpErr) && httpErr.RetryAfter > delay {
+		delay = min(httpErr.RetryAfter, backoffLimit(feed.Interval))
+	}
+	next := now.Add(delay)
+	state.status.NextPollAt = &next
+	return state
+}
+
+// backoff возвращает задержку после failures ошибок подряд: интервал удваивается
+// с каждой ошибкой, но не превышает MaxBackoff (или сам интервал, если он больше).
+func backoff(interval time.Duration, failures int) time.Duration {
+	limit := backoffLimit(interval)
+	delay := interval
+	for i := 0; i < failures; i++ {
+		delay *= 2
+		if delay >= limit {
+			return limit
+		}
+	}
+	return delay
+}
+
+// backoffLimit возвращает наибольшую задержку после ошибки: MaxBackoff
+// или интервал опроса ленты, если он больше.
+func backoffLimit(interval time.Duration) time.Duration {
+	return max(interval, MaxBackoff)
+}
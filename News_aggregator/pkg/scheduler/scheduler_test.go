@@ -0,0 +1,432 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"Task36a41/pkg/rss"
+	"Task36a41/pkg/storage"
+)
+
+// fakeStore — хранилище в памяти для тестов планировщика.
+// Ленты опрашиваются параллельно, поэтому методы защищены мьютексом.
+type fakeStore struct {
+	mu         sync.Mutex
+	posts      []rss.Post
+	validators map[string]rss.Validators
+	statuses   map[string]storage.FeedStatus
+	sources    []storage.Source
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		validators: map[string]rss.Validators{},
+		statuses:   map[string]storage.FeedStatus{},
+	}
+}
+
+func (f *fakeStore) GetValidators(ctx context.Context, url string) (rss.Validators, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.validators[url], nil
+}
+func (f *fakeStore) SaveValidators(ctx context.Context, url string, v rss.Validators) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.validators[url] = v
+	return nil
+}
+func (f *fakeStore) SavePosts(ctx context.Context, posts []rss.Post) (storage.SaveReport, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.posts = append(f.posts, posts...)
+	return storage.SaveReport{Inserted: len(posts)}, nil
+}
+func (f *fakeStore) SaveFeedStatus(ctx context.Context, status storage.FeedStatus) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statuses[status.URL] = status
+	return nil
+}
+func (f *fakeStore) GetFeedStatuses(ctx context.Context) ([]storage.FeedStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var statuses []storage.FeedStatus
+	for _, status := range f.statuses {
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+func (f *fakeStore) GetFeedStatusOf(url string) (storage.FeedStatus, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	status, ok := f.statuses[url]
+	return status, ok
+}
+func (f *fakeStore) GetSources(ctx context.Context) ([]storage.Source, error) { return f.sources, nil }
+
+// newTestScheduler создаёт планировщик с управляемыми временем и загрузкой лент.
+func newTestScheduler(store Store, feeds []Feed, fetch FetchFunc, now *time.Time) *Scheduler {
+	s := New(store, 0)
+	s.feeds = func(ctx context.Context) ([]Feed, error) { return feeds, nil }
+	s.fetch = fetch
+	s.now = func() time.Time { return *now }
+	return s
+}
+
+// pollOnce запускает опрос наступивших лент и дожидается его завершения.
+func pollOnce(s *Scheduler) {
+	s.pollDue(context.Background())
+	s.wait()
+}
+
+func TestSchedulerPerFeedIntervals(t *testing.T) {
+	now := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	store := newFakeStore()
+	calls := map[string]int{}
+	fetch := func(ctx context.Context, url string, v rss.Validators) (*rss.Feed, rss.Validators, error) {
+		calls[url]++
+		return &rss.Feed{Posts: []rss.Post{{Title: url, Link: url}}}, rss.Validators{ETag: `"1"`}, nil
+	}
+	feeds := []Feed{
+		{URL: "fast", Interval: 5 * time.Minute},
+		{URL: "slow", Interval: time.Hour},
+	}
+	s := newTestScheduler(store, feeds, fetch, &now)
+
+	pollOnce(s)
+	now = now.Add(5 * time.Minute)
+	pollOnce(s)
+
+	if calls["fast"] != 2 || calls["slow"] != 1 {
+		t.Errorf("Expected fast=2 slow=1 polls, got %v", calls)
+	}
+	if len(store.posts) != 3 {
+		t.Errorf("Expected 3 saved posts, got %d", len(store.posts))
+	}
+	if store.validators["fast"].ETag != `"1"` {
+		t.Errorf("Expected validators to be saved, got %+v", store.validators["fast"])
+	}
+	status := store.statuses["slow"]
+	if status.ItemCount != 1 || status.LastSuccessAt == nil || !status.NextPollAt.Equal(now.Add(55*time.Minute)) {
+		t.Errorf("Unexpected status for slow feed: %+v", status)
+	}
+}
+
+func TestSchedulerSanitizesContent(t *testing.T) {
+	now := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	store := newFakeStore()
+	fetch := func(ctx context.Context, url string, v rss.Validators) (*rss.Feed, rss.Validators, error) {
+		post := rss.Post{Title: "Post", Link: url, Content: `<p onclick="steal()">Hello</p><script>steal()</script>`}
+		return &rss.Feed{Posts: []rss.Post{post}}, rss.Validators{}, nil
+	}
+	s := newTestScheduler(store, []Feed{{URL: "feed", Interval: time.Hour}}, fetch, &now)
+
+	pollOnce(s)
+
+	if len(store.posts) != 1 {
+		t.Fatalf("Expected 1 saved post, got %d", len(store.posts))
+	}
+	if post := store.posts[0]; post.Content != "<p>Hello</p>" || post.ContentText != "Hello" {
+		t.Errorf("Expected sanitized content, got %q / %q", post.Content, post.ContentText)
+	}
+}
+
+func TestSchedulerNotifiesNewPosts(t *testing.T) {
+	now := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	store := newFakeStore()
+	var fetchErr error
+	fetch := func(ctx context.Context, url string, v rss.Validators) (*rss.Feed, rss.Validators, error) {
+		if fetchErr != nil {
+			return nil, v, fetchErr
+		}
+		return &rss.Feed{Posts: []rss.Post{{Title: "Post", Link: url}}}, rss.Validators{}, nil
+	}
+	s := newTestScheduler(store, []Feed{{URL: "feed", Interval: time.Minute}}, fetch, &now)
+	var notified int32
+	s.OnNewPosts(func() { atomic.AddInt32(&notified, 1) })
+
+	pollOnce(s)
+	if notified != 1 {
+		t.Errorf("Expected 1 notification after new posts, got %d", notified)
+	}
+
+	// Неизменившаяся лента не добавляет публикаций
+	fetchErr = rss.ErrNotModified
+	now = now.Add(time.Minute)
+	pollOnce(s)
+	if notified != 1 {
+		t.Errorf("Expected no notification for unchanged feed, got %d", notified)
+	}
+}
+
+func TestSchedulerCancelMidFetch(t *testing.T) {
+	now := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	store := newFakeStore()
+	started := make(chan struct{})
+	fetch := func(ctx context.Context, url string, v rss.Validators) (*rss.Feed, rss.Validators, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, v, ctx.Err()
+	}
+	s := newTestScheduler(store, []Feed{{URL: "feed", Interval: time.Minute}}, fetch, &now)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	<-started
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+
+	// Прерванный опрос не считается ошибкой и не откладывает следующий
+	status := store.statuses["feed"]
+	if status.ConsecutiveFailures != 0 || status.NextPollAt != nil {
+		t.Errorf("Expected unchanged status after cancel, got %+v", status)
+	}
+}
+
+func TestSchedulerFullText(t *testing.T) {
+	now := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	store := newFakeStore()
+	fetch := func(ctx context.Context, url string, v rss.Validators) (*rss.Feed, rss.Validators, error) {
+		return &rss.Feed{Posts: []rss.Post{
+			{Title: "A", Link: "http://example.com/a", Content: "Teaser A"},
+			{Title: "B", Link: "http://example.com/b", Content: "Teaser B"},
+		}}, rss.Validators{}, nil
+	}
+	s := newTestScheduler(store, []Feed{{URL: "feed", Interval: time.Minute, FullText: true}}, fetch, &now)
+	var mu sync.Mutex
+	extracted := map[string]int{}
+	s.extract = func(ctx context.Context, url string) (string, error) {
+		mu.Lock()
+		extracted[url]++
+		mu.Unlock()
+		if url == "http://example.com/b" {
+			return "", errors.New("timeout")
+		}
+		return "<p>Full " + url + "</p>", nil
+	}
+
+	pollOnce(s)
+	if len(store.posts) != 2 || store.posts[0].FullContent != "<p>Full http://example.com/a</p>" || store.posts[1].FullContent != "" {
+		t.Fatalf("Unexpected saved posts: %+v", store.posts)
+	}
+
+	// Загруженная статья не запрашивается повторно, неудавшаяся — запрашивается
+	now = now.Add(time.Minute)
+	pollOnce(s)
+	if extracted["http://example.com/a"] != 1 || extracted["http://example.com/b"] != 2 {
+		t.Errorf("Unexpected extraction calls: %v", extracted)
+	}
+}
+
+func TestSchedulerHonoursTTL(t *testing.T) {
+	now := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	store := newFakeStore()
+	fetch := func(ctx context.Context, url string, v rss.Validators) (*rss.Feed, rss.Validators, error) {
+		return &rss.Feed{TTL: 30 * time.Minute}, v, nil
+	}
+	s := newTestScheduler(store, []Feed{{URL: "feed", Interval: 5 * time.Minute}}, fetch, &now)
+
+	pollOnce(s)
+
+	if next := store.statuses["feed"].NextPollAt; !next.Equal(now.Add(30 * time.Minute)) {
+		t.Errorf("Expected next poll after TTL, got %v", next)
+	}
+}
+
+func TestSchedulerBackoff(t *testing.T) {
+	now := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	store := newFakeStore()
+	var fetchErr error = errors.New("connection refused")
+	fetch := func(ctx context.Context, url string, v rss.Validators) (*rss.Feed, rss.Validators, error) {
+		if fetchErr != nil {
+			return nil, v, fetchErr
+		}
+		return &rss.Feed{}, v, nil
+	}
+	s := newTestScheduler(store, []Feed{{URL: "feed", Interval: 5 * time.Minute}}, fetch, &now)
+
+	// Каждая ошибка подряд удваивает задержку
+	for i, want := range []time.Duration{10 * time.Minute, 20 * time.Minute, 40 * time.Minute} {
+		pollOnce(s)
+		status := store.statuses["feed"]
+		if status.ConsecutiveFailures != i+1 {
+			t.Errorf("Expected %d consecutive failures, got %d", i+1, status.ConsecutiveFailures)
+		}
+		if !status.NextPollAt.Equal(now.Add(want)) {
+			t.Errorf("Expected next poll in %v, got %v", want, status.NextPollAt.Sub(now))
+		}
+		if status.LastError != "connection refused" {
+			t.Errorf("Unexpected last error: %q", status.LastError)
+		}
+		now = *status.NextPollAt
+	}
+
+	// Retry-After больше задержки — используется он
+	fetchErr = fmt.Errorf("failed to fetch RSS: %w", &rss.HTTPError{StatusCode: 503, Status: "503 Service Unavailable", RetryAfter: 3 * time.Hour})
+	pollOnce(s)
+	if next := store.statuses["feed"].NextPollAt; !next.Equal(now.Add(3 * time.Hour)) {
+		t.Errorf("Expected Retry-After to be honoured, got %v", next.Sub(now))
+	}
+	now = *store.statuses["feed"].NextPollAt
+
+	// Retry-After больше MaxBackoff ограничивается им
+	fetchErr = fmt.Errorf("failed to fetch RSS: %w", &rss.HTTPError{StatusCode: 429, Status: "429 Too Many Requests", RetryAfter: 30 * 24 * time.Hour})
+	pollOnce(s)
+	if next := store.statuses["feed"].NextPollAt; !next.Equal(now.Add(MaxBackoff)) {
+		t.Errorf("Expected Retry-After to be capped at %v, got %v", MaxBackoff, next.Sub(now))
+	}
+	now = *store.statuses["feed"].NextPollAt
+
+	// Успешный опрос сбрасывает счётчик ошибок
+	fetchErr = nil
+	pollOnce(s)
+	if status := store.statuses["feed"]; status.ConsecutiveFailures != 0 || status.LastSuccessAt == nil {
+		t.Errorf("Expected failures to be reset, got %+v", status)
+	}
+}
+
+func TestSchedulerNotModified(t *testing.T) {
+	now := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	store := newFakeStore()
+	store.statuses["feed"] = storage.FeedStatus{URL: "feed", ItemCount: 7}
+	fetch := func(ctx context.Context, url string, v rss.Validators) (*rss.Feed, rss.Validators, error) {
+		return nil, v, rss.ErrNotModified
+	}
+	s := newTestScheduler(store, []Feed{{URL: "feed", Interval: 5 * time.Minute}}, fetch, &now)
+	s.restore(context.Background())
+
+	pollOnce(s)
+
+	status := store.statuses["feed"]
+	if status.ConsecutiveFailures != 0 || status.ItemCount != 7 || status.LastSuccessAt == nil {
+		t.Errorf("Expected 304 to count as success without new items, got %+v", status)
+	}
+	if len(store.posts) != 0 {
+		t.Errorf("Expected no saved posts, got %d", len(store.posts))
+	}
+}
+
+func TestSchedulerSources(t *testing.T) {
+	now := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	store := newFakeStore()
+	store.sources = []storage.Source{
+		{ID: 1, URL: "default", Enabled: true},
+		{ID: 2, URL: "hourly", Enabled: true, PollInterval: 60},
+		{ID: 3, URL: "disabled", Enabled: false},
+	}
+	calls := map[string]int{}
+	fetch := func(ctx context.Context, url string, v rss.Validators) (*rss.Feed, rss.Validators, error) {
+		calls[url]++
+		return &rss.Feed{}, v, nil
+	}
+	s := New(store, 5*time.Minute)
+	s.fetch = fetch
+	s.now = func() time.Time { return now }
+
+	pollOnce(s)
+
+	if calls["default"] != 1 || calls["hourly"] != 1 || calls["disabled"] != 0 {
+		t.Errorf("Expected only enabled sources to be polled, got %v", calls)
+	}
+	if next := store.statuses["hourly"].NextPollAt; !next.Equal(now.Add(time.Hour)) {
+		t.Errorf("Expected source poll interval to be used, got %v", next.Sub(now))
+	}
+	if next := store.statuses["default"].NextPollAt; !next.Equal(now.Add(5 * time.Minute)) {
+		t.Errorf("Expected default poll interval to be used, got %v", next.Sub(now))
+	}
+
+	// Удалённый источник больше не опрашивается, а его состояние забывается
+	store.sources = store.sources[1:]
+	now = now.Add(time.Hour)
+	pollOnce(s)
+	if calls["default"] != 1 || calls["hourly"] != 2 {
+		t.Errorf("Expected removed source not to be polled, got %v", calls)
+	}
+	if _, ok := s.state["default"]; ok {
+		t.Error("Expected state of removed source to be dropped")
+	}
+}
+
+func TestBackoffLimit(t *testing.T) {
+	if got := backoff(5*time.Minute, 20); got != MaxBackoff {
+		t.Errorf("Expected backoff to be capped at %v, got %v", MaxBackoff, got)
+	}
+	if got := backoff(12*time.Hour, 3); got != 12*time.Hour {
+		t.Errorf("Expected backoff not to go below interval, got %v", got)
+	}
+}
+
+func TestSchedulerSkipsFeedsInFlight(t *testing.T) {
+	now := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	store := newFakeStore()
+	release := make(chan struct{})
+	var mu sync.Mutex
+	calls := map[string]int{}
+	fetch := func(ctx context.Context, url string, v rss.Validators) (*rss.Feed, rss.Validators, error) {
+		mu.Lock()
+		calls[url]++
+		mu.Unlock()
+		if url == "hanging" {
+			<-release
+		}
+		return &rss.Feed{}, v, nil
+	}
+	feeds := []Feed{
+		{URL: "hanging", Interval: time.Minute},
+		{URL: "fast", Interval: time.Minute},
+	}
+	s := newTestScheduler(store, feeds, fetch, &now)
+
+	// Зависшая лента не задерживает следующий шаг и не опрашивается повторно, пока её опрос идёт
+	s.pollDue(context.Background())
+	waitFor(t, func() bool {
+		_, ok := store.GetFeedStatusOf("fast")
+		return ok
+	})
+	now = now.Add(time.Minute)
+	s.pollDue(context.Background())
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls["fast"] == 2
+	})
+
+	mu.Lock()
+	if calls["hanging"] != 1 {
+		t.Errorf("Expected hanging feed to be fetched once while in flight, got %d", calls["hanging"])
+	}
+	mu.Unlock()
+
+	close(release)
+	s.wait()
+	if _, ok := store.GetFeedStatusOf("hanging"); !ok {
+		t.Error("Expected status of the hanging feed to be saved after it finished")
+	}
+}
+
+// waitFor ждёт выполнения условия не дольше нескольких секунд.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,68 @@
+// Пакет simhash вычисляет отпечатки SimHash для поиска почти одинаковых публикаций:
+// у текстов, отличающихся несколькими словами, отпечатки отличаются немногими битами.
+package simhash
+
+import (
+	"hash/fnv"
+	"strings"
+	"unicode"
+)
+
+// MaxDistance — наибольшее число различающихся битов, при котором
+// публикации считаются одной историей. Подобрано на анонсах из лент:
+// хвост вроде «Читать далее» меняет отпечаток коротких анонсов на 3–7 битов,
+// а разные тексты отличаются примерно на 32 бита.
+const MaxDistance = 10
+
+// minWords — минимальное число слов текста. Отпечаток слишком короткого
+// текста (например, одного заголовка из двух слов) ненадёжен.
+const minWords = 5
+
+// Fingerprint возвращает 64-битный отпечаток текста. Признаками служат слова
+// без учёта регистра и пунктуации. Для текста короче пяти слов возвращается 0:
+// такие тексты не сравниваются.
+func Fingerprint(text string) uint64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) < minWords {
+		return 0
+	}
+
+	var weights [64]int
+	for _, word := range words {
+		h := fnv.New64a()
+		h.Write([]byte(word))
+		sum := h.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for bit, w := range weights {
+		if w > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+	return fingerprint
+}
+
+// Distance возвращает число различающихся битов двух отпечатков (расстояние Хэмминга).
+func Distance(a, b uint64) int {
+	var n int
+	for x := a ^ b; x != 0; x &= x - 1 {
+		n++
+	}
+	return n
+}
+
+// Similar сообщает, что отпечатки принадлежат почти одинаковым текстам.
+// Нулевой отпечаток ни на что не похож.
+func Similar(a, b uint64) bool {
+	return a != 0 && b != 0 && Distance(a, b) <= MaxDistance
+}
@@ -0,0 +1,28 @@
+package simhash
+
+import "testing"
+
+const (
+	original  = "Сборка Go-проекта. Как мы ускорили сборку Go-проекта в три раза. Рассказываем, как кэширование модулей и параллельные тесты помогли сократить время CI. Читать далее"
+	republish = "Сборка Go-проекта. Как мы ускорили сборку Go-проекта в три раза. Рассказываем, как кэширование модулей и параллельные тесты помогли сократить время CI."
+	other     = "Выпущен Go 1.22 с новым синтаксисом циклов и улучшенным планировщиком, который лучше работает на многоядерных машинах."
+)
+
+func TestFingerprint(t *testing.T) {
+	a, b, c := Fingerprint(original), Fingerprint(republish), Fingerprint(other)
+	if !Similar(a, b) {
+		t.Errorf("перепубликация должна быть похожа: расстояние %d", Distance(a, b))
+	}
+	if Similar(a, c) {
+		t.Errorf("разные тексты не должны быть похожи: расстояние %d", Distance(a, c))
+	}
+	if Fingerprint("сборка GO проекта — КАК мы ускорили сборку go проекта в три раза рассказываем как кэширование модулей и параллельные тесты помогли сократить время ci читать далее") != a {
+		t.Error("отпечаток должен зависеть только от слов")
+	}
+	if Fingerprint("Новости недели") != 0 {
+		t.Error("для короткого текста ожидался нулевой отпечаток")
+	}
+	if Similar(0, 0) {
+		t.Error("нулевые отпечатки не должны быть похожи")
+	}
+}
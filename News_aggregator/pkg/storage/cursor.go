@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"Task36a41/pkg/rss"
+)
+
+// Cursor — позиция в выборке публикаций, упорядоченной по (pub_time, id).
+// В отличие от смещения, курсор не «съезжает», когда во время просмотра
+// появляются новые публикации, и не требует пропускать строки в БД.
+type Cursor struct {
+	PubTime  int64  `json:"t"`           // Время публикации граничной записи (Unix)
+	ID       int    `json:"id"`          // ID граничной записи
+	Sort     string `json:"s"`           // Порядок выборки: SortNewest или SortOldest
+	Backward bool   `json:"b,omitempty"` // Страница перед граничной записью, а не после неё
+}
+
+// Encode возвращает непрозрачное строковое представление курсора для передачи клиенту.
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor разбирает курсор, полученный от клиента.
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor encoding")
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid cursor format")
+	}
+	if c.Sort != SortNewest && c.Sort != SortOldest {
+		return nil, fmt.Errorf("invalid cursor sort order %q", c.Sort)
+	}
+	return &c, nil
+}
+
+// ascending сообщает, нужно ли выбирать строки по возрастанию (pub_time, id):
+// для порядка «сначала старые» при движении вперёд или «сначала новые» при движении назад.
+func (c Cursor) ascending() bool {
+	return (c.Sort == SortOldest) != c.Backward
+}
+
+// CursorAfter возвращает курсор страницы, следующей за публикацией post в порядке sort.
+// Публикация должна быть получена из хранилища: время берётся из её PubDate.
+func CursorAfter(post rss.Post, sort string) string {
+	return cursorAt(post, sort, false)
+}
+
+// CursorBefore возвращает курсор страницы, предшествующей публикации post в порядке sort.
+func CursorBefore(post rss.Post, sort string) string {
+	return cursorAt(post, sort, true)
+}
+
+func cursorAt(post rss.Post, sort string, backward bool) string {
+	pubTime, _ := rss.ParseDate(post.PubDate)
+	return Cursor{PubTime: pubTime.Unix(), ID: post.ID, Sort: sort, Backward: backward}.Encode()
+}
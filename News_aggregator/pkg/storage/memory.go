@@ -0,0 +1,520 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"Task36a41/pkg/rss"
+	"Task36a41/pkg/simhash"
+)
+
+// Memory — хранилище в памяти с той же семантикой, что и Storage.
+// Подходит для тестов и локальной демонстрации без PostgreSQL.
+// Безопасно для одновременного использования из нескольких горутин.
+type Memory struct {
+	mu         sync.RWMutex
+	posts      map[int]*memoryPost // Публикации по ID
+	byTime     []*memoryPost       // Публикации от новых к старым, как в sorted
+	byKey      map[string]int      // ID публикации по ключу дедупликации
+	nextID     int
+	validators map[string]rss.Validators
+	feeds      map[string]FeedStatus
+	sources    map[int]Source
+	nextSource int
+}
+
+// memoryPost — сохранённая публикация, время публикации в формате Unix и отпечаток текста.
+type memoryPost struct {
+	post        rss.Post
+	pubTime     int64
+	fingerprint uint64
+}
+
+// cluster возвращает ID кластера публикации: ID первой публикации кластера.
+func (p *memoryPost) cluster() int {
+	if p.post.ClusterID != 0 {
+		return p.post.ClusterID
+	}
+	return p.post.ID
+}
+
+// NewMemory создаёт пустое хранилище в памяти.
+func NewMemory() *Memory {
+	return &Memory{
+		posts:      make(map[int]*memoryPost),
+		byKey:      make(map[string]int),
+		nextID:     1,
+		validators: make(map[string]rss.Validators),
+		feeds:      make(map[string]FeedStatus),
+		sources:    make(map[int]Source),
+		nextSource: 1,
+	}
+}
+
+// Ping всегда успешен: хранилище в памяти доступно, пока работает процесс.
+func (m *Memory) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Close ничего не делает: хранилищу в памяти нечего закрывать.
+func (m *Memory) Close() error {
+	return nil
+}
+
+// SavePosts сохраняет публикации. Как и в Storage, публикации с уже известным
+// GUID или ссылкой обновляются, а ошибочные пропускаются и попадают в отчёт.
+func (m *Memory) SavePosts(ctx context.Context, posts []rss.Post) (SaveReport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var report SaveReport
+	for _, post := range posts {
+		result, err := m.upsert(post)
+		if err != nil {
+			report.skip(post, err)
+			continue
+		}
+		report.add(result)
+	}
+	return report, nil
+}
+
+// upsert сохраняет одну публикацию. Вызывается под блокировкой на запись.
+func (m *Memory) upsert(post rss.Post) (string, error) {
+	if post.Link == "" {
+		return "", fmt.Errorf("post has no link")
+	}
+	pubTime, err := rss.ParseDate(post.PubDate)
+	if err != nil {
+		return "", err
+	}
+
+	key := dedupKey(post)
+	if _, known := m.byKey[key]; !known && key != post.Link {
+		if id, ok := m.byLink(post.Link); ok {
+			// Публикация сохранена по ссылке до появления GUID
+			delete(m.byKey, post.Link)
+			m.byKey[key] = id
+			m.posts[id].post.GUID = post.GUID
+		}
+	}
+
+	if id, ok := m.byKey[key]; ok {
+		stored := &m.posts[id].post
+		if stored.Title == post.Title && stored.Content == post.Content && stored.ContentText == post.ContentText &&
+			stored.Link == post.Link &&
+			stored.Author == post.Author && reflect.DeepEqual(stored.Categories, post.Categories) &&
+			reflect.DeepEqual(stored.Enclosures, post.Enclosures) &&
+			(post.FullContent == "" || stored.FullContent == post.FullContent) {
+			return postUnchanged, nil
+		}
+		stored.Title = post.Title
+		stored.Content = post.Content
+		stored.ContentText = post.ContentText
+		stored.Link = post.Link
+		stored.Author = post.Author
+		stored.Categories = post.Categories
+		stored.Enclosures = post.Enclosures
+		if post.FullContent != "" {
+			stored.FullContent = post.FullContent
+		}
+		m.posts[id].fingerprint = postFingerprint(*stored)
+		return postUpdated, nil
+	}
+
+	fingerprint := postFingerprint(post)
+	post.ID = m.nextID
+	post.SourceID = m.sourceID(post.Source)
+	post.ClusterID = m.findCluster(fingerprint, pubTime)
+	m.nextID++
+	stored := &memoryPost{post: post, pubTime: pubTime.Unix(), fingerprint: fingerprint}
+	m.posts[post.ID] = stored
+	m.insertByTime(stored)
+	m.byKey[key] = post.ID
+	return postInserted, nil
+}
+
+// findCluster возвращает ID кластера самой похожей публикации, опубликованной
+// в пределах clusterWindow, или 0, если похожих нет. Как и запрос к PostgreSQL,
+// просматривает только публикации из этого окна. Вызывается под блокировкой.
+func (m *Memory) findCluster(fingerprint uint64, pubTime time.Time) int {
+	from, to := pubTime.Add(-clusterWindow).Unix(), pubTime.Add(clusterWindow).Unix()
+	start := sort.Search(len(m.byTime), func(i int) bool { return m.byTime[i].pubTime <= to })
+
+	var best *memoryPost
+	bestDistance := simhash.MaxDistance + 1
+	for _, p := range m.byTime[start:] {
+		if p.pubTime < from {
+			break
+		}
+		if !simhash.Similar(fingerprint, p.fingerprint) {
+			continue
+		}
+		// Публикации идут от новых к старым, поэтому при равном расстоянии выигрывает более ранняя
+		if d := simhash.Distance(fingerprint, p.fingerprint); d <= bestDistance {
+			best, bestDistance = p, d
+		}
+	}
+	if best == nil {
+		return 0
+	}
+	return best.cluster()
+}
+
+// insertByTime вставляет публикацию в byTime, сохраняя порядок от новых к старым.
+// Вызывается под блокировкой на запись.
+func (m *Memory) insertByTime(p *memoryPost) {
+	i := sort.Search(len(m.byTime), func(i int) bool { return !m.byTime[i].newerThan(p) })
+	m.byTime = append(m.byTime, nil)
+	copy(m.byTime[i+1:], m.byTime[i:])
+	m.byTime[i] = p
+}
+
+// newerThan сообщает, идёт ли публикация раньше other в порядке от новых к старым.
+func (p *memoryPost) newerThan(other *memoryPost) bool {
+	if p.pubTime != other.pubTime {
+		return p.pubTime > other.pubTime
+	}
+	return p.post.ID > other.post.ID
+}
+
+// byLink возвращает ID публикации, сохранённой по ссылке без GUID.
+// Вызывается под блокировкой.
+func (m *Memory) byLink(link string) (int, bool) {
+	id, ok := m.byKey[link]
+	if !ok || m.posts[id].post.GUID != "" {
+		return 0, false
+	}
+	return id, true
+}
+
+// sorted возвращает публикации, подходящие под фильтр, от новых к старым.
+// Вызывается под блокировкой на чтение.
+func (m *Memory) sorted(match func(rss.Post) bool) []*memoryPost {
+	var result []*memoryPost
+	for _, p := range m.byTime {
+		if match == nil || match(p.post) {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// export возвращает копию публикации с датой в формате RFC1123Z в UTC, как её отдаёт Storage.
+// Полный текст статьи, как и в Storage, в списки не попадает.
+func (p *memoryPost) export() rss.Post {
+	post := p.post
+	post.ClusterID = p.cluster()
+	post.FullContent = ""
+	post.PubDate = time.Unix(p.pubTime, 0).UTC().Format(time.RFC1123Z)
+	return post
+}
+
+// GetLastNPosts возвращает последние N публикаций.
+func (m *Memory) GetLastNPosts(ctx context.Context, n int) ([]rss.Post, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var posts []rss.Post
+	for i, p := range m.sorted(nil) {
+		if i >= n {
+			break
+		}
+		posts = append(posts, p.export())
+	}
+	return posts, nil
+}
+
+// GetPostsAfter возвращает до limit публикаций, сохранённых после публикации с ID id,
+// в порядке сохранения.
+func (m *Memory) GetPostsAfter(ctx context.Context, id, limit int) ([]rss.Post, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var posts []rss.Post
+	for next := id + 1; next < m.nextID && len(posts) < limit; next++ {
+		if p, ok := m.posts[next]; ok {
+			posts = append(posts, p.export())
+		}
+	}
+	return posts, nil
+}
+
+// LastPostID возвращает ID последней сохранённой публикации или 0, если публикаций нет.
+func (m *Memory) LastPostID(ctx context.Context) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.nextID - 1, nil
+}
+
+// GetPostByID возвращает публикацию по ID или nil, если её нет.
+func (m *Memory) GetPostByID(ctx context.Context, id int) (*rss.Post, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	p, ok := m.posts[id]
+	if !ok {
+		return nil, nil
+	}
+	post := p.export()
+	post.FullContent = p.post.FullContent
+	return &post, nil
+}
+
+// SearchPosts ищет новости по фильтру с учетом пагинации по смещению или курсору.
+// Поисковый запрос поддерживает тот же синтаксис, что и Storage: слова, фразы в кавычках
+// и исключение через минус, но сравнивает подстроки без учёта регистра вместо морфологии.
+func (m *Memory) SearchPosts(ctx context.Context, filter PostFilter) ([]rss.Post, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	q := parseSearchQuery(filter.Query)
+	ranks := make(map[int]float64)
+	matched := m.sorted(func(post rss.Post) bool {
+		if filter.Source != "" && post.Source != filter.Source {
+			return false
+		}
+		rank, ok := q.match(post)
+		ranks[post.ID] = rank
+		return ok
+	})
+
+	matched = filterByTime(matched, filter.From, filter.To)
+	if filter.Collapse {
+		matched = firstInClusters(matched)
+	}
+	switch filter.SortOrder() {
+	case SortRelevance:
+		// Сортировка устойчивая, поэтому при равной релевантности новые остаются выше
+		sort.SliceStable(matched, func(i, j int) bool { return ranks[matched[i].post.ID] > ranks[matched[j].post.ID] })
+	case SortOldest:
+		for i, j := 0, len(matched)-1; i < j; i, j = i+1, j-1 {
+			matched[i], matched[j] = matched[j], matched[i]
+		}
+	}
+
+	// Границы страницы: по смещению или по курсору
+	start, end := filter.Offset, filter.Offset+filter.Limit
+	if c := filter.Cursor; c != nil {
+		// pos — индекс первой публикации, идущей после курсора в порядке выдачи
+		pos := sort.Search(len(matched), func(i int) bool {
+			p := matched[i]
+			if c.Sort == SortOldest {
+				return p.pubTime > c.PubTime || (p.pubTime == c.PubTime && p.post.ID > c.ID)
+			}
+			return p.pubTime < c.PubTime || (p.pubTime == c.PubTime && p.post.ID < c.ID)
+		})
+		if c.Backward {
+			// Перед курсором стоят публикации, идущие до него, кроме самой граничной
+			before := pos
+			if before > 0 && matched[before-1].post.ID == c.ID {
+				before--
+			}
+			start, end = before-filter.Limit, before
+		} else {
+			start, end = pos, pos+filter.Limit
+		}
+	}
+	if start < 0 {
+		start = 0
+	}
+
+	var posts []rss.Post
+	for i := start; i < len(matched) && i < end; i++ {
+		post := matched[i].export()
+		if !q.empty() {
+			post.Rank = ranks[post.ID]
+			post.Highlight = q.highlight(searchText(post))
+		}
+		if filter.Collapse {
+			post.Alternates = m.alternates(matched[i])
+		}
+		posts = append(posts, post)
+	}
+	return posts, len(matched), nil
+}
+
+// firstInClusters оставляет от каждого кластера самую раннюю публикацию,
+// сохраняя порядок, в котором публикации идут от новых к старым.
+func firstInClusters(posts []*memoryPost) []*memoryPost {
+	first := make(map[int]*memoryPost)
+	for i := len(posts) - 1; i >= 0; i-- {
+		if _, ok := first[posts[i].cluster()]; !ok {
+			first[posts[i].cluster()] = posts[i]
+		}
+	}
+	var result []*memoryPost
+	for _, p := range posts {
+		if first[p.cluster()] == p {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// alternates возвращает остальные публикации кластера от ранних к поздним.
+// Вызывается под блокировкой на чтение.
+func (m *Memory) alternates(p *memoryPost) []rss.Alternate {
+	cluster := m.sorted(func(post rss.Post) bool {
+		return post.ID != p.post.ID && (post.ID == p.cluster() || post.ClusterID == p.cluster())
+	})
+	var alternates []rss.Alternate
+	for i := len(cluster) - 1; i >= 0; i-- {
+		post := cluster[i].post
+		alternates = append(alternates, rss.Alternate{ID: post.ID, Title: post.Title, Link: post.Link, Source: post.Source})
+	}
+	return alternates
+}
+
+// filterByTime оставляет публикации, опубликованные в интервале [from, to].
+// Нулевые границы не ограничивают выборку.
+func filterByTime(posts []*memoryPost, from, to time.Time) []*memoryPost {
+	var result []*memoryPost
+	for _, p := range posts {
+		if !from.IsZero() && p.pubTime < from.Unix() {
+			continue
+		}
+		if !to.IsZero() && p.pubTime > to.Unix() {
+			continue
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+// GetValidators возвращает сохранённые ETag и Last-Modified для ленты.
+func (m *Memory) GetValidators(ctx context.Context, url string) (rss.Validators, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.validators[url], nil
+}
+
+// SaveValidators сохраняет ETag и Last-Modified, полученные при последнем опросе ленты.
+func (m *Memory) SaveValidators(ctx context.Context, url string, v rss.Validators) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.validators[url] = v
+	if _, ok := m.feeds[url]; !ok {
+		m.feeds[url] = FeedStatus{URL: url}
+	}
+	return nil
+}
+
+// SaveFeedStatus сохраняет состояние опроса ленты.
+func (m *Memory) SaveFeedStatus(ctx context.Context, status FeedStatus) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.feeds[status.URL] = status
+	return nil
+}
+
+// GetFeedStatuses возвращает состояние опроса всех известных лент.
+func (m *Memory) GetFeedStatuses(ctx context.Context) ([]FeedStatus, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var statuses []FeedStatus
+	for _, status := range m.feeds {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].URL < statuses[j].URL })
+	return statuses, nil
+}
+
+// sourceID возвращает ID источника с заданным URL или 0, если его нет.
+// Вызывается под блокировкой.
+func (m *Memory) sourceID(url string) int {
+	if url == "" {
+		return 0
+	}
+	for id, source := range m.sources {
+		if source.URL == url {
+			return id
+		}
+	}
+	return 0
+}
+
+// AddSource добавляет источник и привязывает к нему уже сохранённые публикации из этой ленты.
+func (m *Memory) AddSource(ctx context.Context, source Source) (Source, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.sourceID(source.URL) != 0 {
+		return source, ErrSourceExists
+	}
+	source.ID = m.nextSource
+	m.nextSource++
+	m.sources[source.ID] = source
+
+	for _, p := range m.posts {
+		if p.post.Source == source.URL && p.post.SourceID == 0 {
+			p.post.SourceID = source.ID
+		}
+	}
+	return source, nil
+}
+
+// GetSources возвращает все источники, упорядоченные по ID.
+func (m *Memory) GetSources(ctx context.Context) ([]Source, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var sources []Source
+	for _, source := range m.sources {
+		sources = append(sources, source)
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].ID < sources[j].ID })
+	return sources, nil
+}
+
+// GetSource возвращает источник по ID или nil, если его нет.
+func (m *Memory) GetSource(ctx context.Context, id int) (*Source, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	source, ok := m.sources[id]
+	if !ok {
+		return nil, nil
+	}
+	return &source, nil
+}
+
+// UpdateSource сохраняет изменения источника, кроме URL.
+func (m *Memory) UpdateSource(ctx context.Context, source Source) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.sources[source.ID]
+	if !ok {
+		return ErrSourceNotFound
+	}
+	source.URL = stored.URL
+	m.sources[source.ID] = source
+	return nil
+}
+
+// DeleteSource удаляет источник и состояние опроса его ленты, сохраняя публикации.
+func (m *Memory) DeleteSource(ctx context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	source, ok := m.sources[id]
+	if !ok {
+		return ErrSourceNotFound
+	}
+	delete(m.sources, id)
+	delete(m.feeds, source.URL)
+	delete(m.validators, source.URL)
+
+	for _, p := range m.posts {
+		if p.post.SourceID == id {
+			p.post.SourceID = 0
+		}
+	}
+	return nil
+}
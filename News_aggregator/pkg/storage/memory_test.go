@@ -0,0 +1,463 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"Task36a41/pkg/rss"
+)
+
+func TestMemorySaveAndGetPosts(t *testing.T) {
+	m := NewMemory()
+	base := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+
+	posts := []rss.Post{
+		{Title: "Old", Content: "old", PubDate: base.Format(time.RFC1123Z), Link: "http://example.com/old"},
+		{Title: "New", Content: "new", PubDate: base.Add(time.Hour).Format(time.RFC1123Z), Link: "http://example.com/new"},
+		{Title: "Bad", Content: "bad", PubDate: "yesterday", Link: "http://example.com/bad"},
+	}
+	report, err := m.SavePosts(context.Background(), posts)
+	if err != nil {
+		t.Fatalf("Error saving posts: %v", err)
+	}
+	if report.Inserted != 2 || len(report.Skipped) != 1 {
+		t.Errorf("Unexpected report: %s", report)
+	}
+
+	// Повторное сохранение с изменённым заголовком обновляет публикацию
+	posts[0].Title = "Old (updated)"
+	report, err = m.SavePosts(context.Background(), posts[:2])
+	if err != nil {
+		t.Fatalf("Error saving posts: %v", err)
+	}
+	if report.Updated != 1 || report.Unchanged != 1 {
+		t.Errorf("Unexpected report: %s", report)
+	}
+
+	last, err := m.GetLastNPosts(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("Error getting posts: %v", err)
+	}
+	if len(last) != 2 || last[0].Title != "New" || last[1].Title != "Old (updated)" {
+		t.Errorf("Unexpected posts order: %+v", last)
+	}
+
+	post, err := m.GetPostByID(context.Background(), last[1].ID)
+	if err != nil || post == nil || post.Link != "http://example.com/old" {
+		t.Errorf("Unexpected post by ID: %+v, %v", post, err)
+	}
+	if post, _ := m.GetPostByID(context.Background(), 100); post != nil {
+		t.Errorf("Expected nil for missing post, got %+v", post)
+	}
+}
+
+func TestMemoryPostsAfter(t *testing.T) {
+	m := NewMemory()
+	if id, _ := m.LastPostID(context.Background()); id != 0 {
+		t.Errorf("Expected last ID 0 for empty storage, got %d", id)
+	}
+
+	base := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	var posts []rss.Post
+	for i := 1; i <= 3; i++ {
+		posts = append(posts, rss.Post{
+			Title:   fmt.Sprintf("Post %d", i),
+			PubDate: base.Add(-time.Duration(i) * time.Hour).Format(time.RFC1123Z),
+			Link:    fmt.Sprintf("http://example.com/%d", i),
+		})
+	}
+	if _, err := m.SavePosts(context.Background(), posts); err != nil {
+		t.Fatalf("Error saving posts: %v", err)
+	}
+
+	last, _ := m.LastPostID(context.Background())
+	if last != 3 {
+		t.Errorf("Expected last ID 3, got %d", last)
+	}
+
+	// Порядок сохранения, а не даты публикации
+	after, err := m.GetPostsAfter(context.Background(), 1, 10)
+	if err != nil {
+		t.Fatalf("Error getting posts: %v", err)
+	}
+	if len(after) != 2 || after[0].Title != "Post 2" || after[1].Title != "Post 3" {
+		t.Errorf("Unexpected posts after ID 1: %+v", after)
+	}
+	if after, _ := m.GetPostsAfter(context.Background(), 0, 1); len(after) != 1 || after[0].ID != 1 {
+		t.Errorf("Expected limit to apply, got %+v", after)
+	}
+	if after, _ := m.GetPostsAfter(context.Background(), last, 10); len(after) != 0 {
+		t.Errorf("Expected no posts after last ID, got %+v", after)
+	}
+}
+
+func TestMemoryPostMetadata(t *testing.T) {
+	m := NewMemory()
+	date := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC).Format(time.RFC1123Z)
+
+	// Публикация сохранена по ссылке, пока лента не указывала GUID
+	legacy := rss.Post{Title: "Legacy", PubDate: date, Link: "http://example.com/legacy", Source: "http://example.com/rss"}
+	if _, err := m.SavePosts(context.Background(), []rss.Post{legacy}); err != nil {
+		t.Fatalf("Error saving posts: %v", err)
+	}
+
+	post := rss.Post{
+		Title:      "Post",
+		PubDate:    date,
+		Link:       "http://example.com/post?utm_source=rss",
+		Source:     "http://example.com/rss",
+		GUID:       "post-1",
+		Author:     "Gopher",
+		Categories: []string{"go"},
+		Enclosures: []rss.Enclosure{{URL: "http://example.com/post.mp3", Type: "audio/mpeg", Length: 1024}},
+	}
+	legacy.GUID = "legacy-1"
+	report, err := m.SavePosts(context.Background(), []rss.Post{post, legacy})
+	if err != nil {
+		t.Fatalf("Error saving posts: %v", err)
+	}
+	if report.Inserted != 1 || report.Unchanged != 1 {
+		t.Errorf("Unexpected report: %s", report)
+	}
+
+	// Ссылка изменилась, но GUID тот же — публикация обновляется, а не дублируется
+	post.Link = "http://example.com/post"
+	post.Categories = []string{"go", "news"}
+	report, err = m.SavePosts(context.Background(), []rss.Post{post})
+	if err != nil {
+		t.Fatalf("Error saving posts: %v", err)
+	}
+	if report.Updated != 1 {
+		t.Errorf("Unexpected report: %s", report)
+	}
+
+	// Тот же непостоянный GUID в другой ленте — другая публикация
+	other := post
+	other.Source = "http://other.example.com/rss"
+	if report, _ := m.SavePosts(context.Background(), []rss.Post{other}); report.Inserted != 1 {
+		t.Errorf("Expected post from another feed to be inserted: %s", report)
+	}
+
+	last, err := m.GetLastNPosts(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("Error getting posts: %v", err)
+	}
+	if len(last) != 3 {
+		t.Fatalf("Expected 3 posts, got %+v", last)
+	}
+	saved, _ := m.GetPostByID(context.Background(), 2)
+	if saved == nil || saved.Link != post.Link || saved.Author != "Gopher" ||
+		len(saved.Categories) != 2 || len(saved.Enclosures) != 1 || saved.Enclosures[0].Length != 1024 {
+		t.Errorf("Unexpected saved post: %+v", saved)
+	}
+	if saved, _ := m.GetPostByID(context.Background(), 1); saved == nil || saved.GUID != "legacy-1" {
+		t.Errorf("Expected legacy post to get GUID: %+v", saved)
+	}
+}
+
+func TestMemoryClusters(t *testing.T) {
+	m := NewMemory()
+	base := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	story := "Как мы ускорили сборку Go-проекта в три раза. Рассказываем, как кэширование модулей и параллельные тесты помогли сократить время CI."
+
+	_, err := m.SavePosts(context.Background(), []rss.Post{
+		{Title: "Сборка Go-проекта", Content: story + " Читать далее", PubDate: base.Format(time.RFC1123Z),
+			Link: "https://habr.com/ru/articles/1/", Source: "https://habr.com/ru/rss/hub/go/"},
+		{Title: "Другая новость", Content: "Выпущен Go 1.22 с новым синтаксисом циклов и улучшенным планировщиком.",
+			PubDate: base.Add(time.Hour).Format(time.RFC1123Z), Link: "https://example.com/go122"},
+		{Title: "Сборка Go-проекта", Content: story, PubDate: base.Add(2 * time.Hour).Format(time.RFC1123Z),
+			Link: "https://habr.com/ru/articles/1/?utm_source=best", Source: "https://habr.com/ru/rss/best/daily/"},
+		// Тот же текст через неделю — уже другая история
+		{Title: "Сборка Go-проекта", Content: story, PubDate: base.Add(7 * 24 * time.Hour).Format(time.RFC1123Z),
+			Link: "https://example.com/repost"},
+	})
+	if err != nil {
+		t.Fatalf("Error saving posts: %v", err)
+	}
+
+	posts, _ := m.GetLastNPosts(context.Background(), 10)
+	clusters := make(map[string]int)
+	for _, post := range posts {
+		clusters[post.Link] = post.ClusterID
+	}
+	if clusters["https://habr.com/ru/articles/1/?utm_source=best"] != 1 || clusters["https://habr.com/ru/articles/1/"] != 1 {
+		t.Errorf("Expected habr posts to share cluster 1: %v", clusters)
+	}
+	if clusters["https://example.com/go122"] != 2 || clusters["https://example.com/repost"] != 4 {
+		t.Errorf("Expected other posts to have own clusters: %v", clusters)
+	}
+
+	// Свёрнутая выдача: одна публикация на кластер, остальные — в Alternates
+	results, total, err := m.SearchPosts(context.Background(), PostFilter{Limit: 10, Collapse: true})
+	if err != nil {
+		t.Fatalf("Error searching posts: %v", err)
+	}
+	if total != 3 || len(results) != 3 {
+		t.Fatalf("Expected 3 collapsed posts, got total=%d %+v", total, results)
+	}
+	habr := results[2]
+	if habr.ID != 1 || len(habr.Alternates) != 1 || habr.Alternates[0].ID != 3 ||
+		habr.Alternates[0].Source != "https://habr.com/ru/rss/best/daily/" {
+		t.Errorf("Unexpected representative: %+v", habr)
+	}
+
+	// Если первая публикация кластера не подходит под фильтр, кластер представляет следующая
+	results, _, _ = m.SearchPosts(context.Background(), PostFilter{Limit: 10, Collapse: true, Source: "https://habr.com/ru/rss/best/daily/"})
+	if len(results) != 1 || results[0].ID != 3 || len(results[0].Alternates) != 1 || results[0].Alternates[0].ID != 1 {
+		t.Errorf("Unexpected filtered collapsed posts: %+v", results)
+	}
+}
+
+func TestMemoryClusterWindow(t *testing.T) {
+	m := NewMemory()
+	base := time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC)
+	story := "Как мы ускорили сборку Go-проекта в три раза. Рассказываем, как кэширование модулей и параллельные тесты помогли сократить время CI."
+
+	// Публикации сохраняются не по порядку времени; похожие ищутся только
+	// в пределах clusterWindow включительно
+	save := func(link string, pubTime time.Time) {
+		t.Helper()
+		if _, err := m.SavePosts(context.Background(), []rss.Post{{Title: "Сборка Go-проекта", Content: story, PubDate: pubTime.Format(time.RFC1123Z), Link: link}}); err != nil {
+			t.Fatalf("Error saving posts: %v", err)
+		}
+	}
+	save("https://example.com/late", base.Add(clusterWindow+time.Second))
+	save("https://example.com/base", base)
+	save("https://example.com/edge", base.Add(-clusterWindow))
+	save("https://example.com/early", base.Add(-2*clusterWindow-time.Second))
+
+	posts, _ := m.GetLastNPosts(context.Background(), 10)
+	var links []string
+	clusters := make(map[string]int)
+	for _, post := range posts {
+		links = append(links, post.Link)
+		clusters[post.Link] = post.ClusterID
+	}
+	if got := strings.Join(links, " "); got != "https://example.com/late https://example.com/base https://example.com/edge https://example.com/early" {
+		t.Errorf("Expected posts from newest to oldest, got %s", got)
+	}
+	want := map[string]int{
+		"https://example.com/late":  1,
+		"https://example.com/base":  2,
+		"https://example.com/edge":  2,
+		"https://example.com/early": 4,
+	}
+	for link, cluster := range want {
+		if clusters[link] != cluster {
+			t.Errorf("%s: expected cluster %d, got %d", link, cluster, clusters[link])
+		}
+	}
+}
+
+func TestDedupKey(t *testing.T) {
+	tests := []struct {
+		post rss.Post
+		want string
+	}{
+		{rss.Post{Link: "http://example.com/a", Source: "http://example.com/rss"}, "http://example.com/a"},
+		{rss.Post{Link: "http://example.com/a", GUID: "http://example.com/?p=1"}, "http://example.com/?p=1"},
+		{rss.Post{Link: "http://example.com/a", GUID: "42", Source: "http://example.com/rss"}, "http://example.com/rss 42"},
+		{rss.Post{Link: "http://example.com/a", GUID: "urn:uuid:1", Source: "http://example.com/rss"}, "http://example.com/rss urn:uuid:1"},
+	}
+	for _, tt := range tests {
+		if got := dedupKey(tt.post); got != tt.want {
+			t.Errorf("dedupKey(%+v) = %q, want %q", tt.post, got, tt.want)
+		}
+	}
+}
+
+func TestMemorySearchPosts(t *testing.T) {
+	m := NewMemory()
+	now := time.Now()
+	_, err := m.SavePosts(context.Background(), []rss.Post{
+		{Title: "Go Programming Language", Content: "Learn Go", PubDate: now.Add(-3 * time.Hour).Format(time.RFC1123Z), Link: "http://example.com/go"},
+		{Title: "Learning Python", Content: "Python basics, not go", PubDate: now.Add(-2 * time.Hour).Format(time.RFC1123Z), Link: "http://example.com/python"},
+		{Title: "Advanced concepts", Content: "Deep dive into GO generics", PubDate: now.Add(-time.Hour).Format(time.RFC1123Z), Link: "http://example.com/advanced-go"},
+		{Title: "Weekly digest", Content: "Nothing here", PubDate: now.Format(time.RFC1123Z), Link: "http://example.com/digest"},
+	})
+	if err != nil {
+		t.Fatalf("Error saving posts: %v", err)
+	}
+	ctx := context.Background()
+
+	// Совпадение в заголовке ранжируется выше, чем в содержании
+	results, total, err := m.SearchPosts(ctx, PostFilter{Query: "go", Limit: 10})
+	if err != nil {
+		t.Fatalf("Error searching posts: %v", err)
+	}
+	if total != 3 || results[0].Title != "Go Programming Language" {
+		t.Errorf("Unexpected results: total=%d %+v", total, results)
+	}
+	if results[0].Rank <= results[1].Rank {
+		t.Errorf("Expected results ordered by rank, got %v then %v", results[0].Rank, results[1].Rank)
+	}
+	if results[0].Highlight != "Learn <mark>Go</mark>" {
+		t.Errorf("Expected highlight with <mark>, got %q", results[0].Highlight)
+	}
+
+	// Исключение через минус
+	results, total, _ = m.SearchPosts(ctx, PostFilter{Query: "go -python", Limit: 10})
+	if total != 2 {
+		t.Errorf("Expected 2 results without python, got %d: %+v", total, results)
+	}
+
+	// Фраза в кавычках
+	results, total, _ = m.SearchPosts(ctx, PostFilter{Query: `"go generics"`, Limit: 10})
+	if total != 1 || results[0].Link != "http://example.com/advanced-go" {
+		t.Errorf("Unexpected phrase results: %+v", results)
+	}
+
+	// Пустой запрос возвращает все новости от новых к старым, с пагинацией
+	results, total, _ = m.SearchPosts(ctx, PostFilter{Query: "", Limit: 2})
+	if total != 4 || len(results) != 2 || results[0].Title != "Weekly digest" || results[0].Highlight != "" {
+		t.Errorf("Unexpected results for empty query: total=%d %+v", total, results)
+	}
+}
+
+func TestMemorySearchPostsFilters(t *testing.T) {
+	m := NewMemory()
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	var posts []rss.Post
+	for i := 0; i < 6; i++ {
+		source := "http://a.example/rss"
+		if i%2 == 1 {
+			source = "http://b.example/rss"
+		}
+		posts = append(posts, rss.Post{
+			Title:   fmt.Sprintf("Post %d", i),
+			PubDate: base.AddDate(0, 0, i).Format(time.RFC1123Z),
+			Link:    fmt.Sprintf("http://example.com/%d", i),
+			Source:  source,
+		})
+	}
+	if _, err := m.SavePosts(context.Background(), posts); err != nil {
+		t.Fatalf("Error saving posts: %v", err)
+	}
+	ctx := context.Background()
+
+	results, total, _ := m.SearchPosts(ctx, PostFilter{Source: "http://b.example/rss", Limit: 10})
+	if total != 3 || results[0].Title != "Post 5" {
+		t.Errorf("Unexpected results for source filter: total=%d %+v", total, results)
+	}
+
+	results, total, _ = m.SearchPosts(ctx, PostFilter{
+		From:  base.AddDate(0, 0, 1),
+		To:    base.AddDate(0, 0, 3),
+		Sort:  SortOldest,
+		Limit: 10,
+	})
+	if total != 3 || results[0].Title != "Post 1" || results[2].Title != "Post 3" {
+		t.Errorf("Unexpected results for date range: total=%d %+v", total, results)
+	}
+}
+
+func TestMemorySearchPostsCursor(t *testing.T) {
+	m := NewMemory()
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	var posts []rss.Post
+	for i := 0; i < 6; i++ {
+		posts = append(posts, rss.Post{
+			Title:   fmt.Sprintf("Post %d", i),
+			PubDate: base.AddDate(0, 0, i).Format(time.RFC1123Z),
+			Link:    fmt.Sprintf("http://example.com/%d", i),
+		})
+	}
+	if _, err := m.SavePosts(context.Background(), posts); err != nil {
+		t.Fatalf("Error saving posts: %v", err)
+	}
+	ctx := context.Background()
+
+	// Курсор строится по сохранённым публикациям, у которых уже есть ID
+	posts, _, _ = m.SearchPosts(ctx, PostFilter{Sort: SortOldest, Limit: 10})
+	encoded := CursorAfter(posts[3], SortNewest)
+	cursor, err := DecodeCursor(encoded)
+	if err != nil {
+		t.Fatalf("Error decoding cursor %q: %v", encoded, err)
+	}
+	results, total, _ := m.SearchPosts(ctx, PostFilter{Cursor: cursor, Limit: 2})
+	if total != 6 || len(results) != 2 || results[0].Title != "Post 2" || results[1].Title != "Post 1" {
+		t.Errorf("Unexpected results after cursor: total=%d %+v", total, results)
+	}
+
+	// Назад от Post 2 в порядке newest: ближайшие более новые, в том же порядке вывода
+	cursor, _ = DecodeCursor(CursorBefore(posts[2], SortNewest))
+	results, _, _ = m.SearchPosts(ctx, PostFilter{Cursor: cursor, Limit: 2})
+	if len(results) != 2 || results[0].Title != "Post 4" || results[1].Title != "Post 3" {
+		t.Errorf("Unexpected results before cursor: %+v", results)
+	}
+
+	cursor, _ = DecodeCursor(CursorAfter(posts[3], SortOldest))
+	results, _, _ = m.SearchPosts(ctx, PostFilter{Cursor: cursor, Limit: 10})
+	if len(results) != 2 || results[0].Title != "Post 4" {
+		t.Errorf("Unexpected results after oldest cursor: %+v", results)
+	}
+
+	for _, value := range []string{"garbage", "e30"} {
+		if _, err := DecodeCursor(value); err == nil {
+			t.Errorf("Expected error decoding cursor %q", value)
+		}
+	}
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	q := parseSearchQuery(`Go  "Error  Handling" -python -"old news"`)
+	if strings.Join(q.include, "|") != "go|error handling" {
+		t.Errorf("Unexpected include terms: %q", q.include)
+	}
+	if strings.Join(q.exclude, "|") != "python|old news" {
+		t.Errorf("Unexpected exclude terms: %q", q.exclude)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	q := parseSearchQuery("горутины")
+	got := q.highlight("Планировщик запускает Горутины по очереди")
+	want := "Планировщик запускает <mark>Горутины</mark> по очереди"
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	// Разметка из текста публикации экранируется, подсвечивается только совпадение
+	q = parseSearchQuery("script")
+	got = q.highlight(`Пример <script>alert("x")</script> в тексте`)
+	want = `Пример &lt;<mark>script</mark>&gt;alert(&#34;x&#34;)&lt;/<mark>script</mark>&gt; в тексте`
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestMemoryConcurrentAccess(t *testing.T) {
+	m := NewMemory()
+	now := time.Now().Format(time.RFC1123Z)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			link := fmt.Sprintf("http://example.com/%d", i)
+			m.SavePosts(context.Background(), []rss.Post{{Title: link, PubDate: now, Link: link}})
+			m.SaveValidators(context.Background(), link, rss.Validators{ETag: link})
+		}(i)
+		go func() {
+			defer wg.Done()
+			m.GetLastNPosts(context.Background(), 5)
+			m.SearchPosts(context.Background(), PostFilter{Query: "example", Limit: 5})
+			m.GetFeedStatuses(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	posts, _ := m.GetLastNPosts(context.Background(), 100)
+	if len(posts) != 10 {
+		t.Errorf("Expected 10 posts, got %d", len(posts))
+	}
+	feeds, _ := m.GetFeedStatuses(context.Background())
+	if len(feeds) != 10 {
+		t.Errorf("Expected 10 feeds, got %d", len(feeds))
+	}
+}
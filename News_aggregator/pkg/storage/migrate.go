@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"database/sql"
+	"embed"
+
+	"Task36a41/pkg/migrate"
+)
+
+// Миграции схемы встроены в бинарный файл и применяются пакетом migrate.
+// schema.sql разворачивает те же миграции вручную и записывает их версии
+// в schema_migrations, поэтому сервер не применяет их повторно.
+//
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// migrationLockID — ключ advisory-блокировки, чтобы несколько экземпляров
+// сервиса не применяли миграции одновременно.
+const migrationLockID = 36041
+
+// loadMigrations читает встроенные миграции и сортирует их по версии.
+func loadMigrations() ([]migrate.Migration, error) {
+	return migrate.Load(migrationsFS, "migrations")
+}
+
+// applyMigrations применяет к базе данных ещё не применённые миграции.
+func applyMigrations(db *sql.DB) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+	return migrate.Apply(db, migrations, migrationLockID)
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("Error loading migrations: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("Expected at least one migration")
+	}
+
+	// Версии должны идти подряд начиная с 1
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("Expected migration version %d, got %d (%s)", i+1, m.Version, m.Name)
+		}
+		if strings.TrimSpace(m.SQL) == "" {
+			t.Errorf("Migration %s is empty", m.Name)
+		}
+	}
+}
+
+func TestSchemaIncludesMigrations(t *testing.T) {
+	schema, err := os.ReadFile("../../schema.sql")
+	if err != nil {
+		t.Fatalf("Error reading schema.sql: %v", err)
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("Error loading migrations: %v", err)
+	}
+	// Каждая миграция подключается и записывается в schema_migrations,
+	// иначе сервер применил бы её повторно к базе, развёрнутой из schema.sql
+	for _, m := range migrations {
+		if !strings.Contains(string(schema), `\ir pkg/storage/migrations/`+m.Name) {
+			t.Errorf("schema.sql does not include migration %s", m.Name)
+		}
+		record := fmt.Sprintf("VALUES (%d, '%s');", m.Version, m.Name)
+		if !strings.Contains(string(schema), record) {
+			t.Errorf("schema.sql does not record migration %s", m.Name)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	// Используем строку подключения к тестовой базе данных
+	db, err := New("user=postgres password=vlad5043 dbname=News sslmode=disable")
+	if err != nil {
+		t.Fatalf("Error connecting to database: %v", err)
+	}
+	defer db.Close()
+
+	// Повторный запуск миграций ничего не меняет
+	if err := applyMigrations(db.db); err != nil {
+		t.Fatalf("Error re-running migrations: %v", err)
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("Error loading migrations: %v", err)
+	}
+	var count int
+	if err := db.db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&count); err != nil {
+		t.Fatalf("Error counting applied migrations: %v", err)
+	}
+	if count != len(migrations) {
+		t.Errorf("Expected %d applied migrations, got %d", len(migrations), count)
+	}
+}
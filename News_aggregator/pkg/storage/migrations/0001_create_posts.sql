@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS posts (
+    id SERIAL PRIMARY KEY,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    pub_time BIGINT DEFAULT 0,
+    link TEXT NOT NULL UNIQUE
+);
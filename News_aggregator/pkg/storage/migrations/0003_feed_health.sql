@@ -0,0 +1,7 @@
+ALTER TABLE feeds
+    ADD COLUMN IF NOT EXISTS last_success_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS last_error TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS last_error_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS consecutive_failures INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS item_count INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS next_poll_at TIMESTAMPTZ;
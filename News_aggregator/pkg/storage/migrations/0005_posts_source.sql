@@ -0,0 +1,5 @@
+-- Лента, из которой получена публикация, для фильтрации /news по источнику.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS posts_source_pub_time_idx ON posts (source, pub_time DESC);
+CREATE INDEX IF NOT EXISTS posts_pub_time_idx ON posts (pub_time DESC);
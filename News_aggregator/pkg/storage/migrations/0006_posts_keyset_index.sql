@@ -0,0 +1,2 @@
+-- Индекс для постраничной выборки по курсору (pub_time, id).
+CREATE INDEX IF NOT EXISTS posts_pub_time_id_idx ON posts (pub_time DESC, id DESC);
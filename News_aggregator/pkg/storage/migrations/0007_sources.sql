@@ -0,0 +1,14 @@
+-- Источники публикаций, которыми можно управлять через /admin/sources без перезапуска.
+CREATE TABLE IF NOT EXISTS sources (
+    id SERIAL PRIMARY KEY,
+    url TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    poll_interval INT NOT NULL DEFAULT 0, -- Интервал опроса в минутах, 0 — общий request_period
+    category TEXT NOT NULL DEFAULT ''
+);
+
+-- Источник публикации. Сохраняется при удалении источника вместе с URL в posts.source.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS source_id INT REFERENCES sources (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS posts_source_id_idx ON posts (source_id);
@@ -0,0 +1,15 @@
+-- Метаданные записи ленты: постоянный идентификатор, автор, категории и вложения.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS guid TEXT NOT NULL DEFAULT '';
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS author TEXT NOT NULL DEFAULT '';
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS categories TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS enclosures JSONB NOT NULL DEFAULT '[]';
+
+-- Ключ дедупликации: GUID записи, если лента его указывает, иначе ссылка.
+-- Ссылка у записи может меняться, поэтому уникальной она больше не является.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS dedup_key TEXT;
+UPDATE posts SET dedup_key = link WHERE dedup_key IS NULL;
+ALTER TABLE posts ALTER COLUMN dedup_key SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS posts_dedup_key_idx ON posts (dedup_key);
+
+ALTER TABLE posts DROP CONSTRAINT IF EXISTS posts_link_key;
+CREATE INDEX IF NOT EXISTS posts_link_idx ON posts (link);
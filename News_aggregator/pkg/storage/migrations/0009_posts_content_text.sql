@@ -0,0 +1,19 @@
+-- Текст содержания без разметки: по нему строятся поиск и превью.
+-- Для уже сохранённых публикаций теги вырезаются приблизительно, новые
+-- публикации получают текст из очищенного HTML при сохранении.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS content_text TEXT NOT NULL DEFAULT '';
+UPDATE posts SET content_text = btrim(regexp_replace(regexp_replace(content, '<[^>]*>', ' ', 'g'), '\s+', ' ', 'g'))
+WHERE content_text = '';
+
+-- Поисковый вектор пересоздаётся по тексту вместо HTML, чтобы разметка не попадала в индекс.
+DROP INDEX IF EXISTS posts_search_vector_idx;
+ALTER TABLE posts DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE posts ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('russian', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('russian', coalesce(nullif(content_text, ''), content, '')), 'B') ||
+        setweight(to_tsvector('english', coalesce(nullif(content_text, ''), content, '')), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS posts_search_vector_idx ON posts USING GIN (search_vector);
@@ -0,0 +1,4 @@
+-- Режим полного текста: для источников с урезанными лентами статья
+-- загружается со страницы публикации и сохраняется в posts.full_content.
+ALTER TABLE sources ADD COLUMN IF NOT EXISTS full_text BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS full_content TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,8 @@
+-- Кластеры почти одинаковых публикаций из разных лент.
+-- simhash — отпечаток заголовка и текста, cluster_id — первая публикация кластера
+-- (NULL у неё самой). Публикации, сохранённые до миграции, получают отпечаток
+-- при следующем опросе ленты, но в кластеры попадают только новые публикации.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS simhash BIGINT;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS cluster_id INT REFERENCES posts (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS posts_cluster_id_idx ON posts (cluster_id);
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"html"
+	"strings"
+	"unicode"
+
+	"Task36a41/pkg/rss"
+)
+
+// highlightRadius — сколько символов вокруг первого совпадения попадает во фрагмент.
+const highlightRadius = 80
+
+// Маркеры начала и конца совпадения во фрагменте. Фрагмент сначала размечается ими
+// и экранируется, а уже затем маркеры заменяются тегами <mark>: так разметка
+// из текста публикации попадает в выдачу как текст, а не как HTML.
+const (
+	highlightStart = "\uE000"
+	highlightStop  = "\uE001"
+)
+
+// highlightMarks заменяет маркеры совпадений тегами <mark>.
+var highlightMarks = strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>")
+
+// markHighlight экранирует фрагмент и заменяет маркеры совпадений тегами <mark>.
+func markHighlight(fragment string) string {
+	return highlightMarks.Replace(html.EscapeString(fragment))
+}
+
+// searchQuery — поисковый запрос, разобранный по правилам websearch_to_tsquery:
+// слова и фразы в кавычках должны встречаться, а слова и фразы с минусом — нет.
+// Используется хранилищем в памяти.
+type searchQuery struct {
+	include []string
+	exclude []string
+}
+
+// parseSearchQuery разбирает строку запроса. Все термины приводятся к нижнему регистру.
+func parseSearchQuery(query string) searchQuery {
+	var q searchQuery
+	runes := []rune(strings.ToLower(query))
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		negate := false
+		if runes[i] == '-' {
+			negate = true
+			i++
+		}
+
+		var term string
+		if i < len(runes) && runes[i] == '"' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			term = strings.Join(strings.Fields(string(runes[i+1:end])), " ")
+			i = end + 1
+		} else {
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) {
+				end++
+			}
+			term = string(runes[i:end])
+			i = end
+		}
+
+		if term == "" {
+			continue
+		}
+		if negate {
+			q.exclude = append(q.exclude, term)
+		} else {
+			q.include = append(q.include, term)
+		}
+	}
+	return q
+}
+
+// empty сообщает, что запрос не содержит искомых терминов.
+func (q searchQuery) empty() bool {
+	return len(q.include) == 0
+}
+
+// match проверяет, подходит ли публикация под запрос, и возвращает её релевантность.
+// Совпадения в заголовке весят больше, чем в содержании.
+func (q searchQuery) match(post rss.Post) (float64, bool) {
+	title := strings.ToLower(post.Title)
+	content := strings.ToLower(searchText(post))
+
+	for _, term := range q.exclude {
+		if strings.Contains(title, term) || strings.Contains(content, term) {
+			return 0, false
+		}
+	}
+
+	var rank float64
+	for _, term := range q.include {
+		titleHits := strings.Count(title, term)
+		contentHits := strings.Count(content, term)
+		if titleHits == 0 && contentHits == 0 {
+			return 0, false
+		}
+		rank += float64(titleHits) + 0.4*float64(contentHits)
+	}
+	return rank, true
+}
+
+// searchText возвращает текст публикации, по которому идёт поиск: текст без разметки,
+// а для публикаций, сохранённых без него, — исходное содержание.
+func searchText(post rss.Post) string {
+	if post.ContentText != "" {
+		return post.ContentText
+	}
+	return post.Content
+}
+
+// highlight возвращает экранированный фрагмент текста вокруг первого совпадения,
+// в котором все совпадения обёрнуты в <mark>, как в результатах SearchPosts.
+func (q searchQuery) highlight(text string) string {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	if len(lower) != len(runes) {
+		// Регистр изменил длину строки — подсветка по позициям невозможна
+		return ""
+	}
+
+	// Находим первое совпадение любого из терминов
+	first := -1
+	for _, term := range q.include {
+		if pos := runeIndex(lower, []rune(term)); pos >= 0 && (first < 0 || pos < first) {
+			first = pos
+		}
+	}
+	if first < 0 {
+		return ""
+	}
+
+	start := first - highlightRadius
+	if start < 0 {
+		start = 0
+	}
+	end := first + highlightRadius
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	var b strings.Builder
+	for i := start; i < end; {
+		if length := q.matchAt(lower, i); length > 0 && i+length <= end {
+			b.WriteString(highlightStart)
+			b.WriteString(string(runes[i : i+length]))
+			b.WriteString(highlightStop)
+			i += length
+			continue
+		}
+		b.WriteRune(runes[i])
+		i++
+	}
+	return markHighlight(strings.TrimSpace(b.String()))
+}
+
+// matchAt возвращает длину термина, совпадающего с текстом в позиции pos, или 0.
+func (q searchQuery) matchAt(text []rune, pos int) int {
+	for _, term := range q.include {
+		t := []rune(term)
+		if pos+len(t) <= len(text) && string(text[pos:pos+len(t)]) == term {
+			return len(t)
+		}
+	}
+	return 0
+}
+
+// runeIndex ищет подстроку sub в text и возвращает её позицию в рунах или -1.
+func runeIndex(text, sub []rune) int {
+	for i := 0; i+len(sub) <= len(text); i++ {
+		if string(text[i:i+len(sub)]) == string(sub) {
+			return i
+		}
+	}
+	return -1
+}
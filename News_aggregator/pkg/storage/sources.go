@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/lib/pq"
+)
+
+// Ошибки операций с источниками.
+var (
+	ErrSourceNotFound = errors.New("source not found")
+	ErrSourceExists   = errors.New("source with this url already exists")
+)
+
+// Source описывает ленту-источник публикаций.
+type Source struct {
+	ID           int    `json:"id"`            // Идентификатор источника
+	URL          string `json:"url"`           // Адрес ленты
+	Name         string `json:"name"`          // Отображаемое название
+	Enabled      bool   `json:"enabled"`       // Опрашивается ли лента
+	PollInterval int    `json:"poll_interval"` // Интервал опроса в минутах, 0 — общий интервал
+	Category     string `json:"category"`      // Категория для группировки источников
+	FullText     bool   `json:"full_text"`     // Загружать полный текст статей со страниц публикаций
+}
+
+// ValidateSourceURL проверяет, что адрес ленты — абсолютный URL с протоколом http или https.
+func ValidateSourceURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https URL")
+	}
+	return nil
+}
+
+// SourceStore описывает хранилище источников.
+type SourceStore interface {
+	AddSource(ctx context.Context, source Source) (Source, error)
+	GetSources(ctx context.Context) ([]Source, error)
+	GetSource(ctx context.Context, id int) (*Source, error)
+	UpdateSource(ctx context.Context, source Source) error
+	DeleteSource(ctx context.Context, id int) error
+}
+
+// uniqueViolation — код ошибки PostgreSQL при нарушении ограничения уникальности.
+const uniqueViolation = "23505"
+
+// AddSource добавляет источник и возвращает его с присвоенным ID.
+// Уже сохранённые публикации из этой ленты привязываются к новому источнику.
+// Если источник с таким URL уже есть, возвращается ErrSourceExists.
+func (s *Storage) AddSource(ctx context.Context, source Source) (Source, error) {
+	defer observeQuery(ctx, "add_source")()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return source, fmt.Errorf("could not begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO sources (url, name, enabled, poll_interval, category, full_text)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+	err = tx.QueryRowContext(ctx, query, source.URL, source.Name, source.Enabled, source.PollInterval, source.Category, source.FullText).Scan(&source.ID)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return source, ErrSourceExists
+		}
+		return source, fmt.Errorf("could not add source: %v", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE posts SET source_id = $1 WHERE source = $2 AND source_id IS NULL`, source.ID, source.URL); err != nil {
+		return source, fmt.Errorf("could not link posts to source: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return source, fmt.Errorf("could not commit transaction: %v", err)
+	}
+	return source, nil
+}
+
+// GetSources возвращает все источники, упорядоченные по ID.
+func (s *Storage) GetSources(ctx context.Context) ([]Source, error) {
+	defer observeQuery(ctx, "get_sources")()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, url, name, enabled, poll_interval, category, full_text FROM sources ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("could not get sources: %v", err)
+	}
+	defer rows.Close()
+
+	var sources []Source
+	for rows.Next() {
+		var source Source
+		if err := rows.Scan(&source.ID, &source.URL, &source.Name, &source.Enabled, &source.PollInterval, &source.Category, &source.FullText); err != nil {
+			return nil, fmt.Errorf("could not scan source: %v", err)
+		}
+		sources = append(sources, source)
+	}
+	return sources, rows.Err()
+}
+
+// GetSource возвращает источник по ID или nil, если его нет.
+func (s *Storage) GetSource(ctx context.Context, id int) (*Source, error) {
+	defer observeQuery(ctx, "get_source")()
+
+	query := `SELECT id, url, name, enabled, poll_interval, category, full_text FROM sources WHERE id = $1`
+
+	var source Source
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&source.ID, &source.URL, &source.Name, &source.Enabled, &source.PollInterval, &source.Category, &source.FullText)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not get source: %v", err)
+	}
+	return &source, nil
+}
+
+// UpdateSource сохраняет название, признак опроса, интервал, категорию и режим полного текста источника.
+// URL источника не меняется. Если источника нет, возвращается ErrSourceNotFound.
+func (s *Storage) UpdateSource(ctx context.Context, source Source) error {
+	defer observeQuery(ctx, "update_source")()
+
+	query := `
+		UPDATE sources
+		SET name = $2, enabled = $3, poll_interval = $4, category = $5, full_text = $6
+		WHERE id = $1`
+	result, err := s.db.ExecContext(ctx, query, source.ID, source.Name, source.Enabled, source.PollInterval, source.Category, source.FullText)
+	if err != nil {
+		return fmt.Errorf("could not update source: %v", err)
+	}
+	return checkAffected(result)
+}
+
+// DeleteSource удаляет источник и состояние опроса его ленты.
+// Собранные публикации остаются, но теряют привязку к источнику.
+func (s *Storage) DeleteSource(ctx context.Context, id int) error {
+	defer observeQuery(ctx, "delete_source")()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var feedURL string
+	err = tx.QueryRowContext(ctx, `DELETE FROM sources WHERE id = $1 RETURNING url`, id).Scan(&feedURL)
+	if err == sql.ErrNoRows {
+		return ErrSourceNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("could not delete source: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM feeds WHERE url = $1`, feedURL); err != nil {
+		return fmt.Errorf("could not delete feed status: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %v", err)
+	}
+	return nil
+}
+
+// checkAffected возвращает ErrSourceNotFound, если запрос не затронул ни одной строки.
+func checkAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not get affected rows: %v", err)
+	}
+	if n == 0 {
+		return ErrSourceNotFound
+	}
+	return nil
+}
@@ -3,38 +3,114 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
+	"Task36a41/pkg/metrics"
 	"Task36a41/pkg/rss"
+	"Task36a41/pkg/simhash"
+	"Task36a41/pkg/tracing"
 
-	_ "github.com/lib/pq" // PostgreSQL драйвер
+	"github.com/lib/pq" // PostgreSQL драйвер
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// Storage представляет структуру для работы с БД.
-type Storage struct {
-	db *sql.DB
+// PostStore описывает хранилище публикаций.
+type PostStore interface {
+	SavePosts(ctx context.Context, posts []rss.Post) (SaveReport, error)
+	GetLastNPosts(ctx context.Context, n int) ([]rss.Post, error)
+	GetPostsAfter(ctx context.Context, id, limit int) ([]rss.Post, error)
+	LastPostID(ctx context.Context) (int, error)
+	GetPostByID(ctx context.Context, id int) (*rss.Post, error)
+	SearchPosts(ctx context.Context, filter PostFilter) ([]rss.Post, int, error)
 }
 
-// MockStorage - имитация хранилища для тестирования
-type MockStorage struct {
-	posts []rss.Post
-}
+// Порядок сортировки результатов поиска.
+const (
+	SortNewest    = "newest"    // Сначала новые
+	SortOldest    = "oldest"    // Сначала старые
+	SortRelevance = "relevance" // Сначала наиболее релевантные запросу
+)
 
-// NewMockStorage создает новый экземпляр MockStorage с заданными постами
-func NewMockStorage(posts []rss.Post) *MockStorage {
-	return &MockStorage{posts: posts}
+// PostFilter задаёт параметры выборки публикаций.
+type PostFilter struct {
+	Query  string    // Поисковый запрос по заголовку и содержанию
+	Source string    // URL ленты-источника
+	From   time.Time // Опубликованы не раньше (нулевое значение — без ограничения)
+	To     time.Time // Опубликованы не позже (нулевое значение — без ограничения)
+	Sort   string    // SortNewest, SortOldest или SortRelevance
+	Limit  int       // Количество публикаций на странице
+	Offset int       // Смещение от начала выборки, не используется вместе с Cursor
+	Cursor *Cursor   // Позиция, от которой выбирается страница; задаёт и порядок сортировки
+
+	// Collapse оставляет от каждого кластера почти одинаковых публикаций одну —
+	// самую раннюю из подходящих под фильтр, а остальные прикладывает к ней в Alternates.
+	Collapse bool
 }
 
-// GetLastNPosts возвращает последние N публикаций из имитационного хранилища
-func (m *MockStorage) GetLastNPosts(n int) ([]rss.Post, error) {
-	if n > len(m.posts) {
-		n = len(m.posts)
+// SortOrder возвращает порядок сортировки: заданный курсором или с учётом значения по умолчанию:
+// по релевантности для поискового запроса, иначе сначала новые.
+// Сортировка по релевантности без запроса не имеет смысла и заменяется на новые.
+func (f PostFilter) SortOrder() string {
+	switch {
+	case f.Cursor != nil:
+		return f.Cursor.Sort
+	case f.Sort == SortOldest:
+		return SortOldest
+	case f.Sort == SortNewest:
+		return SortNewest
+	case strings.TrimSpace(f.Query) != "":
+		return SortRelevance
+	default:
+		return SortNewest
 	}
-	return m.posts[:n], nil
 }
 
-// New создает новое подключение к базе данных и сбрасывает таблицу
+// FeedStore описывает хранилище состояния опроса лент.
+type FeedStore interface {
+	rss.ValidatorStore
+	SaveFeedStatus(ctx context.Context, status FeedStatus) error
+	GetFeedStatuses(ctx context.Context) ([]FeedStatus, error)
+}
+
+// Interface — хранилище, которое использует приложение. Его реализуют
+// Storage (PostgreSQL) и Memory (в памяти, для тестов и демонстрации).
+type Interface interface {
+	PostStore
+	FeedStore
+	SourceStore
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// Проверяем на этапе компиляции, что обе реализации соответствуют Interface.
+var (
+	_ Interface = (*Storage)(nil)
+	_ Interface = (*Memory)(nil)
+)
+
+// Storage представляет структуру для работы с БД.
+type Storage struct {
+	db *sql.DB
+}
+
+// FeedStatus описывает состояние опроса ленты.
+type FeedStatus struct {
+	URL                 string     `json:"url"`                  // Адрес ленты
+	LastSuccessAt       *time.Time `json:"last_success_at"`      // Время последнего успешного опроса
+	LastError           string     `json:"last_error"`           // Текст последней ошибки
+	LastErrorAt         *time.Time `json:"last_error_at"`        // Время последней ошибки
+	ConsecutiveFailures int        `json:"consecutive_failures"` // Количество ошибок подряд
+	ItemCount           int        `json:"item_count"`           // Количество публикаций при последнем опросе
+	NextPollAt          *time.Time `json:"next_poll_at"`         // Время следующего опроса
+}
+
+// New создает новое подключение к базе данных и применяет миграции схемы.
+// Сохранённые публикации при перезапуске не теряются.
 func New(connectionString string) (*Storage, error) {
 	db, err := sql.Open("postgres", connectionString)
 	if err != nil {
@@ -46,37 +122,18 @@ func New(connectionString string) (*Storage, error) {
 		return nil, fmt.Errorf("could not ping database: %v", err)
 	}
 
-	// Создаем и сбрасываем таблицу
-	if err := resetTable(db); err != nil {
-		return nil, fmt.Errorf("could not reset table: %v", err)
+	// Применяем недостающие миграции
+	if err := applyMigrations(db); err != nil {
+		return nil, fmt.Errorf("could not migrate database: %v", err)
 	}
 
 	return &Storage{db: db}, nil
 }
 
-// resetTable сбрасывает таблицу и создаёт её заново
-func resetTable(db *sql.DB) error {
-	// Удаляем таблицу, если она существует
-	_, err := db.Exec(`DROP TABLE IF EXISTS posts;`)
-	if err != nil {
-		return fmt.Errorf("could not drop table: %v", err)
-	}
-
-	// Создаем таблицу заново
-	_, err = db.Exec(`
-		CREATE TABLE posts (
-			id SERIAL PRIMARY KEY,
-			title TEXT NOT NULL,
-			content TEXT NOT NULL,
-			pub_time BIGINT DEFAULT 0,
-			link TEXT NOT NULL UNIQUE
-		);
-	`)
-	if err != nil {
-		return fmt.Errorf("could not create table: %v", err)
-	}
-
-	return nil
+// Ping проверяет соединение с базой данных.
+func (s *Storage) Ping(ctx context.Context) error {
+	defer observeQuery(ctx, "ping")()
+	return s.db.PingContext(ctx)
 }
 
 // Close закрывает соединение с базой данных.
@@ -84,118 +141,349 @@ func (s *Storage) Close() error {
 	return s.db.Close()
 }
 
-// SavePost сохраняет одну публикацию в БД.
-func (s *Storage) SavePost(post rss.Post) error {
-	query := `
-        INSERT INTO posts (title, content, pub_time, link)
-        VALUES ($1, $2, $3, $4)
-        RETURNING id
-    `
-	// Парсим дату публикации
-	var pubTime time.Time
-	var err error
-	timeFormats := []string{
-		time.RFC1123Z,
-		time.RFC1123,
-		"Mon, 2 Jan 2006 15:04:05 -0700",
-		"Mon, 2 Jan 2006 15:04:05 MST",
-	}
-
-	for _, format := range timeFormats {
-		pubTime, err = time.Parse(format, post.PubDate)
-		if err == nil {
-			break
+// observeQuery замеряет операцию с базой данных для метрик и создаёт её спан.
+// Операции, вызываемые без контекста, получают спан без родителя.
+func observeQuery(ctx context.Context, query string) func() {
+	stop := metrics.ObserveQuery(query)
+	_, span := tracing.Tracer().Start(ctx, "db "+query,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperation(query)))
+	return func() {
+		span.End()
+		stop()
+	}
+}
+
+// Результат сохранения одной публикации.
+const (
+	postInserted  = "inserted"  // Новая публикация
+	postUpdated   = "updated"   // У существующей публикации изменились заголовок, содержание или метаданные
+	postUnchanged = "unchanged" // Публикация уже сохранена без изменений
+)
+
+// upsertPostQuery вставляет публикацию или обновляет уже сохранённую публикацию
+// с тем же ключом дедупликации. Пустой полный текст не затирает загруженный ранее.
+// Если ничего не изменилось, запрос не возвращает строк.
+const upsertPostQuery = `
+	INSERT INTO posts (title, content, pub_time, link, source, source_id, guid, author, categories, enclosures, dedup_key,
+		content_text, full_content, simhash)
+	VALUES ($1, $2, $3, $4, $5, (SELECT id FROM sources WHERE url = $5), $6, $7, $8, $9, $10, $11, $12, $13)
+	ON CONFLICT (dedup_key) DO UPDATE
+	SET title = EXCLUDED.title, content = EXCLUDED.content, content_text = EXCLUDED.content_text, link = EXCLUDED.link,
+		author = EXCLUDED.author, categories = EXCLUDED.categories, enclosures = EXCLUDED.enclosures,
+		full_content = COALESCE(NULLIF(EXCLUDED.full_content, ''), posts.full_content),
+		simhash = EXCLUDED.simhash
+	WHERE posts.simhash IS DISTINCT FROM EXCLUDED.simhash
+		OR posts.title IS DISTINCT FROM EXCLUDED.title
+		OR (EXCLUDED.full_content <> '' AND posts.full_content IS DISTINCT FROM EXCLUDED.full_content)
+		OR posts.content IS DISTINCT FROM EXCLUDED.content
+		OR posts.content_text IS DISTINCT FROM EXCLUDED.content_text
+		OR posts.link IS DISTINCT FROM EXCLUDED.link
+		OR posts.author IS DISTINCT FROM EXCLUDED.author
+		OR posts.categories IS DISTINCT FROM EXCLUDED.categories
+		OR posts.enclosures IS DISTINCT FROM EXCLUDED.enclosures
+	RETURNING id, (xmax = 0) AS inserted
+`
+
+// adoptPostQuery переводит публикацию, сохранённую по ссылке до появления GUID,
+// на ключ по GUID, чтобы она не задвоилась при следующем опросе ленты.
+const adoptPostQuery = `
+	UPDATE posts SET dedup_key = $1, guid = $2
+	WHERE dedup_key = $3 AND guid = ''
+		AND NOT EXISTS (SELECT 1 FROM posts WHERE dedup_key = $1)
+	RETURNING id
+`
+
+// clusterWindow — насколько далеко по времени публикации ищутся похожие публикации.
+const clusterWindow = 3 * 24 * time.Hour
+
+// findClusterQuery выбирает отпечатки публикаций, опубликованных в пределах clusterWindow.
+// Расстояние между отпечатками сравнивается в findCluster.
+const findClusterQuery = `
+	SELECT COALESCE(cluster_id, id), simhash FROM posts
+	WHERE pub_time BETWEEN $1 AND $2 AND simhash <> 0 AND id <> $3
+	ORDER BY pub_time, id
+`
+
+// findCluster возвращает ID кластера самой похожей на публикацию id публикации
+// или 0, если похожих нет. При равном расстоянии выбирается более ранняя публикация.
+func findCluster(ctx context.Context, q querier, id int, fingerprint uint64, pubTime time.Time) (int, error) {
+	if fingerprint == 0 {
+		return 0, nil
+	}
+
+	rows, err := q.QueryContext(ctx, findClusterQuery, pubTime.Add(-clusterWindow).Unix(), pubTime.Add(clusterWindow).Unix(), id)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't find similar posts: %v", err)
+	}
+	defer rows.Close()
+
+	cluster, best := 0, simhash.MaxDistance+1
+	for rows.Next() {
+		var clusterID int
+		var other int64
+		if err := rows.Scan(&clusterID, &other); err != nil {
+			return 0, fmt.Errorf("couldn't scan similar post: %v", err)
 		}
+		if d := simhash.Distance(fingerprint, uint64(other)); simhash.Similar(fingerprint, uint64(other)) && d < best {
+			cluster, best = clusterID, d
+		}
+	}
+	return cluster, rows.Err()
+}
+
+// postFingerprint возвращает отпечаток заголовка и текста публикации.
+func postFingerprint(post rss.Post) uint64 {
+	return simhash.Fingerprint(post.Title + " " + searchText(post))
+}
+
+// dedupKey возвращает ключ, по которому определяется, что публикация уже сохранена.
+// GUID в виде абсолютного URL уникален сам по себе, прочие GUID уникальны
+// только в пределах ленты. Без GUID публикация определяется по ссылке.
+func dedupKey(post rss.Post) string {
+	if post.GUID == "" {
+		return post.Link
+	}
+	if u, err := url.Parse(post.GUID); err == nil && u.IsAbs() && u.Host != "" {
+		return post.GUID
 	}
+	return post.Source + " " + post.GUID
+}
+
+// SaveReport — итог сохранения пакета публикаций.
+type SaveReport struct {
+	Inserted  int           `json:"inserted"`  // Количество новых публикаций
+	Updated   int           `json:"updated"`   // Количество обновлённых публикаций
+	Unchanged int           `json:"unchanged"` // Количество уже сохранённых без изменений
+	Skipped   []SkippedPost `json:"skipped"`   // Пропущенные публикации с причинами
+}
+
+// SkippedPost описывает публикацию, которую не удалось сохранить.
+type SkippedPost struct {
+	Link   string `json:"link"`
+	Title  string `json:"title"`
+	Reason string `json:"reason"`
+}
+
+// String возвращает краткое описание итога для логов.
+func (r SaveReport) String() string {
+	return fmt.Sprintf("inserted=%d updated=%d unchanged=%d skipped=%d",
+		r.Inserted, r.Updated, r.Unchanged, len(r.Skipped))
+}
+
+// add учитывает результат сохранения одной публикации.
+func (r *SaveReport) add(result string) {
+	switch result {
+	case postInserted:
+		r.Inserted++
+	case postUpdated:
+		r.Updated++
+	case postUnchanged:
+		r.Unchanged++
+	}
+}
+
+// skip добавляет публикацию в список пропущенных.
+func (r *SaveReport) skip(post rss.Post, err error) {
+	r.Skipped = append(r.Skipped, SkippedPost{Link: post.Link, Title: post.Title, Reason: err.Error()})
+}
+
+// querier — общий интерфейс *sql.DB и *sql.Tx для выполнения запросов.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
 
+// upsertPost сохраняет одну публикацию и возвращает результат: вставлена, обновлена или не изменилась.
+func upsertPost(ctx context.Context, q querier, post rss.Post) (string, error) {
+	if post.Link == "" {
+		return "", fmt.Errorf("post has no link")
+	}
+
+	// Парсим дату публикации
+	pubTime, err := rss.ParseDate(post.PubDate)
 	if err != nil {
-		return fmt.Errorf("couldn't parse publication time: %v", err)
+		return "", err
 	}
 
-	unixTime := pubTime.Unix()
+	enclosures := post.Enclosures
+	if enclosures == nil {
+		enclosures = []rss.Enclosure{}
+	}
+	enclosuresJSON, err := json.Marshal(enclosures)
+	if err != nil {
+		return "", fmt.Errorf("couldn't encode enclosures: %v", err)
+	}
+	categories := post.Categories
+	if categories == nil {
+		categories = []string{}
+	}
 
-	// Вставляем пост в таблицу и извлекаем его ID
 	var id int
-	err = s.db.QueryRow(query, post.Title, post.Content, unixTime, post.Link).Scan(&id)
+	key := dedupKey(post)
+	if key != post.Link {
+		err = q.QueryRowContext(ctx, adoptPostQuery, key, post.GUID, post.Link).Scan(&id)
+		if err != nil && err != sql.ErrNoRows {
+			return "", fmt.Errorf("couldn't adopt post: %v", err)
+		}
+	}
+
+	fingerprint := postFingerprint(post)
+	var inserted bool
+	err = q.QueryRowContext(ctx, upsertPostQuery, post.Title, post.Content, pubTime.Unix(), post.Link, post.Source,
+		post.GUID, post.Author, pq.Array(categories), string(enclosuresJSON), key, post.ContentText, post.FullContent,
+		int64(fingerprint)).Scan(&id, &inserted)
+	if err == sql.ErrNoRows {
+		return postUnchanged, nil
+	}
 	if err != nil {
-		return fmt.Errorf("couldn't insert post: %v", err)
+		return "", fmt.Errorf("couldn't upsert post: %v", err)
+	}
+	if !inserted {
+		return postUpdated, nil
 	}
 
-	// Устанавливаем ID в структуру post
-	post.ID = id
+	// Кластер определяется только для новой публикации: при обновлении он не меняется
+	cluster, err := findCluster(ctx, q, id, fingerprint, pubTime)
+	if err != nil {
+		return "", err
+	}
+	if cluster != 0 {
+		if _, err := q.ExecContext(ctx, `UPDATE posts SET cluster_id = $1 WHERE id = $2`, cluster, id); err != nil {
+			return "", fmt.Errorf("couldn't update post cluster: %v", err)
+		}
+	}
+	return postInserted, nil
+}
+
+// savePostsLockID — ключ advisory-блокировки, под которой сохраняются публикации.
+// ID публикаций выдаёт последовательность при вставке, а транзакции фиксируются
+// в произвольном порядке: без блокировки поток /news/stream, читающий публикации
+// с ID больше последнего отправленного, навсегда пропустил бы публикацию
+// с меньшим ID, зафиксированную позже. Под блокировкой транзакции, вставляющие
+// публикации, идут по очереди, и ID видимых публикаций растут без пропусков
+// и у нескольких экземпляров сервиса. Блокировка снимается при завершении транзакции.
+const savePostsLockID = 36043
+
+// lockPostSaves дожидается, пока другие транзакции закончат сохранять публикации.
+func lockPostSaves(ctx context.Context, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, savePostsLockID); err != nil {
+		return fmt.Errorf("could not acquire posts lock: %v", err)
+	}
 	return nil
 }
 
+// SavePost сохраняет одну публикацию в БД. Если публикация с таким GUID
+// (или ссылкой, если GUID нет) уже есть, она обновляется.
+func (s *Storage) SavePost(ctx context.Context, post rss.Post) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %v", err)
+	}
+	defer tx.Rollback() // откатим транзакцию в случае ошибки
+
+	if err := lockPostSaves(ctx, tx); err != nil {
+		return err
+	}
+	if _, err := upsertPost(ctx, tx, post); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 // SavePosts сохраняет несколько публикаций в БД с использованием транзакции.
-func (s *Storage) SavePosts(posts []rss.Post) error {
+// Публикации, которые не удалось сохранить, пропускаются по отдельности
+// и попадают в отчёт вместе с причиной; остальные сохраняются.
+func (s *Storage) SavePosts(ctx context.Context, posts []rss.Post) (SaveReport, error) {
+	defer observeQuery(ctx, "save_posts")()
+
+	var report SaveReport
+
 	// Начинаем транзакцию
-	tx, err := s.db.Begin()
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("could not begin transaction: %v", err)
+		return report, fmt.Errorf("could not begin transaction: %v", err)
 	}
 	defer tx.Rollback() // откатим транзакцию в случае ошибки
 
+	if err := lockPostSaves(ctx, tx); err != nil {
+		return report, err
+	}
+
 	for _, post := range posts {
-		if err := s.SavePostTx(tx, post); err != nil {
-			return fmt.Errorf("could not save post: %v", err)
+		// Точка сохранения позволяет откатить только неудачную публикацию,
+		// не прерывая всю транзакцию
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT save_post`); err != nil {
+			return report, fmt.Errorf("could not create savepoint: %v", err)
 		}
-	}
 
-	// Фиксируем транзакцию
-	return tx.Commit()
-}
+		result, err := upsertPost(ctx, tx, post)
+		if err != nil {
+			report.skip(post, err)
+			if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT save_post`); err != nil {
+				return report, fmt.Errorf("could not rollback to savepoint: %v", err)
+			}
+			continue
+		}
+		report.add(result)
 
-// SavePostTx сохраняет одну публикацию в БД внутри транзакции.
-func (s *Storage) SavePostTx(tx *sql.Tx, post rss.Post) error {
-	query := `
-        INSERT INTO posts (title, content, pub_time, link)
-        VALUES ($1, $2, $3, $4)
-        RETURNING id
-    `
-	// Парсим дату публикации
-	var pubTime time.Time
-	var err error
-	timeFormats := []string{
-		time.RFC1123Z,
-		time.RFC1123,
-		"Mon, 2 Jan 2006 15:04:05 -0700",
-		"Mon, 2 Jan 2006 15:04:05 MST",
-	}
-
-	for _, format := range timeFormats {
-		pubTime, err = time.Parse(format, post.PubDate)
-		if err == nil {
-			break
+		if _, err := tx.ExecContext(ctx, `RELEASE SAVEPOINT save_post`); err != nil {
+			return report, fmt.Errorf("could not release savepoint: %v", err)
 		}
 	}
 
-	if err != nil {
-		return fmt.Errorf("couldn't parse publication time: %v", err)
+	// Фиксируем транзакцию
+	if err := tx.Commit(); err != nil {
+		return SaveReport{}, fmt.Errorf("could not commit transaction: %v", err)
 	}
+	return report, nil
+}
 
-	unixTime := pubTime.Unix()
+// postColumns — поля публикации, которые читает scanPost.
+const postColumns = "id, title, content, content_text, pub_time, link, source, COALESCE(source_id, 0), guid, author, categories, enclosures, COALESCE(cluster_id, id)"
 
-	// Вставляем пост в таблицу и извлекаем его ID
-	var id int
-	err = tx.QueryRow(query, post.Title, post.Content, unixTime, post.Link).Scan(&id)
-	if err != nil {
-		return fmt.Errorf("couldn't insert post: %v", err)
+// rowScanner — общий интерфейс *sql.Row и *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanPost читает публикацию из строки с полями postColumns, за которыми
+// могут следовать дополнительные поля extra.
+func scanPost(row rowScanner, extra ...interface{}) (rss.Post, error) {
+	var post rss.Post
+	var pubTime int64
+	var enclosures []byte
+	dest := []interface{}{&post.ID, &post.Title, &post.Content, &post.ContentText, &pubTime, &post.Link, &post.Source, &post.SourceID,
+		&post.GUID, &post.Author, pq.Array(&post.Categories), &enclosures, &post.ClusterID}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return post, err
+	}
+	if err := json.Unmarshal(enclosures, &post.Enclosures); err != nil {
+		return post, fmt.Errorf("could not decode enclosures: %v", err)
+	}
+	if len(post.Categories) == 0 {
+		post.Categories = nil
+	}
+	if len(post.Enclosures) == 0 {
+		post.Enclosures = nil
 	}
 
-	// Устанавливаем ID в структуру post
-	post.ID = id
-	return nil
+	// Преобразуем Unix timestamp обратно в строку в формате RFC1123Z (UTC)
+	post.PubDate = time.Unix(pubTime, 0).UTC().Format(time.RFC1123Z)
+	return post, nil
 }
 
 // GetLastNPosts возвращает последние N публикаций.
-func (s *Storage) GetLastNPosts(n int) ([]rss.Post, error) {
-	query :=
-		`SELECT id, title, content, pub_time, link
+func (s *Storage) GetLastNPosts(ctx context.Context, n int) ([]rss.Post, error) {
+	defer observeQuery(ctx, "get_last_n_posts")()
+
+	query := `SELECT ` + postColumns + `
 		FROM posts
 		ORDER BY pub_time DESC
 		LIMIT $1`
 
-	rows, err := s.db.Query(query, n)
+	rows, err := s.db.QueryContext(ctx, query, n)
 	if err != nil {
 		return nil, fmt.Errorf("could not get posts: %v", err)
 	}
@@ -203,30 +491,69 @@ func (s *Storage) GetLastNPosts(n int) ([]rss.Post, error) {
 
 	var posts []rss.Post
 	for rows.Next() {
-		var post rss.Post
-		var pubTime int64
-
-		// Извлекаем pub_time как Unix timestamp
-		if err := rows.Scan(&post.ID, &post.Title, &post.Content, &pubTime, &post.Link); err != nil {
+		post, err := scanPost(rows)
+		if err != nil {
 			return nil, fmt.Errorf("could not scan post: %v", err)
 		}
-
-		// Преобразуем Unix timestamp обратно в строку в формате RFC1123Z
-		post.PubDate = time.Unix(pubTime, 0).Format(time.RFC1123Z)
 		posts = append(posts, post)
 	}
 
 	return posts, nil
 }
 
-func (s *Storage) GetPostByID(id int) (*rss.Post, error) {
-	query := `SELECT id, title, content, pub_time, link FROM posts WHERE id = $1`
+// GetPostsAfter возвращает до limit публикаций, сохранённых после публикации с ID id,
+// в порядке сохранения. Используется для потока новых публикаций: публикации
+// сохраняются под savePostsLockID, поэтому позже не появится публикация с ID не больше id.
+func (s *Storage) GetPostsAfter(ctx context.Context, id, limit int) ([]rss.Post, error) {
+	defer observeQuery(ctx, "get_posts_after")()
 
-	var post rss.Post
-	var pubTime int64
+	query := `SELECT ` + postColumns + `
+		FROM posts
+		WHERE id > $1
+		ORDER BY id
+		LIMIT $2`
+
+	rows, err := s.db.QueryContext(ctx, query, id, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not get posts: %v", err)
+	}
+	defer rows.Close()
+
+	var posts []rss.Post
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan post: %v", err)
+		}
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not get posts: %v", err)
+	}
+	return posts, nil
+}
+
+// LastPostID возвращает ID последней сохранённой публикации или 0, если публикаций нет.
+func (s *Storage) LastPostID(ctx context.Context) (int, error) {
+	defer observeQuery(ctx, "last_post_id")()
+
+	var id int
+	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM posts`).Scan(&id); err != nil {
+		return 0, fmt.Errorf("could not get last post id: %v", err)
+	}
+	return id, nil
+}
+
+// GetPostByID возвращает публикацию по ID или nil, если её нет.
+// В отличие от списков публикаций, возвращается и полный текст статьи.
+func (s *Storage) GetPostByID(ctx context.Context, id int) (*rss.Post, error) {
+	defer observeQuery(ctx, "get_post_by_id")()
+
+	query := `SELECT ` + postColumns + `, full_content FROM posts WHERE id = $1`
 
 	// Выполняем запрос
-	err := s.db.QueryRow(query, id).Scan(&post.ID, &post.Title, &post.Content, &pubTime, &post.Link)
+	var fullContent string
+	post, err := scanPost(s.db.QueryRowContext(ctx, query, id), &fullContent)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// Если запись с таким ID не найдена, возвращаем nil
@@ -235,39 +562,311 @@ func (s *Storage) GetPostByID(id int) (*rss.Post, error) {
 		return nil, fmt.Errorf("could not get post: %v", err)
 	}
 
-	// Преобразуем pub_time из Unix timestamp в строку формата RFC1123Z
-	post.PubDate = time.Unix(pubTime, 0).Format(time.RFC1123Z)
+	post.FullContent = fullContent
 	return &post, nil
 }
 
-// SearchPostsByTitle ищет новости по заголовку с учетом пагинации.
-func (s *Storage) SearchPostsByTitle(ctx context.Context, query string, limit, offset int) ([]rss.Post, int, error) {
-	// Сначала получаем общее количество результатов для пагинации
-	var totalCount int
-	countQuery := `SELECT COUNT(*) FROM posts WHERE title ILIKE $1`
-	err := s.db.QueryRowContext(ctx, countQuery, "%"+query+"%").Scan(&totalCount)
-	if err != nil {
-		return nil, 0, fmt.Errorf("could not count posts: %v", err)
+// searchQueryCTE разбирает поисковый запрос websearch_to_tsquery, поэтому поддерживаются
+// фразы в кавычках, исключение слов через минус и OR. Ленты смешанные, поэтому запрос
+// строится и в русской, и в английской конфигурации.
+const searchQueryCTE = `WITH q AS (
+	SELECT websearch_to_tsquery('russian', $1) || websearch_to_tsquery('english', $1) AS query
+)`
+
+// postsWhere строит условия выборки публикаций по фильтру и аргументы запроса.
+// При непустом поисковом запросе первым аргументом идёт сам запрос для searchQueryCTE.
+// Условие курсора добавляется, только если withCursor равно true.
+// При свёрнутой выдаче публикации фильтруются в подзапросе, который нумерует их
+// внутри кластера, а снаружи остаются первые публикации кластеров.
+func postsWhere(filter PostFilter, withCursor bool) (from string, where string, args []interface{}) {
+	from = "posts"
+	var conds []string
+	if filter.Query != "" {
+		from = "posts, q"
+		args = append(args, filter.Query)
+		conds = append(conds, "search_vector @@ q.query")
+	}
+	if filter.Source != "" {
+		args = append(args, filter.Source)
+		conds = append(conds, fmt.Sprintf("source = $%d", len(args)))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From.Unix())
+		conds = append(conds, fmt.Sprintf("pub_time >= $%d", len(args)))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To.Unix())
+		conds = append(conds, fmt.Sprintf("pub_time <= $%d", len(args)))
+	}
+	if filter.Collapse {
+		inner := ""
+		if len(conds) > 0 {
+			inner = "WHERE " + strings.Join(conds, " AND ")
+		}
+		from = fmt.Sprintf(`(
+			SELECT posts.*, row_number() OVER (PARTITION BY COALESCE(cluster_id, id) ORDER BY pub_time, id) AS cluster_rank
+			FROM %s %s
+		) AS posts`, from, inner)
+		if filter.Query != "" {
+			from += ", q"
+		}
+		conds = []string{"cluster_rank = 1"}
+	}
+	if withCursor && filter.Cursor != nil {
+		op := "<"
+		if filter.Cursor.ascending() {
+			op = ">"
+		}
+		args = append(args, filter.Cursor.PubTime, filter.Cursor.ID)
+		conds = append(conds, fmt.Sprintf("(pub_time, id) %s ($%d, $%d)", op, len(args)-1, len(args)))
+	}
+	if len(conds) > 0 {
+		where = "WHERE " + strings.Join(conds, " AND ")
+	}
+	return from, where, args
+}
+
+// SearchPosts ищет новости по фильтру с учетом пагинации и возвращает их общее количество.
+// При непустом запросе поиск идёт по заголовку и содержанию, а результаты содержат
+// релевантность и фрагмент текста с подсвеченными совпадениями.
+// Если задан курсор, страница выбирается по ключу (pub_time, id) вместо смещения.
+func (s *Storage) SearchPosts(ctx context.Context, filter PostFilter) ([]rss.Post, int, error) {
+	defer observeQuery(ctx, "search_posts")()
+
+	filter.Query = strings.TrimSpace(filter.Query)
+	from, where, args := postsWhere(filter, true)
+
+	columns := postColumns
+	with := ""
+	if filter.Query != "" {
+		with = searchQueryCTE
+		columns += `, ts_rank_cd(search_vector, q.query) AS rank,
+			ts_headline('russian', COALESCE(NULLIF(content_text, ''), content), q.query,
+				'StartSel=` + highlightStart + `, StopSel=` + highlightStop + `, MaxFragments=2, MaxWords=30, MinWords=10') AS highlight`
 	}
 
-	// Затем выполняем запрос на получение нужной страницы
-	selectQuery := `SELECT id, title, content, pub_time, link FROM posts WHERE title ILIKE $1 ORDER BY pub_time DESC LIMIT $2 OFFSET $3`
-	rows, err := s.db.QueryContext(ctx, selectQuery, "%"+query+"%", limit, offset)
+	var orderBy string
+	switch {
+	case filter.Cursor != nil && filter.Cursor.ascending():
+		orderBy = "pub_time ASC, id ASC"
+	case filter.Cursor != nil:
+		orderBy = "pub_time DESC, id DESC"
+	case filter.SortOrder() == SortRelevance:
+		orderBy = "rank DESC, pub_time DESC, id DESC"
+	case filter.SortOrder() == SortOldest:
+		orderBy = "pub_time ASC, id ASC"
+	default:
+		orderBy = "pub_time DESC, id DESC"
+	}
+
+	offset := filter.Offset
+	if filter.Cursor != nil {
+		offset = 0
+	}
+	args = append(args, filter.Limit, offset)
+	selectQuery := fmt.Sprintf(`%s
+		SELECT %s, COUNT(*) OVER () AS total
+		FROM %s
+		%s
+		ORDER BY %s
+		LIMIT $%d OFFSET $%d`, with, columns, from, where, orderBy, len(args)-1, len(args))
+
+	rows, err := s.db.QueryContext(ctx, selectQuery, args...)
 	if err != nil {
-		return nil, 0, fmt.Errorf("could not get posts: %v", err)
+		return nil, 0, fmt.Errorf("could not search posts: %v", err)
 	}
 	defer rows.Close()
 
 	var posts []rss.Post
+	var totalCount int
 	for rows.Next() {
-		var post rss.Post
-		var pubTime int64
-		if err := rows.Scan(&post.ID, &post.Title, &post.Content, &pubTime, &post.Link); err != nil {
+		var rank float64
+		var highlight string
+		var extra []interface{}
+		if filter.Query != "" {
+			extra = append(extra, &rank, &highlight)
+		}
+		post, err := scanPost(rows, append(extra, &totalCount)...)
+		if err != nil {
 			return nil, 0, fmt.Errorf("could not scan post: %v", err)
 		}
-		post.PubDate = time.Unix(pubTime, 0).Format(time.RFC1123Z)
+		post.Rank, post.Highlight = rank, markHighlight(highlight)
 		posts = append(posts, post)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("could not search posts: %v", err)
+	}
+
+	// Страница перед курсором выбиралась в обратном порядке
+	if filter.Cursor != nil && filter.Cursor.Backward {
+		reversePosts(posts)
+	}
+
+	if filter.Collapse {
+		if err := s.attachAlternates(ctx, posts); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	// С курсором оконная функция считает только строки за ним, а за пределами
+	// последней страницы строк нет вовсе — в этих случаях считаем общее количество отдельно
+	if filter.Cursor != nil || (len(posts) == 0 && filter.Offset > 0) {
+		from, where, countArgs := postsWhere(filter, false)
+		countQuery := fmt.Sprintf(`%s SELECT COUNT(*) FROM %s %s`, with, from, where)
+		if err := s.db.QueryRowContext(ctx, countQuery, countArgs...).Scan(&totalCount); err != nil {
+			return nil, 0, fmt.Errorf("could not count posts: %v", err)
+		}
+	}
 
 	return posts, totalCount, nil
 }
+
+// attachAlternates прикладывает к публикациям остальные публикации их кластеров.
+func (s *Storage) attachAlternates(ctx context.Context, posts []rss.Post) error {
+	defer observeQuery(ctx, "attach_alternates")()
+
+	if len(posts) == 0 {
+		return nil
+	}
+	clusters := make([]int64, len(posts))
+	for i, post := range posts {
+		clusters[i] = int64(post.ClusterID)
+	}
+
+	query := `
+		SELECT COALESCE(cluster_id, id), id, title, link, source
+		FROM posts
+		WHERE cluster_id = ANY($1) OR id = ANY($1)
+		ORDER BY pub_time, id`
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(clusters))
+	if err != nil {
+		return fmt.Errorf("could not get alternates: %v", err)
+	}
+	defer rows.Close()
+
+	alternates := make(map[int][]rss.Alternate)
+	for rows.Next() {
+		var clusterID int
+		var alt rss.Alternate
+		if err := rows.Scan(&clusterID, &alt.ID, &alt.Title, &alt.Link, &alt.Source); err != nil {
+			return fmt.Errorf("could not scan alternate: %v", err)
+		}
+		alternates[clusterID] = append(alternates[clusterID], alt)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("could not get alternates: %v", err)
+	}
+
+	for i := range posts {
+		for _, alt := range alternates[posts[i].ClusterID] {
+			if alt.ID != posts[i].ID {
+				posts[i].Alternates = append(posts[i].Alternates, alt)
+			}
+		}
+	}
+	return nil
+}
+
+// reversePosts переворачивает порядок публикаций на месте.
+func reversePosts(posts []rss.Post) {
+	for i, j := 0, len(posts)-1; i < j; i, j = i+1, j-1 {
+		posts[i], posts[j] = posts[j], posts[i]
+	}
+}
+
+// GetValidators возвращает сохранённые ETag и Last-Modified для ленты.
+// Если лента ещё не опрашивалась, возвращаются пустые валидаторы.
+func (s *Storage) GetValidators(ctx context.Context, url string) (rss.Validators, error) {
+	defer observeQuery(ctx, "get_validators")()
+
+	query := `SELECT etag, last_modified FROM feeds WHERE url = $1`
+
+	var v rss.Validators
+	err := s.db.QueryRowContext(ctx, query, url).Scan(&v.ETag, &v.LastModified)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return rss.Validators{}, nil
+		}
+		return rss.Validators{}, fmt.Errorf("could not get validators: %v", err)
+	}
+	return v, nil
+}
+
+// SaveValidators сохраняет ETag и Last-Modified, полученные при последнем опросе ленты.
+func (s *Storage) SaveValidators(ctx context.Context, url string, v rss.Validators) error {
+	defer observeQuery(ctx, "save_validators")()
+
+	query := `
+		INSERT INTO feeds (url, etag, last_modified)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (url) DO UPDATE
+		SET etag = EXCLUDED.etag, last_modified = EXCLUDED.last_modified
+	`
+	if _, err := s.db.ExecContext(ctx, query, url, v.ETag, v.LastModified); err != nil {
+		return fmt.Errorf("could not save validators: %v", err)
+	}
+	return nil
+}
+
+// SaveFeedStatus сохраняет состояние опроса ленты, не затрагивая валидаторы кэша.
+func (s *Storage) SaveFeedStatus(ctx context.Context, status FeedStatus) error {
+	defer observeQuery(ctx, "save_feed_status")()
+
+	query := `
+		INSERT INTO feeds (url, last_success_at, last_error, last_error_at, consecutive_failures, item_count, next_poll_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (url) DO UPDATE
+		SET last_success_at = EXCLUDED.last_success_at,
+			last_error = EXCLUDED.last_error,
+			last_error_at = EXCLUDED.last_error_at,
+			consecutive_failures = EXCLUDED.consecutive_failures,
+			item_count = EXCLUDED.item_count,
+			next_poll_at = EXCLUDED.next_poll_at
+	`
+	_, err := s.db.ExecContext(ctx, query, status.URL, status.LastSuccessAt, status.LastError, status.LastErrorAt,
+		status.ConsecutiveFailures, status.ItemCount, status.NextPollAt)
+	if err != nil {
+		return fmt.Errorf("could not save feed status: %v", err)
+	}
+	return nil
+}
+
+// GetFeedStatuses возвращает состояние опроса всех известных лент.
+func (s *Storage) GetFeedStatuses(ctx context.Context) ([]FeedStatus, error) {
+	defer observeQuery(ctx, "get_feed_statuses")()
+
+	query := `
+		SELECT url, last_success_at, last_error, last_error_at, consecutive_failures, item_count, next_poll_at
+		FROM feeds
+		ORDER BY url`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("could not get feed statuses: %v", err)
+	}
+	defer rows.Close()
+
+	var statuses []FeedStatus
+	for rows.Next() {
+		var status FeedStatus
+		var lastSuccessAt, lastErrorAt, nextPollAt sql.NullTime
+		if err := rows.Scan(&status.URL, &lastSuccessAt, &status.LastError, &lastErrorAt,
+			&status.ConsecutiveFailures, &status.ItemCount, &nextPollAt); err != nil {
+			return nil, fmt.Errorf("could not scan feed status: %v", err)
+		}
+		status.LastSuccessAt = nullTimePtr(lastSuccessAt)
+		status.LastErrorAt = nullTimePtr(lastErrorAt)
+		status.NextPollAt = nullTimePtr(nextPollAt)
+		statuses = append(statuses, status)
+	}
+
+	return statuses, rows.Err()
+}
+
+// nullTimePtr преобразует sql.NullTime в указатель, равный nil для NULL.
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -3,20 +3,15 @@ package storage
 import (
 	"Task36a41/pkg/rss"
 	"context"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 )
 
 func setupDatabase(db *Storage) error {
-	// Drop table если создано, иначе создать
-	_, err := db.db.Exec(`DROP TABLE IF EXISTS posts;
-        CREATE TABLE posts (
-            id SERIAL PRIMARY KEY,
-            title TEXT NOT NULL,
-            content TEXT NOT NULL,
-            pub_time BIGINT NOT NULL,
-            link TEXT NOT NULL UNIQUE
-        );`)
+	// Схему создают миграции в New, здесь только очищаем данные
+	_, err := db.db.Exec(`TRUNCATE posts, sources RESTART IDENTITY`)
 	return err
 }
 
@@ -48,13 +43,13 @@ func TestSaveAndGetPosts(t *testing.T) {
 	}
 
 	// Сохраняем пост
-	err = db.SavePost(post)
+	err = db.SavePost(context.Background(), post)
 	if err != nil {
 		t.Fatalf("Error saving post: %v", err)
 	}
 
 	// Получаем посты
-	posts, err := db.GetLastNPosts(1)
+	posts, err := db.GetLastNPosts(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("Error getting posts: %v", err)
 	}
@@ -65,7 +60,7 @@ func TestSaveAndGetPosts(t *testing.T) {
 	}
 }
 
-func TestSearchPostsByTitle(t *testing.T) {
+func TestSearchPosts(t *testing.T) {
 	// Используем строку подключения к тестовой базе данных
 	db, err := New("user=postgres password=vlad5043 dbname=News sslmode=disable")
 	if err != nil {
@@ -78,46 +73,555 @@ func TestSearchPostsByTitle(t *testing.T) {
 		t.Fatalf("Error setting up database: %v", err)
 	}
 
-	// Пример данных для поиска
+	// Пример данных для поиска: русские и английские публикации
 	posts := []rss.Post{
 		{Title: "Go Programming Language", Content: "Learn Go", PubDate: time.Now().Format(time.RFC1123Z), Link: "http://example.com/go"},
 		{Title: "Learning Python", Content: "Python basics", PubDate: time.Now().Format(time.RFC1123Z), Link: "http://example.com/python"},
-		{Title: "Advanced Go Concepts", Content: "Deep dive into Go", PubDate: time.Now().Format(time.RFC1123Z), Link: "http://example.com/advanced-go"},
+		{Title: "Advanced Concepts", Content: "Deep dive into Go generics and Python interop", PubDate: time.Now().Format(time.RFC1123Z), Link: "http://example.com/advanced-go"},
+		{Title: "Горутины изнутри", Content: "Как устроен планировщик горутин", PubDate: time.Now().Format(time.RFC1123Z), Link: "http://example.com/goroutines"},
+		{Title: "XSS", ContentText: `Payload <script>alert("xss")</script> in text`, PubDate: time.Now().Format(time.RFC1123Z), Link: "http://example.com/xss"},
 	}
 
 	// Сохраняем тестовые данные
 	for _, post := range posts {
-		if err := db.SavePost(post); err != nil {
+		if err := db.SavePost(context.Background(), post); err != nil {
 			t.Fatalf("Error saving post: %v", err)
 		}
 	}
+	ctx := context.Background()
+
+	// Поиск по заголовку и содержанию, совпадение в заголовке ранжируется выше
+	results, total, err := db.SearchPosts(ctx, PostFilter{Query: "go", Limit: 10})
+	if err != nil {
+		t.Fatalf("Error searching posts: %v", err)
+	}
+	if total != 2 || len(results) != 2 {
+		t.Fatalf("Expected 2 results, got total=%d len=%d", total, len(results))
+	}
+	if results[0].Title != "Go Programming Language" {
+		t.Errorf("Expected title match first, got %s", results[0].Title)
+	}
+	if !strings.Contains(results[1].Highlight, "<mark>") {
+		t.Errorf("Expected highlight with <mark>, got %q", results[1].Highlight)
+	}
+
+	// Разметка из текста публикации в фрагменте экранируется
+	results, _, err = db.SearchPosts(ctx, PostFilter{Query: "payload", Limit: 10})
+	if err != nil {
+		t.Fatalf("Error searching posts: %v", err)
+	}
+	if len(results) != 1 || strings.Contains(results[0].Highlight, "<script>") ||
+		!strings.Contains(results[0].Highlight, "<mark>Payload</mark> &lt;script&gt;") {
+		t.Errorf("Expected escaped highlight, got %+v", results)
+	}
+
+	// Исключение слова
+	_, total, err = db.SearchPosts(ctx, PostFilter{Query: "go -python", Limit: 10})
+	if err != nil {
+		t.Fatalf("Error searching posts: %v", err)
+	}
+	if total != 1 {
+		t.Errorf("Expected 1 result without python, got %d", total)
+	}
+
+	// Фраза в кавычках
+	results, total, err = db.SearchPosts(ctx, PostFilter{Query: `"go generics"`, Limit: 10})
+	if err != nil {
+		t.Fatalf("Error searching posts: %v", err)
+	}
+	if total != 1 || results[0].Link != "http://example.com/advanced-go" {
+		t.Errorf("Unexpected phrase results: %+v", results)
+	}
+
+	// Русская морфология: «горутина» находит «горутины»
+	_, total, err = db.SearchPosts(ctx, PostFilter{Query: "горутина", Limit: 10})
+	if err != nil {
+		t.Fatalf("Error searching posts: %v", err)
+	}
+	if total != 1 {
+		t.Errorf("Expected 1 result for russian query, got %d", total)
+	}
 
-	// Выполняем поиск
+	// Страница за пределами результатов сохраняет общее количество
+	results, total, err = db.SearchPosts(ctx, PostFilter{Query: "go", Limit: 10, Offset: 10})
+	if err != nil {
+		t.Fatalf("Error searching posts: %v", err)
+	}
+	if len(results) != 0 || total != 2 {
+		t.Errorf("Expected empty page with total 2, got len=%d total=%d", len(results), total)
+	}
+}
+
+func TestSearchPostsFilters(t *testing.T) {
+	// Используем строку подключения к тестовой базе данных
+	db, err := New("user=postgres password=vlad5043 dbname=News sslmode=disable")
+	if err != nil {
+		t.Fatalf("Error connecting to database: %v", err)
+	}
+	defer db.Close()
+
+	// Настраиваем базу данных
+	if err := setupDatabase(db); err != nil {
+		t.Fatalf("Error setting up database: %v", err)
+	}
+
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	var posts []rss.Post
+	for i := 0; i < 6; i++ {
+		source := "http://a.example/rss"
+		if i%2 == 1 {
+			source = "http://b.example/rss"
+		}
+		posts = append(posts, rss.Post{
+			Title:   fmt.Sprintf("Go post %d", i),
+			Content: "Content",
+			PubDate: base.AddDate(0, 0, i).Format(time.RFC1123Z),
+			Link:    fmt.Sprintf("http://example.com/%d", i),
+			Source:  source,
+		})
+	}
+	if _, err := db.SavePosts(context.Background(), posts); err != nil {
+		t.Fatalf("Error saving posts: %v", err)
+	}
 	ctx := context.Background()
-	query := "Go"
-	limit := 2
-	offset := 0
 
-	results, total, err := db.SearchPostsByTitle(ctx, query, limit, offset)
+	results, total, err := db.SearchPosts(ctx, PostFilter{Query: "go", Source: "http://b.example/rss", Sort: SortNewest, Limit: 10})
+	if err != nil {
+		t.Fatalf("Error searching posts: %v", err)
+	}
+	if total != 3 || results[0].Title != "Go post 5" || results[0].Source != "http://b.example/rss" {
+		t.Errorf("Unexpected results for source filter: total=%d %+v", total, results)
+	}
+
+	results, total, err = db.SearchPosts(ctx, PostFilter{
+		From:  base.AddDate(0, 0, 1),
+		To:    base.AddDate(0, 0, 3),
+		Sort:  SortOldest,
+		Limit: 2,
+	})
+	if err != nil {
+		t.Fatalf("Error searching posts: %v", err)
+	}
+	if total != 3 || len(results) != 2 || results[0].Title != "Go post 1" {
+		t.Errorf("Unexpected results for date range: total=%d %+v", total, results)
+	}
+
+	// Курсор строится по сохранённым публикациям, у которых уже есть ID
+	posts, _, _ = db.SearchPosts(ctx, PostFilter{Sort: SortOldest, Limit: 10})
+	cursor, _ := DecodeCursor(CursorAfter(posts[3], SortNewest))
+	results, total, err = db.SearchPosts(ctx, PostFilter{Cursor: cursor, Limit: 2})
+	if err != nil {
+		t.Fatalf("Error searching posts by cursor: %v", err)
+	}
+	if total != 6 || len(results) != 2 || results[0].Title != "Go post 2" || results[1].Title != "Go post 1" {
+		t.Errorf("Unexpected results after cursor: total=%d %+v", total, results)
+	}
+
+	cursor, _ = DecodeCursor(CursorBefore(posts[2], SortNewest))
+	results, _, err = db.SearchPosts(ctx, PostFilter{Cursor: cursor, Limit: 2})
+	if err != nil {
+		t.Fatalf("Error searching posts by cursor: %v", err)
+	}
+	if len(results) != 2 || results[0].Title != "Go post 4" || results[1].Title != "Go post 3" {
+		t.Errorf("Unexpected results before cursor: %+v", results)
+	}
+}
+
+func TestValidators(t *testing.T) {
+	// Используем строку подключения к тестовой базе данных
+	db, err := New("user=postgres password=vlad5043 dbname=News sslmode=disable")
+	if err != nil {
+		t.Fatalf("Error connecting to database: %v", err)
+	}
+	defer db.Close()
+
+	url := "http://example.com/feed.xml"
+	if _, err := db.db.Exec("DELETE FROM feeds WHERE url = $1", url); err != nil {
+		t.Fatalf("Error cleaning table: %v", err)
+	}
+
+	// Для неопрошенной ленты валидаторы пустые
+	v, err := db.GetValidators(context.Background(), url)
+	if err != nil {
+		t.Fatalf("Error getting validators: %v", err)
+	}
+	if v != (rss.Validators{}) {
+		t.Errorf("Expected empty validators, got %+v", v)
+	}
+
+	// Сохраняем и перезаписываем валидаторы
+	for _, want := range []rss.Validators{
+		{ETag: `"v1"`, LastModified: "Mon, 04 Mar 2024 09:15:00 GMT"},
+		{ETag: `"v2"`},
+	} {
+		if err := db.SaveValidators(context.Background(), url, want); err != nil {
+			t.Fatalf("Error saving validators: %v", err)
+		}
+		got, err := db.GetValidators(context.Background(), url)
+		if err != nil {
+			t.Fatalf("Error getting validators: %v", err)
+		}
+		if got != want {
+			t.Errorf("Expected %+v, got %+v", want, got)
+		}
+	}
+}
+
+func TestFeedStatuses(t *testing.T) {
+	// Используем строку подключения к тестовой базе данных
+	db, err := New("user=postgres password=vlad5043 dbname=News sslmode=disable")
+	if err != nil {
+		t.Fatalf("Error connecting to database: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.db.Exec("DELETE FROM feeds"); err != nil {
+		t.Fatalf("Error cleaning table: %v", err)
+	}
+
+	url := "http://example.com/feed.xml"
+	if err := db.SaveValidators(context.Background(), url, rss.Validators{ETag: `"v1"`}); err != nil {
+		t.Fatalf("Error saving validators: %v", err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	next := now.Add(10 * time.Minute)
+	status := FeedStatus{
+		URL:                 url,
+		LastError:           "unexpected status 503 Service Unavailable",
+		LastErrorAt:         &now,
+		ConsecutiveFailures: 2,
+		ItemCount:           15,
+		NextPollAt:          &next,
+	}
+	if err := db.SaveFeedStatus(context.Background(), status); err != nil {
+		t.Fatalf("Error saving feed status: %v", err)
+	}
+
+	statuses, err := db.GetFeedStatuses(context.Background())
+	if err != nil {
+		t.Fatalf("Error getting feed statuses: %v", err)
+	}
+	if len(statuses) != 1 {
+		t.Fatalf("Expected 1 feed, got %d", len(statuses))
+	}
+	got := statuses[0]
+	if got.ConsecutiveFailures != 2 || got.ItemCount != 15 || got.LastError != status.LastError {
+		t.Errorf("Unexpected feed status: %+v", got)
+	}
+	if got.LastSuccessAt != nil || got.NextPollAt == nil || !got.NextPollAt.Equal(next) {
+		t.Errorf("Unexpected feed status times: %+v", got)
+	}
+
+	// Сохранение состояния не должно затирать валидаторы кэша
+	if v, _ := db.GetValidators(context.Background(), url); v.ETag != `"v1"` {
+		t.Errorf("Expected validators to be preserved, got %+v", v)
+	}
+}
+
+func TestSavePostsReport(t *testing.T) {
+	// Используем строку подключения к тестовой базе данных
+	db, err := New("user=postgres password=vlad5043 dbname=News sslmode=disable")
+	if err != nil {
+		t.Fatalf("Error connecting to database: %v", err)
+	}
+	defer db.Close()
+
+	// Настраиваем базу данных
+	if err := setupDatabase(db); err != nil {
+		t.Fatalf("Error setting up database: %v", err)
+	}
+
+	now := time.Now().Format(time.RFC1123Z)
+	first := []rss.Post{
+		{Title: "Post A", Content: "A", PubDate: now, Link: "http://example.com/a"},
+		{Title: "Post B", Content: "B", PubDate: now, Link: "http://example.com/b"},
+	}
+	report, err := db.SavePosts(context.Background(), first)
+	if err != nil {
+		t.Fatalf("Error saving posts: %v", err)
+	}
+	if report.Inserted != 2 || report.Updated != 0 || len(report.Skipped) != 0 {
+		t.Errorf("Unexpected report for first batch: %s", report)
+	}
+
+	// Повторный опрос: одна публикация изменилась, одна без изменений,
+	// одна с неразбираемой датой, одна новая
+	second := []rss.Post{
+		{Title: "Post A (updated)", Content: "A", PubDate: now, Link: "http://example.com/a"},
+		{Title: "Post B", Content: "B", PubDate: now, Link: "http://example.com/b"},
+		{Title: "Bad date", Content: "C", PubDate: "yesterday", Link: "http://example.com/c"},
+		{Title: "Post D", Content: "D", PubDate: now, Link: "http://example.com/d"},
+	}
+	report, err = db.SavePosts(context.Background(), second)
+	if err != nil {
+		t.Fatalf("Error saving posts: %v", err)
+	}
+	if report.Inserted != 1 || report.Updated != 1 || report.Unchanged != 1 || len(report.Skipped) != 1 {
+		t.Errorf("Unexpected report for second batch: %s", report)
+	}
+	if len(report.Skipped) == 1 && report.Skipped[0].Link != "http://example.com/c" {
+		t.Errorf("Expected bad post to be skipped, got %+v", report.Skipped[0])
+	}
+
+	posts, err := db.GetLastNPosts(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("Error getting posts: %v", err)
+	}
+	if len(posts) != 3 {
+		t.Errorf("Expected 3 posts, got %d", len(posts))
+	}
+}
+
+func TestPostMetadata(t *testing.T) {
+	// Используем строку подключения к тестовой базе данных
+	db, err := New("user=postgres password=vlad5043 dbname=News sslmode=disable")
+	if err != nil {
+		t.Fatalf("Error connecting to database: %v", err)
+	}
+	defer db.Close()
+
+	// Настраиваем базу данных
+	if err := setupDatabase(db); err != nil {
+		t.Fatalf("Error setting up database: %v", err)
+	}
+
+	post := rss.Post{
+		Title:      "Post",
+		PubDate:    time.Now().Format(time.RFC1123Z),
+		Link:       "http://example.com/post?utm_source=rss",
+		Source:     "http://example.com/rss",
+		GUID:       "post-1",
+		Author:     "Gopher",
+		Categories: []string{"go"},
+		Enclosures: []rss.Enclosure{{URL: "http://example.com/post.mp3", Type: "audio/mpeg", Length: 1024}},
+	}
+	if report, err := db.SavePosts(context.Background(), []rss.Post{post}); err != nil || report.Inserted != 1 {
+		t.Fatalf("Unexpected result of saving post: %s, %v", report, err)
+	}
+
+	// Ссылка изменилась, но GUID тот же — публикация обновляется, а не дублируется
+	post.Link = "http://example.com/post"
+	post.Categories = []string{"go", "news"}
+	if report, err := db.SavePosts(context.Background(), []rss.Post{post}); err != nil || report.Updated != 1 {
+		t.Fatalf("Unexpected result of updating post: %s, %v", report, err)
+	}
+
+	posts, err := db.GetLastNPosts(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("Error getting posts: %v", err)
+	}
+	if len(posts) != 1 {
+		t.Fatalf("Expected 1 post, got %+v", posts)
+	}
+	saved := posts[0]
+	if saved.Link != post.Link || saved.GUID != "post-1" || saved.Author != "Gopher" ||
+		len(saved.Categories) != 2 || len(saved.Enclosures) != 1 || saved.Enclosures[0].Length != 1024 {
+		t.Errorf("Unexpected saved post: %+v", saved)
+	}
+}
+
+func TestPostClusters(t *testing.T) {
+	// Используем строку подключения к тестовой базе данных
+	db, err := New("user=postgres password=vlad5043 dbname=News sslmode=disable")
+	if err != nil {
+		t.Fatalf("Error connecting to database: %v", err)
+	}
+	defer db.Close()
+
+	// Настраиваем базу данных
+	if err := setupDatabase(db); err != nil {
+		t.Fatalf("Error setting up database: %v", err)
+	}
+
+	story := "Как мы ускорили сборку Go-проекта в три раза: кэширование модулей и параллельные тесты сократили время CI."
+	now := time.Now()
+	_, err = db.SavePosts(context.Background(), []rss.Post{
+		{Title: "Сборка Go", Content: story + " Читать далее", PubDate: now.Add(-time.Hour).Format(time.RFC1123Z), Link: "https://habr.com/1", Source: "hub"},
+		{Title: "Go 1.22", Content: "Выпущен Go 1.22 с новым синтаксисом циклов и улучшенным планировщиком.", PubDate: now.Format(time.RFC1123Z), Link: "https://example.com/go122"},
+		{Title: "Сборка Go", Content: story, PubDate: now.Format(time.RFC1123Z), Link: "https://habr.com/1?best", Source: "best"},
+	})
+	if err != nil {
+		t.Fatalf("Error saving posts: %v", err)
+	}
+
+	posts, total, err := db.SearchPosts(context.Background(), PostFilter{Limit: 10, Collapse: true})
 	if err != nil {
 		t.Fatalf("Error searching posts: %v", err)
 	}
+	if total != 2 || len(posts) != 2 {
+		t.Fatalf("Expected 2 collapsed posts, got total=%d %+v", total, posts)
+	}
+	habr := posts[1]
+	if habr.Link != "https://habr.com/1" || len(habr.Alternates) != 1 || habr.Alternates[0].Source != "best" {
+		t.Errorf("Unexpected representative: %+v", habr)
+	}
+
+	posts, total, err = db.SearchPosts(context.Background(), PostFilter{Limit: 10, Collapse: true, Source: "best"})
+	if err != nil || total != 1 || len(posts) != 1 || posts[0].Link != "https://habr.com/1?best" {
+		t.Errorf("Unexpected filtered collapsed posts: total=%d %+v, %v", total, posts, err)
+	}
+}
+
+func TestSources(t *testing.T) {
+	// Используем строку подключения к тестовой базе данных
+	db, err := New("user=postgres password=vlad5043 dbname=News sslmode=disable")
+	if err != nil {
+		t.Fatalf("Error connecting to database: %v", err)
+	}
+	defer db.Close()
+
+	// Настраиваем базу данных
+	if err := setupDatabase(db); err != nil {
+		t.Fatalf("Error setting up database: %v", err)
+	}
+
+	post := rss.Post{Title: "Old", PubDate: "Mon, 04 Mar 2024 09:00:00 +0000", Link: "http://example.com/old", Source: "http://example.com/rss"}
+	if _, err := db.SavePosts(context.Background(), []rss.Post{post}); err != nil {
+		t.Fatalf("Error saving posts: %v", err)
+	}
+
+	source, err := db.AddSource(context.Background(), Source{URL: "http://example.com/rss", Name: "Example", Enabled: true})
+	if err != nil {
+		t.Fatalf("Error adding source: %v", err)
+	}
+	if _, err := db.AddSource(context.Background(), Source{URL: "http://example.com/rss"}); err != ErrSourceExists {
+		t.Errorf("Expected ErrSourceExists, got %v", err)
+	}
+
+	posts, _ := db.GetLastNPosts(context.Background(), 1)
+	if len(posts) != 1 || posts[0].SourceID != source.ID {
+		t.Errorf("Expected existing post to be linked to source: %+v", posts)
+	}
+
+	source.Enabled = false
+	source.PollInterval = 30
+	source.FullText = true
+	if err := db.UpdateSource(context.Background(), source); err != nil {
+		t.Fatalf("Error updating source: %v", err)
+	}
+	stored, err := db.GetSource(context.Background(), source.ID)
+	if err != nil || stored == nil || stored.Enabled || stored.PollInterval != 30 || !stored.FullText {
+		t.Errorf("Unexpected stored source: %+v, %v", stored, err)
+	}
+
+	if err := db.DeleteSource(context.Background(), source.ID); err != nil {
+		t.Fatalf("Error deleting source: %v", err)
+	}
+	if err := db.DeleteSource(context.Background(), source.ID); err != ErrSourceNotFound {
+		t.Errorf("Expected ErrSourceNotFound, got %v", err)
+	}
+	posts, _ = db.GetLastNPosts(context.Background(), 1)
+	if len(posts) != 1 || posts[0].SourceID != 0 {
+		t.Errorf("Expected post to be kept without source: %+v", posts)
+	}
+}
 
-	// Проверяем общее количество результатов
-	if total != 2 {
-		t.Errorf("Expected total 2, got %d", total)
+func TestPostsAfter(t *testing.T) {
+	db, err := New("user=postgres password=vlad5043 dbname=News sslmode=disable")
+	if err != nil {
+		t.Fatalf("Error connecting to database: %v", err)
 	}
+	defer db.Close()
 
-	// Проверяем количество возвращенных записей
-	if len(results) != 2 {
-		t.Errorf("Expected 2 results, got %d", len(results))
+	if err := setupDatabase(db); err != nil {
+		t.Fatalf("Error setting up database: %v", err)
+	}
+	if id, err := db.LastPostID(context.Background()); err != nil || id != 0 {
+		t.Fatalf("Expected last ID 0 for empty table, got %d, %v", id, err)
 	}
 
-	// Проверяем правильность заголовков
-	expectedTitles := []string{"Go Programming Language", "Advanced Go Concepts"}
-	for i, result := range results {
-		if result.Title != expectedTitles[i] {
-			t.Errorf("Expected title %s, got %s", expectedTitles[i], result.Title)
+	base := time.Now().Add(-time.Hour)
+	for i := 1; i <= 3; i++ {
+		post := rss.Post{
+			Title:   fmt.Sprintf("Post %d", i),
+			Content: fmt.Sprintf("Content %d", i),
+			PubDate: base.Add(-time.Duration(i) * time.Hour).Format(time.RFC1123Z),
+			Link:    fmt.Sprintf("http://example.com/after/%d", i),
 		}
+		if err := db.SavePost(context.Background(), post); err != nil {
+			t.Fatalf("Error saving post: %v", err)
+		}
+	}
+
+	last, err := db.LastPostID(context.Background())
+	if err != nil || last != 3 {
+		t.Fatalf("Expected last ID 3, got %d, %v", last, err)
+	}
+
+	// Порядок сохранения, а не даты публикации
+	posts, err := db.GetPostsAfter(context.Background(), 1, 10)
+	if err != nil {
+		t.Fatalf("Error getting posts: %v", err)
+	}
+	if len(posts) != 2 || posts[0].Title != "Post 2" || posts[1].Title != "Post 3" {
+		t.Errorf("Unexpected posts after ID 1: %+v", posts)
+	}
+	if posts, _ := db.GetPostsAfter(context.Background(), 0, 1); len(posts) != 1 || posts[0].ID != 1 {
+		t.Errorf("Expected limit to apply, got %+v", posts)
+	}
+}
+
+func TestPostsAfterConcurrentSaves(t *testing.T) {
+	db, err := New("user=postgres password=vlad5043 dbname=News sslmode=disable")
+	if err != nil {
+		t.Fatalf("Error connecting to database: %v", err)
+	}
+	defer db.Close()
+
+	if err := setupDatabase(db); err != nil {
+		t.Fatalf("Error setting up database: %v", err)
+	}
+
+	// Несколько лент сохраняются одновременно, а поток читает публикации
+	// после последнего полученного ID: ни одна публикация не должна потеряться
+	const feeds, batches, batchSize = 4, 10, 5
+	done := make(chan error, feeds)
+	for f := 0; f < feeds; f++ {
+		go func(f int) {
+			for b := 0; b < batches; b++ {
+				var posts []rss.Post
+				for i := 0; i < batchSize; i++ {
+					posts = append(posts, rss.Post{
+						Title:   fmt.Sprintf("Feed %d post %d-%d", f, b, i),
+						PubDate: time.Now().Format(time.RFC1123Z),
+						Link:    fmt.Sprintf("http://example.com/concurrent/%d/%d/%d", f, b, i),
+					})
+				}
+				if _, err := db.SavePosts(context.Background(), posts); err != nil {
+					done <- err
+					return
+				}
+			}
+			done <- nil
+		}(f)
+	}
+
+	seen := make(map[int]bool)
+	lastID, finished := 0, 0
+	for finished < feeds || len(seen) < feeds*batches*batchSize {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("Error saving posts: %v", err)
+			}
+			finished++
+		default:
+		}
+		posts, err := db.GetPostsAfter(context.Background(), lastID, 100)
+		if err != nil {
+			t.Fatalf("Error getting posts: %v", err)
+		}
+		for _, post := range posts {
+			seen[post.ID] = true
+			lastID = post.ID
+		}
+		if finished == feeds && len(posts) == 0 {
+			break
+		}
+	}
+
+	if len(seen) != feeds*batches*batchSize {
+		t.Errorf("Expected stream to receive %d posts, got %d", feeds*batches*batchSize, len(seen))
 	}
 }
@@ -0,0 +1,212 @@
+// Пакет tracing настраивает трассировку OpenTelemetry: контекст трассировки
+// передаётся между сервисами в заголовках W3C traceparent и tracestate,
+// а спаны входящих запросов, опроса лент и запросов к базе данных
+// экспортируются в коллектор OTLP или в файл.
+//
+// Пакетом пользуются все сервисы: шлюз, комментарии и цензура подключают его
+// через replace на каталог News_aggregator. Идентификатор запроса в журналах
+// всех сервисов — идентификатор трассировки (TraceID), поэтому строки журнала
+// одного запроса совпадают между сервисами и со спанами.
+//
+// Экспортёр выбирается переменной окружения OTEL_TRACES_EXPORTER:
+//   - otlp — OTLP/HTTP, адрес из OTEL_EXPORTER_OTLP_ENDPOINT (по умолчанию localhost:4318);
+//   - file — JSON-строки в файл OTEL_TRACES_FILE (по умолчанию <сервис>-traces.json);
+//   - none или пусто — спаны не экспортируются, но идентификаторы трассировки
+//     создаются и передаются дальше.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Значения OTEL_TRACES_EXPORTER.
+const (
+	ExporterNone = "none"
+	ExporterOTLP = "otlp"
+	ExporterFile = "file"
+)
+
+// instrumentationName — имя, под которым спаны сервиса попадают в экспорт.
+const instrumentationName = "Task36a41"
+
+// propagator читает и записывает заголовки traceparent, tracestate и baggage.
+// Middleware использует его и без Init, поэтому идентификаторы трассировки
+// из входящих запросов доступны и в тестах.
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Init настраивает глобальные TracerProvider и распространитель контекста.
+// Возвращённая функция выгружает накопленные спаны и закрывает экспортёр;
+// её нужно вызвать при остановке сервиса.
+func Init(ctx context.Context, service string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagator)
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(service)))
+	if err != nil {
+		return nil, fmt.Errorf("could not create trace resource: %v", err)
+	}
+	options := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+
+	closeFile := func() error { return nil }
+	switch exporter := os.Getenv("OTEL_TRACES_EXPORTER"); exporter {
+	case "", ExporterNone:
+	case ExporterOTLP:
+		var clientOptions []otlptracehttp.Option
+		if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+			// Локальный коллектор по умолчанию слушает без TLS
+			clientOptions = append(clientOptions, otlptracehttp.WithEndpoint("localhost:4318"), otlptracehttp.WithInsecure())
+		}
+		otlp, err := otlptracehttp.New(ctx, clientOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("could not create OTLP exporter: %v", err)
+		}
+		options = append(options, sdktrace.WithBatcher(otlp))
+	case ExporterFile:
+		path := os.Getenv("OTEL_TRACES_FILE")
+		if path == "" {
+			path = service + "-traces.json"
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("could not open traces file: %v", err)
+		}
+		stdout, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("could not create file exporter: %v", err)
+		}
+		options = append(options, sdktrace.WithBatcher(stdout))
+		closeFile = file.Close
+	default:
+		return nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q: must be %s, %s or %s", exporter, ExporterOTLP, ExporterFile, ExporterNone)
+	}
+
+	provider := sdktrace.NewTracerProvider(options...)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closeErr := closeFile(); err == nil {
+			err = closeErr
+		}
+		return err
+	}, nil
+}
+
+// Tracer возвращает трассировщик сервиса.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// TraceID возвращает идентификатор трассировки из контекста или пустую строку.
+func TraceID(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
+
+// Inject записывает контекст трассировки из ctx в заголовки исходящего запроса.
+func Inject(ctx context.Context, header http.Header) {
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// Middleware продолжает трассировку из заголовков traceparent и tracestate
+// входящего запроса или начинает новую и создаёт серверный спан,
+// названный по шаблону маршрута gorilla/mux, например "GET /news/{n}".
+// Подключается через router.Use, чтобы маршрут был уже известен.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := r.URL.Path
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		serve(w, r, next, route)
+	})
+}
+
+// routeKey — ключ контекста, под которым ServeMux сохраняет шаблон маршрута.
+type routeKey struct{}
+
+// Route возвращает шаблон маршрута http.ServeMux, найденный ServeMux,
+// или пустую строку, если запрос пришёл не через ServeMux.
+func Route(ctx context.Context) string {
+	route, _ := ctx.Value(routeKey{}).(string)
+	return route
+}
+
+// ServeMux — то же, что Middleware, для сервисов на http.ServeMux: возвращает
+// обработчик mux, который создаёт серверный спан, названный по шаблону маршрута,
+// например "GET /news/details", и передаёт запрос mux через middleware.
+// Первый из middleware — внешний; шаблон маршрута они получают через Route.
+// Запросы к неизвестным путям попадают в маршрут "unmatched".
+func ServeMux(m *http.ServeMux, middleware ...func(http.Handler) http.Handler) http.Handler {
+	var next http.Handler = m
+	for i := len(middleware) - 1; i >= 0; i-- {
+		next = middleware[i](next)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, route := m.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+		serve(w, r.WithContext(context.WithValue(r.Context(), routeKey{}, route)), next, route)
+	})
+}
+
+// serve обрабатывает запрос в серверном спане маршрута route.
+func serve(w http.ResponseWriter, r *http.Request, next http.Handler, route string) {
+	ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := Tracer().Start(ctx, r.Method+" "+route,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.HTTPMethod(r.Method), semconv.HTTPRoute(route), semconv.HTTPTarget(r.URL.RequestURI())))
+	defer span.End()
+
+	rec := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+	next.ServeHTTP(rec, r.WithContext(ctx))
+
+	span.SetAttributes(semconv.HTTPStatusCode(rec.statusCode))
+	if rec.statusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(rec.statusCode))
+	}
+}
+
+// End завершает спан, отмечая ошибку, если она есть.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// statusRecorder запоминает код ответа для спана.
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	rec.statusCode = code
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap открывает исходный ResponseWriter для http.ResponseController.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
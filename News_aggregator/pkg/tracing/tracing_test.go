@@ -0,0 +1,183 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+)
+
+const (
+	testTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testParentID    = "00f067aa0ba902b7"
+	testTraceparent = "00-" + testTraceID + "-" + testParentID + "-01"
+)
+
+// recordSpans подменяет глобальный TracerProvider на записывающий спаны в память.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestMiddleware(t *testing.T) {
+	recorder := recordSpans(t)
+
+	var traceID string
+	router := mux.NewRouter()
+	router.Use(Middleware)
+	router.HandleFunc("/news/{n}", func(w http.ResponseWriter, r *http.Request) {
+		traceID = TraceID(r.Context())
+	})
+	router.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/news/10", nil)
+	req.Header.Set("traceparent", testTraceparent)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	if traceID != testTraceID {
+		t.Errorf("трассировка не продолжена: ожидался %s, получен %q", testTraceID, traceID)
+	}
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("ожидалось 2 спана, получено %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "GET /news/{n}" {
+		t.Errorf("неожиданное имя спана: %s", span.Name())
+	}
+	if span.Parent().SpanID().String() != testParentID || !span.Parent().IsRemote() {
+		t.Errorf("спан должен продолжать удалённый родитель %s, получен %v", testParentID, span.Parent())
+	}
+	if !hasAttribute(span, semconv.HTTPStatusCode(http.StatusOK)) {
+		t.Errorf("ожидался код ответа 200 в атрибутах: %v", span.Attributes())
+	}
+
+	// Без traceparent начинается новая трассировка, ошибки сервера отмечаются в спане
+	failed := spans[1]
+	if failed.Parent().IsValid() || failed.SpanContext().TraceID().String() == testTraceID {
+		t.Errorf("ожидалась новая трассировка, получен родитель %v", failed.Parent())
+	}
+	if failed.Status().Code != codes.Error {
+		t.Errorf("ожидался статус ошибки для ответа 500, получен %v", failed.Status())
+	}
+}
+
+func TestServeMux(t *testing.T) {
+	recorder := recordSpans(t)
+
+	var traceID, route string
+	m := http.NewServeMux()
+	m.HandleFunc("/news/details", func(w http.ResponseWriter, r *http.Request) {
+		traceID = TraceID(r.Context())
+	})
+	// middleware видит шаблон маршрута и работает внутри спана
+	var middlewareRoute string
+	handler := ServeMux(m, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			middlewareRoute = Route(r.Context())
+			next.ServeHTTP(w, r)
+			route = Route(r.Context())
+		})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/news/details?id=1", nil)
+	req.Header.Set("traceparent", testTraceparent)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if traceID != testTraceID {
+		t.Errorf("трассировка не продолжена: ожидался %s, получен %q", testTraceID, traceID)
+	}
+	if middlewareRoute != "/news/details" || route != "/news/details" {
+		t.Errorf("неверный маршрут в middleware: %q", middlewareRoute)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/unknown", nil))
+	if route != "unmatched" {
+		t.Errorf("ожидался маршрут unmatched, получен %q", route)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 || spans[0].Name() != "GET /news/details" || spans[1].Name() != "GET unmatched" {
+		t.Fatalf("неожиданные спаны: %v", spans)
+	}
+	if spans[0].Parent().SpanID().String() != testParentID {
+		t.Errorf("спан должен продолжать удалённый родитель %s, получен %v", testParentID, spans[0].Parent())
+	}
+}
+
+func TestInject(t *testing.T) {
+	recordSpans(t)
+
+	ctx, span := Tracer().Start(context.Background(), "GET news")
+	defer span.End()
+
+	header := http.Header{}
+	Inject(ctx, header)
+	want := "00-" + TraceID(ctx) + "-" + span.SpanContext().SpanID().String() + "-01"
+	if got := header.Get("traceparent"); got != want {
+		t.Errorf("ожидался traceparent %s, получен %q", want, got)
+	}
+}
+
+// hasAttribute проверяет, что у спана есть атрибут с заданным значением.
+func hasAttribute(span sdktrace.ReadOnlySpan, want attribute.KeyValue) bool {
+	for _, attr := range span.Attributes() {
+		if attr == want {
+			return true
+		}
+	}
+	return false
+}
+
+func TestInitFileExporter(t *testing.T) {
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	path := filepath.Join(t.TempDir(), "traces.json")
+	t.Setenv("OTEL_TRACES_EXPORTER", ExporterFile)
+	t.Setenv("OTEL_TRACES_FILE", path)
+
+	shutdown, err := Init(context.Background(), "news-test")
+	if err != nil {
+		t.Fatalf("ошибка инициализации: %v", err)
+	}
+	_, span := Tracer().Start(context.Background(), "db get_sources")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("ошибка остановки: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("файл трассировки не создан: %v", err)
+	}
+	if !strings.Contains(string(data), `"db get_sources"`) || !strings.Contains(string(data), `"news-test"`) {
+		t.Errorf("в файле нет спана или имени сервиса:\n%s", data)
+	}
+}
+
+func TestInitUnknownExporter(t *testing.T) {
+	t.Setenv("OTEL_TRACES_EXPORTER", "jaeger")
+	if _, err := Init(context.Background(), "news-test"); err == nil {
+		t.Error("ожидалась ошибка для неизвестного экспортёра")
+	}
+}
@@ -1,8 +1,38 @@
-DROP TABLE IF EXISTS posts;
-CREATE TABLE posts (
-    id SERIAL PRIMARY KEY,
-    title TEXT NOT NULL,
-    content TEXT NOT NULL,
-    pub_time BIGINT DEFAULT 0,
-    link TEXT NOT NULL UNIQUE
-);
\ No newline at end of file
+-- Схема БД агрегатора собирается из миграций в pkg/storage/migrations.
+-- Сервер применяет их автоматически при запуске и отмечает в таблице schema_migrations.
+-- Этот файл разворачивает ту же схему на пустой базе вручную: psql -f schema.sql.
+-- Версии миграций записываются в schema_migrations, как это делает сервер,
+-- поэтому при запуске он не применяет их повторно.
+\set ON_ERROR_STOP on
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INT PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+\ir pkg/storage/migrations/0001_create_posts.sql
+INSERT INTO schema_migrations (version, name) VALUES (1, '0001_create_posts.sql');
+\ir pkg/storage/migrations/0002_create_feeds.sql
+INSERT INTO schema_migrations (version, name) VALUES (2, '0002_create_feeds.sql');
+\ir pkg/storage/migrations/0003_feed_health.sql
+INSERT INTO schema_migrations (version, name) VALUES (3, '0003_feed_health.sql');
+\ir pkg/storage/migrations/0004_posts_search.sql
+INSERT INTO schema_migrations (version, name) VALUES (4, '0004_posts_search.sql');
+\ir pkg/storage/migrations/0005_posts_source.sql
+INSERT INTO schema_migrations (version, name) VALUES (5, '0005_posts_source.sql');
+\ir pkg/storage/migrations/0006_posts_keyset_index.sql
+INSERT INTO schema_migrations (version, name) VALUES (6, '0006_posts_keyset_index.sql');
+\ir pkg/storage/migrations/0007_sources.sql
+INSERT INTO schema_migrations (version, name) VALUES (7, '0007_sources.sql');
+\ir pkg/storage/migrations/0008_posts_metadata.sql
+INSERT INTO schema_migrations (version, name) VALUES (8, '0008_posts_metadata.sql');
+\ir pkg/storage/migrations/0009_posts_content_text.sql
+INSERT INTO schema_migrations (version, name) VALUES (9, '0009_posts_content_text.sql');
+\ir pkg/storage/migrations/0010_full_text.sql
+INSERT INTO schema_migrations (version, name) VALUES (10, '0010_full_text.sql');
+\ir pkg/storage/migrations/0011_posts_clusters.sql
+INSERT INTO schema_migrations (version, name) VALUES (11, '0011_posts_clusters.sql');
+
+COMMIT;
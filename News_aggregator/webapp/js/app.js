@@ -14,24 +14,86 @@ async function loadNews() {
         // Очищаем контейнер перед добавлением новых карточек
         newsContainer.innerHTML = '';
 
-        // Генерируем HTML для каждой новости
+        // Собираем карточки через DOM: данные из лент вставляются только как текст
         posts.forEach(post => {
-            const newsCard = document.createElement("div");
-            newsCard.classList.add("news-card");
-
-            newsCard.innerHTML = `
-                <h2><a href="${post.Link}" target="_blank">${post.Title}</a></h2>
-                <p>${post.Content}</p>
-                <div class="pubdate">${post.PubDate}</div>
-            `;
-
-            newsContainer.appendChild(newsCard);
+            newsContainer.appendChild(newsCard(post));
         });
+
+        // Дальше новые публикации приходят потоком
+        subscribeNews(newsContainer);
     } catch (error) {
         console.error("Ошибка загрузки новостей:", error);
         newsContainer.innerHTML = `<p>Не удалось загрузить новости. Попробуйте обновить страницу позже.</p>`;
     }
 }
 
+// subscribeNews открывает поток /news/stream и добавляет новые публикации в начало ленты.
+// При первом подключении last_event_id не передаётся: /news/10 упорядочен по дате
+// публикации, а не по id, и сервер сам начинает поток с последней сохранённой публикации.
+// После обрыва EventSource переподключается сам и передаёт Last-Event-ID,
+// поэтому публикации, сохранённые за время обрыва, не теряются.
+function subscribeNews(newsContainer) {
+    if (!window.EventSource) {
+        return;
+    }
+    const source = new EventSource('/news/stream');
+    source.addEventListener("post", event => {
+        const post = JSON.parse(event.data);
+        if (newsContainer.querySelector(`[data-id="${post.id}"]`)) {
+            return;
+        }
+        newsContainer.prepend(newsCard(post));
+    });
+    source.onerror = () => {
+        console.warn("Поток новостей прерван, переподключение...");
+    };
+}
+
+// newsCard создаёт карточку новости. Заголовок и превью вставляются как текст,
+// поэтому разметка из ленты не может выполнить скрипт на странице.
+function newsCard(post) {
+    const card = document.createElement("div");
+    card.classList.add("news-card");
+    card.dataset.id = post.id;
+
+    const title = document.createElement("h2");
+    const link = document.createElement("a");
+    link.textContent = post.Title;
+    link.target = "_blank";
+    link.rel = "noopener noreferrer";
+    const href = safeURL(post.Link);
+    if (href) {
+        link.href = href;
+    }
+    title.appendChild(link);
+
+    const preview = document.createElement("p");
+    preview.textContent = post.content_text || plainText(post.Content);
+
+    const pubdate = document.createElement("div");
+    pubdate.classList.add("pubdate");
+    pubdate.textContent = post.PubDate;
+
+    card.append(title, preview, pubdate);
+    return card;
+}
+
+// safeURL возвращает адрес, только если это ссылка http(s), иначе пустую строку.
+function safeURL(value) {
+    try {
+        const url = new URL(value, window.location.href);
+        return url.protocol === "http:" || url.protocol === "https:" ? url.href : "";
+    } catch (e) {
+        return "";
+    }
+}
+
+// plainText извлекает текст из HTML публикаций, сохранённых до появления content_text.
+// DOMParser не выполняет скрипты и не загружает ресурсы.
+function plainText(html) {
+    const doc = new DOMParser().parseFromString(html || "", "text/html");
+    return (doc.body.textContent || "").replace(/\s+/g, " ").trim();
+}
+
 // Загружаем новости при загрузке страницы
 window.onload = loadNews;
\ No newline at end of file
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// HealthzHandler сообщает, что процесс жив и обрабатывает запросы.
+func HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, map[string]interface{}{"status": "ok"})
+}
+
+// ReadyzHandler сообщает, готов ли сервис проверять тексты. Внешних зависимостей
+// у сервиса нет, поэтому проверяется только загруженный список запрещённых слов.
+func ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	if len(forbiddenWords) == 0 {
+		writeHealth(w, http.StatusServiceUnavailable, map[string]interface{}{
+			"status": "unavailable",
+			"checks": map[string]interface{}{"forbidden_words": map[string]string{"status": "unavailable", "error": "forbidden words list is empty"}},
+		})
+		return
+	}
+	writeHealth(w, http.StatusOK, map[string]interface{}{
+		"status": "ok",
+		"checks": map[string]interface{}{"forbidden_words": map[string]string{"status": "ok"}},
+	})
+}
+
+func writeHealth(w http.ResponseWriter, code int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(response)
+}
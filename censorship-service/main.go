@@ -1,36 +1,94 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
+
+	"Task36a41/pkg/tracing"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// shutdownTimeout — сколько ждать завершения обрабатываемых запросов при остановке.
+const shutdownTimeout = 10 * time.Second
+
 func main() {
-	http.HandleFunc("/censor", CensorHandler)
-	log.Println("Starting censorship service on :8083...")
-	log.Fatal(http.ListenAndServe(":8083", nil))
+	// SIGINT и SIGTERM отменяют контекст и запускают остановку сервера
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Трассировка: экспортёр задаётся переменными окружения OTEL_*
+	shutdownTracing, err := tracing.Init(ctx, "censorship")
+	if err != nil {
+		log.Fatalf("Error initializing tracing: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/censor", CensorHandler)
+	mux.HandleFunc("/healthz", HealthzHandler)
+	mux.HandleFunc("/readyz", ReadyzHandler)
+	mux.Handle("/metrics", promhttp.Handler())
+
+	server := &http.Server{Addr: ":8083", Handler: tracing.ServeMux(mux, metricsMiddleware)}
+	go func() {
+		log.Println("Starting censorship service on :8083...")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Error starting server: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop() // Повторный сигнал завершит процесс сразу
+	log.Println("Shutting down censorship service...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down server: %v", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Printf("Error shutting down tracing: %v", err)
+	}
+	log.Println("Censorship service stopped")
 }
 
 func CensorHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := tracing.TraceID(r.Context())
+	span := trace.SpanFromContext(r.Context())
+
 	var request struct {
 		Text string `json:"text"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		log.Println("Invalid JSON format")
+		log.Printf("[Request ID: %s] Invalid JSON format", requestID)
+		censorResults.WithLabelValues("invalid").Inc()
+		span.SetAttributes(attribute.String("censorship.result", "invalid"))
 		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("Text for censorship: %s", request.Text)
+	log.Printf("[Request ID: %s] Text for censorship: %s", requestID, request.Text)
 	if containsForbiddenWords(request.Text) {
-		log.Println("Text contains forbidden words")
+		log.Printf("[Request ID: %s] Text contains forbidden words", requestID)
+		censorResults.WithLabelValues("rejected").Inc()
+		span.SetAttributes(attribute.String("censorship.result", "rejected"))
 		http.Error(w, "Text contains forbidden words", http.StatusBadRequest)
 		return
 	}
 
-	log.Println("Text passed censorship")
+	log.Printf("[Request ID: %s] Text passed censorship", requestID)
+	censorResults.WithLabelValues("accepted").Inc()
+	span.SetAttributes(attribute.String("censorship.result", "accepted"))
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"Task36a41/pkg/tracing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Метрики сервиса цензуры в формате Prometheus, отдаются на /metrics.
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "censorship_http_requests_total",
+		Help: "Количество обработанных HTTP-запросов по маршруту, методу и коду ответа.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "censorship_http_request_duration_seconds",
+		Help:    "Время обработки HTTP-запросов по маршруту, методу и коду ответа.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	censorResults = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "censorship_checks_total",
+		Help: "Результаты проверки текстов: accepted, rejected или invalid.",
+	}, []string{"result"})
+)
+
+// statusRecorder запоминает код ответа для метрик.
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (rw *statusRecorder) WriteHeader(code int) {
+	rw.statusCode = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+// metricsMiddleware считает запросы и время их обработки. Маршрутом служит
+// шаблон из mux, найденный tracing.ServeMux, поэтому запросы к неизвестным
+// путям не порождают новых рядов.
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(rw, r)
+
+		route := tracing.Route(r.Context())
+		status := strconv.Itoa(rw.statusCode)
+		httpRequests.WithLabelValues(route, r.Method, status).Inc()
+		httpDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+	})
+}